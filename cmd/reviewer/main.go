@@ -10,12 +10,10 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/firebase/genkit/go/genkit"
-	"github.com/firebase/genkit/go/plugins/compat_oai/openai"
-	"github.com/firebase/genkit/go/plugins/googlegenai"
 	"github.com/surya84/code-reviewer-bot/config"
-	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/internal/llm"
 	"github.com/surya84/code-reviewer-bot/internal/reviewer"
+	"github.com/surya84/code-reviewer-bot/pkg/tracing"
 	"github.com/surya84/code-reviewer-bot/pkg/vcs"
 )
 
@@ -24,6 +22,7 @@ var (
 	repoOwner  string
 	repoName   string
 	prNumber   int
+	sinceSHA   string
 )
 
 var rootCmd = &cobra.Command{
@@ -37,7 +36,12 @@ var rootCmd = &cobra.Command{
 			log.Fatalf("❌ Failed to load config: %v", err)
 		}
 
-		g, err := initGenkit(ctx, cfg)
+		shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+		if err != nil {
+			log.Fatalf("❌ Failed to set up tracing: %v", err)
+		}
+
+		g, err := llm.Init(ctx, cfg)
 		if err != nil {
 			log.Fatalf("❌ Failed to initialize Genkit: %v", err)
 		}
@@ -46,6 +50,7 @@ var rootCmd = &cobra.Command{
 		if err != nil {
 			log.Fatalf("❌ Failed to get PR details: %v", err)
 		}
+		prDetails.BaseSHA = sinceSHA
 
 		vcsClient, err := vcs.NewVCSClient(ctx, &cfg.VCS)
 		if err != nil {
@@ -53,6 +58,10 @@ var rootCmd = &cobra.Command{
 		}
 
 		result, err := reviewer.RunReview(ctx, g, prDetails, cfg, vcsClient)
+		// Flush the spans before a failure exits the process.
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Warning: could not flush traces: %v", err)
+		}
 		if err != nil {
 			log.Fatalf("❌ Code review process failed: %v", err)
 		}
@@ -62,29 +71,17 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
-	rootCmd.Flags().StringVar(&configPath, "config", "/app/config/config.yaml", "Path to config.yaml")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "/app/config/config.yaml", "Path to config.yaml")
 	rootCmd.Flags().StringVar(&repoOwner, "repo-owner", "", "Repository owner (overrides env)")
 	rootCmd.Flags().StringVar(&repoName, "repo-name", "", "Repository name (overrides env)")
 	rootCmd.Flags().IntVar(&prNumber, "pr-number", 0, "PR number (overrides env)")
+	rootCmd.Flags().StringVar(&sinceSHA, "since", "", "Only review changes pushed after this commit (defaults to the last reviewed commit)")
 }
 
 func Execute() {
 	cobra.CheckErr(rootCmd.Execute())
 }
 
-func initGenkit(ctx context.Context, cfg *config.Config) (*genkit.Genkit, error) {
-	var plugin genkit.Plugin
-	switch cfg.LLM.Provider {
-	case constants.GOOGLEAI:
-		plugin = &googlegenai.GoogleAI{APIKey: cfg.LLM.GoogleAI.APIKey}
-	case constants.OPENAI:
-		plugin = &openai.OpenAI{APIKey: cfg.LLM.OpenAI.APIKey}
-	default:
-		return nil, fmt.Errorf("unsupported LLM provider: %s", cfg.LLM.Provider)
-	}
-	return genkit.Init(ctx, genkit.WithPlugins(plugin))
-}
-
 func getPRDetails() (*reviewer.PRDetails, error) {
 	repoSlug := os.Getenv("GITHUB_REPOSITORY")
 	if repoSlug == "" {
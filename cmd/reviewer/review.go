@@ -0,0 +1,245 @@
+package reviewer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/spf13/cobra"
+
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/internal/diffparser"
+	"github.com/surya84/code-reviewer-bot/internal/llm"
+	"github.com/surya84/code-reviewer-bot/internal/reviewer"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+var (
+	localDir   string
+	localBase  string
+	outputPath string
+	diffFormat string
+)
+
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Review changes without a VCS host",
+}
+
+var reviewLocalCmd = &cobra.Command{
+	Use:   "local",
+	Short: "Review the current branch of a local git repository against a base ref",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			log.Fatalf("❌ Failed to load config: %v", err)
+		}
+
+		g, err := llm.Init(ctx, cfg)
+		if err != nil {
+			log.Fatalf("❌ Failed to initialize Genkit: %v", err)
+		}
+
+		out, closeOut, err := openOutput(outputPath)
+		if err != nil {
+			log.Fatalf("❌ Failed to open output: %v", err)
+		}
+		defer closeOut()
+
+		absDir, err := filepath.Abs(localDir)
+		if err != nil {
+			log.Fatalf("❌ Invalid directory '%s': %v", localDir, err)
+		}
+		prDetails := &reviewer.PRDetails{
+			Owner: "local",
+			Repo:  filepath.Base(absDir),
+		}
+
+		vcsClient := vcs.NewLocalGitAdapter(absDir, localBase, out)
+
+		result, err := reviewer.RunReview(ctx, g, prDetails, cfg, vcsClient)
+		if err != nil {
+			log.Fatalf("❌ Code review process failed: %v", err)
+		}
+
+		log.Printf("✅ Process finished: %s", result)
+	},
+}
+
+var reviewDiffCmd = &cobra.Command{
+	Use:   "diff [file]",
+	Short: "Review a unified diff or patch file, or stdin when no file (or '-') is given",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		if diffFormat != "text" && diffFormat != "markdown" && diffFormat != "json" {
+			log.Fatalf("❌ Unsupported output format '%s': expected text, markdown or json", diffFormat)
+		}
+
+		diff, err := readDiff(args)
+		if err != nil {
+			log.Fatalf("❌ Failed to read diff: %v", err)
+		}
+
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			log.Fatalf("❌ Failed to load config: %v", err)
+		}
+
+		g, err := llm.Init(ctx, cfg)
+		if err != nil {
+			log.Fatalf("❌ Failed to initialize Genkit: %v", err)
+		}
+
+		out, closeOut, err := openOutput(outputPath)
+		if err != nil {
+			log.Fatalf("❌ Failed to open output: %v", err)
+		}
+		defer closeOut()
+
+		count, err := reviewDiff(ctx, out, g, cfg, diff, diffFormat)
+		if err != nil {
+			log.Fatalf("❌ Code review process failed: %v", err)
+		}
+
+		log.Printf("✅ Process finished: found %d comments.", count)
+	},
+}
+
+func init() {
+	reviewLocalCmd.Flags().StringVar(&localDir, "dir", ".", "Path to the git working directory")
+	reviewLocalCmd.Flags().StringVar(&localBase, "base", "main", "Base ref to diff HEAD against")
+	reviewCmd.PersistentFlags().StringVarP(&outputPath, "output", "o", "", "Write the review to this file instead of stdout")
+
+	reviewDiffCmd.Flags().StringVar(&diffFormat, "format", "text", "Output format: text, markdown or json")
+
+	reviewCmd.AddCommand(reviewLocalCmd)
+	reviewCmd.AddCommand(reviewDiffCmd)
+	rootCmd.AddCommand(reviewCmd)
+}
+
+// openOutput returns stdout when path is empty, or a newly created file otherwise.
+func openOutput(path string) (io.Writer, func(), error) {
+	if path == "" {
+		return os.Stdout, func() {}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() {
+		if err := f.Close(); err != nil {
+			log.Printf("Warning: failed to close output file: %v", err)
+		}
+	}, nil
+}
+
+// readDiff reads the diff from the file named in args, or from stdin.
+func readDiff(args []string) (string, error) {
+	if len(args) == 0 || args[0] == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), err
+	}
+	data, err := os.ReadFile(args[0])
+	return string(data), err
+}
+
+// reviewDiff reviews diff and writes the comments and the chunks the model could not review to
+// w in the given format, returning how many comments were written. The review fails without
+// writing anything when none of the chunks could be reviewed.
+func reviewDiff(ctx context.Context, w io.Writer, g *genkit.Genkit, cfg *config.Config, diff, format string) (int, error) {
+	comments, unreviewed, err := reviewer.ReviewDiff(ctx, g, cfg, diff)
+	for _, chunk := range unreviewed {
+		start, end := chunk.NewLineRange()
+		log.Printf("⚠️ Could not review %s:%d-%d", chunk.FilePath, start, end)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := writeComments(w, format, comments, unreviewed); err != nil {
+		return 0, fmt.Errorf("failed to write review: %w", err)
+	}
+	return len(comments), nil
+}
+
+// writeComments renders review comments, followed by the chunks the model could not review, in
+// the requested output format. A review with unreviewed chunks is never reported as clean.
+func writeComments(w io.Writer, format string, comments []*vcs.Comment, unreviewed []*diffparser.DiffChunk) error {
+	switch format {
+	case "json":
+		type jsonComment struct {
+			Path       string  `json:"path"`
+			Line       int     `json:"line"`
+			Body       string  `json:"body"`
+			Severity   string  `json:"severity"`
+			Category   string  `json:"category"`
+			Confidence float64 `json:"confidence"`
+		}
+		type jsonChunk struct {
+			Path      string `json:"path"`
+			StartLine int    `json:"start_line"`
+			EndLine   int    `json:"end_line"`
+		}
+		result := struct {
+			Comments   []jsonComment `json:"comments"`
+			Unreviewed []jsonChunk   `json:"unreviewed"`
+		}{
+			Comments:   make([]jsonComment, 0, len(comments)),
+			Unreviewed: make([]jsonChunk, 0, len(unreviewed)),
+		}
+		for _, c := range comments {
+			result.Comments = append(result.Comments, jsonComment{Path: c.Path, Line: c.Line, Body: c.Body, Severity: c.Severity, Category: c.Category, Confidence: c.Confidence})
+		}
+		for _, chunk := range unreviewed {
+			start, end := chunk.NewLineRange()
+			result.Unreviewed = append(result.Unreviewed, jsonChunk{Path: chunk.FilePath, StartLine: start, EndLine: end})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case "markdown":
+		if len(comments) == 0 && len(unreviewed) == 0 {
+			_, err := fmt.Fprintln(w, "✅ AI Review Complete: No issues found. Great work!")
+			return err
+		}
+		var sb strings.Builder
+		sb.WriteString("### AI Code Review\n\n")
+		for _, c := range comments {
+			sb.WriteString(fmt.Sprintf("- **`%s` (Line %d):** %s\n", c.Path, c.Line, c.Body))
+		}
+		if len(unreviewed) > 0 {
+			if len(comments) > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString("### ⚠️ Not reviewed\n\nThe model could not review these changes, so they may still contain issues.\n\n")
+			for _, chunk := range unreviewed {
+				start, end := chunk.NewLineRange()
+				sb.WriteString(fmt.Sprintf("- `%s` lines %d-%d\n", chunk.FilePath, start, end))
+			}
+		}
+		_, err := io.WriteString(w, sb.String())
+		return err
+	default:
+		for _, c := range comments {
+			if _, err := fmt.Fprintf(w, "%s:%d: %s\n", c.Path, c.Line, c.Body); err != nil {
+				return err
+			}
+		}
+		for _, chunk := range unreviewed {
+			start, end := chunk.NewLineRange()
+			if _, err := fmt.Fprintf(w, "%s:%d-%d: not reviewed: the model could not review these changes\n", chunk.FilePath, start, end); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
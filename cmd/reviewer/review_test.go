@@ -0,0 +1,196 @@
+package reviewer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/surya84/code-reviewer-bot/config"
+)
+
+const testPatch = `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,2 +1,3 @@
+ package a
++var x = 1
+ func f() {}
+`
+
+func TestReadDiff(t *testing.T) {
+	t.Run("Patch File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "change.patch")
+		require.NoError(t, os.WriteFile(path, []byte(testPatch), 0o644))
+
+		diff, err := readDiff([]string{path})
+		require.NoError(t, err)
+		assert.Equal(t, testPatch, diff)
+	})
+
+	t.Run("Missing File", func(t *testing.T) {
+		_, err := readDiff([]string{filepath.Join(t.TempDir(), "missing.patch")})
+		assert.Error(t, err)
+	})
+
+	for name, args := range map[string][]string{"Stdin": nil, "Stdin Dash": {"-"}} {
+		t.Run(name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			require.NoError(t, err)
+			stdin := os.Stdin
+			os.Stdin = r
+			defer func() { os.Stdin = stdin }()
+			_, err = w.WriteString(testPatch)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			diff, err := readDiff(args)
+			require.NoError(t, err)
+			assert.Equal(t, testPatch, diff)
+		})
+	}
+}
+
+// setupFakeModel registers a model that answers each review prompt with respond.
+func setupFakeModel(t *testing.T, respond func(prompt string) (string, error)) (*genkit.Genkit, *config.Config) {
+	g, err := genkit.Init(context.Background())
+	require.NoError(t, err)
+
+	info := &ai.ModelInfo{Supports: &ai.ModelSupports{Constrained: ai.ConstrainedSupportAll}}
+	genkit.DefineModel(g, "fake", "reviewer", info, func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+		response, err := respond(req.Messages[len(req.Messages)-1].Text())
+		if err != nil {
+			return nil, err
+		}
+		return &ai.ModelResponse{Request: req, Message: ai.NewModelTextMessage(response)}, nil
+	})
+
+	cfg := &config.Config{ReviewPrompt: "Review {{.FilePath}}:\n{{.CodeSnippet}}"}
+	cfg.LLM.ModelName = "fake/reviewer"
+	return g, cfg
+}
+
+// failingPatch adds a second file whose chunk the model cannot review.
+const failingPatch = testPatch + `diff --git a/b.go b/b.go
+--- a/b.go
++++ b/b.go
+@@ -1,2 +1,3 @@
+ package b
++var y = 2
+ func g() {}
+`
+
+func TestWriteComments(t *testing.T) {
+	finding := `{"comments":[{"line":2,"line_content":"","message":"x is unused.","severity":"minor","category":"bug","confidence":0.9}]}`
+	body := "**🟡 Minor** · 🐛 Bug · 90% confidence\\n\\nx is unused."
+	tests := []struct {
+		name     string
+		format   string
+		response string
+		diff     string // testPatch when empty.
+		want     string
+	}{
+		{
+			name:     "Text",
+			format:   "text",
+			response: finding,
+			want:     "a.go:2: **🟡 Minor** · 🐛 Bug · 90% confidence\n\nx is unused.\n",
+		},
+		{
+			name:     "Text - No Comments",
+			format:   "text",
+			response: `{"comments":[]}`,
+			want:     "",
+		},
+		{
+			name:     "Markdown",
+			format:   "markdown",
+			response: finding,
+			want:     "### AI Code Review\n\n- **`a.go` (Line 2):** **🟡 Minor** · 🐛 Bug · 90% confidence\n\nx is unused.\n",
+		},
+		{
+			name:     "Markdown - No Comments",
+			format:   "markdown",
+			response: `{"comments":[]}`,
+			want:     "✅ AI Review Complete: No issues found. Great work!\n",
+		},
+		{
+			name:     "JSON",
+			format:   "json",
+			response: finding,
+			want:     `{"comments":[{"path":"a.go","line":2,"body":"` + body + `","severity":"minor","category":"bug","confidence":0.9}],"unreviewed":[]}`,
+		},
+		{
+			name:     "JSON - No Comments",
+			format:   "json",
+			response: `{"comments":[]}`,
+			want:     `{"comments":[],"unreviewed":[]}`,
+		},
+		{
+			name:     "Text - Unreviewed Chunk",
+			format:   "text",
+			response: finding,
+			diff:     failingPatch,
+			want:     "a.go:2: **🟡 Minor** · 🐛 Bug · 90% confidence\n\nx is unused.\nb.go:1-3: not reviewed: the model could not review these changes\n",
+		},
+		{
+			name:     "Markdown - Unreviewed Chunk",
+			format:   "markdown",
+			response: `{"comments":[]}`,
+			diff:     failingPatch,
+			want:     "### AI Code Review\n\n### ⚠️ Not reviewed\n\nThe model could not review these changes, so they may still contain issues.\n\n- `b.go` lines 1-3\n",
+		},
+		{
+			name:     "JSON - Unreviewed Chunk",
+			format:   "json",
+			response: `{"comments":[]}`,
+			diff:     failingPatch,
+			want:     `{"comments":[],"unreviewed":[{"path":"b.go","start_line":1,"end_line":3}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, cfg := setupFakeModel(t, func(prompt string) (string, error) {
+				if strings.HasPrefix(prompt, "Review b.go") {
+					return "", errors.New("model unavailable")
+				}
+				return tt.response, nil
+			})
+			diff := tt.diff
+			if diff == "" {
+				diff = testPatch
+			}
+
+			var out bytes.Buffer
+			_, err := reviewDiff(context.Background(), &out, g, cfg, diff, tt.format)
+			require.NoError(t, err)
+			if tt.format == "json" {
+				assert.JSONEq(t, tt.want, out.String())
+			} else {
+				assert.Equal(t, tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestReviewDiff_ModelFails(t *testing.T) {
+	g, cfg := setupFakeModel(t, func(string) (string, error) {
+		return "", errors.New("model unavailable")
+	})
+
+	var out bytes.Buffer
+	count, err := reviewDiff(context.Background(), &out, g, cfg, testPatch, "markdown")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "could not review any of the 1 chunks")
+	assert.Zero(t, count)
+	// Nothing may be written that reads like a clean review.
+	assert.Empty(t, out.String())
+}
@@ -2,18 +2,17 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
 
-	"github.com/firebase/genkit/go/genkit"
-	"github.com/firebase/genkit/go/plugins/compat_oai/openai"
-	"github.com/firebase/genkit/go/plugins/googlegenai"
 	"github.com/gin-gonic/gin"
 	"github.com/surya84/code-reviewer-bot/config"
 	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/internal/llm"
 	"github.com/surya84/code-reviewer-bot/internal/webhook"
+	"github.com/surya84/code-reviewer-bot/pkg/metrics"
+	"github.com/surya84/code-reviewer-bot/pkg/tracing"
 )
 
 func main() {
@@ -25,70 +24,144 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	g, err := initGenkit(ctx, cfg)
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	g, err := llm.Init(ctx, cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize Genkit: %v", err)
 	}
 
+	reviews, err := webhook.NewReviewQueue(g, cfg)
+	if err != nil {
+		log.Fatalf("Failed to open review queue: %v", err)
+	}
+	defer reviews.Close()
+
 	router := gin.Default()
 
+	// Set up a handler for each configured provider. A provider that is only partly configured
+	// stops the server rather than running without webhook verification or without access.
+
 	// set up the GitHub handler.
 	githubWebhookSecret := os.Getenv("GITHUB_WEBHOOK_SECRET")
 	githubToken := os.Getenv("GITHUB_TOKEN")
-	if githubWebhookSecret != "" && githubToken != "" {
+	githubCredentials := githubToken != "" || cfg.VCS.GitHub.AppID != 0
+	switch {
+	case githubWebhookSecret == "" && !githubCredentials:
+		log.Println("INFO: GITHUB_WEBHOOK_SECRET and GitHub credentials (GITHUB_TOKEN or a GitHub App) not found. Skipping GitHub handler setup.")
+	case githubWebhookSecret == "":
+		log.Fatalf("GitHub credentials are set but GITHUB_WEBHOOK_SECRET is empty, so GitHub webhooks could not be verified.")
+	case !githubCredentials:
+		log.Fatalf("GITHUB_WEBHOOK_SECRET is set but neither GITHUB_TOKEN nor a GitHub App is configured.")
+	default:
 		log.Println("GitHub credentials found. Initializing GitHub handler...")
-		githubHandler, err := webhook.NewGitHubWebhookHandler(g, cfg, githubWebhookSecret)
+		githubHandler, err := webhook.NewGitHubWebhookHandler(reviews, cfg, githubWebhookSecret)
 		if err != nil {
-			log.Printf("WARNING: Could not create GitHub webhook handler: %v", err)
-		} else {
-			router.POST("/api/github/webhook", githubHandler.Handle)
-			log.Println("✅ GitHub webhook endpoint (/api/github/webhook) is active.")
+			log.Fatalf("Failed to create GitHub webhook handler: %v", err)
 		}
-	} else {
-		log.Println("INFO: GITHUB_WEBHOOK_SECRET or GITHUB_TOKEN not found. Skipping GitHub handler setup.")
+		router.POST("/api/github/webhook", metrics.Webhook(constants.GITHUB), tracing.Webhook(constants.GITHUB), githubHandler.Handle)
+		log.Println("✅ GitHub webhook endpoint (/api/github/webhook) is active.")
 	}
 
 	// set up the Gitea handler.
 	giteaWebhookSecret := os.Getenv("GITEA_WEBHOOK_SECRET")
 	giteaToken := os.Getenv("GITEA_TOKEN")
-	if giteaWebhookSecret != "" && giteaToken != "" {
+	switch {
+	case giteaWebhookSecret == "" && giteaToken == "":
+		log.Println("INFO: GITEA_WEBHOOK_SECRET and GITEA_TOKEN not found. Skipping Gitea handler setup.")
+	case giteaWebhookSecret == "":
+		log.Fatalf("GITEA_TOKEN is set but GITEA_WEBHOOK_SECRET is empty, so Gitea webhooks could not be verified.")
+	case giteaToken == "":
+		log.Fatalf("GITEA_WEBHOOK_SECRET is set but GITEA_TOKEN is empty.")
+	default:
 		log.Println("Gitea credentials found. Initializing Gitea handler...")
-		giteaHandler, err := webhook.NewGiteaWebhookHandler(g, cfg, giteaWebhookSecret)
+		giteaHandler, err := webhook.NewGiteaWebhookHandler(reviews, cfg, giteaWebhookSecret)
 		if err != nil {
-			log.Printf("WARNING: Could not create Gitea webhook handler: %v", err)
-		} else {
-			router.POST("/api/gitea/webhook", giteaHandler.Handle)
-			log.Println("✅ Gitea webhook endpoint (/api/gitea/webhook) is active.")
+			log.Fatalf("Failed to create Gitea webhook handler: %v", err)
 		}
-	} else {
-		log.Println("INFO: GITEA_WEBHOOK_SECRET or GITEA_TOKEN not found. Skipping Gitea handler setup.")
+		router.POST("/api/gitea/webhook", metrics.Webhook(constants.GITEA), tracing.Webhook(constants.GITEA), giteaHandler.Handle)
+		log.Println("✅ Gitea webhook endpoint (/api/gitea/webhook) is active.")
+	}
+
+	// set up the GitLab handler.
+	gitlabWebhookSecret := os.Getenv("GITLAB_WEBHOOK_SECRET")
+	gitlabToken := os.Getenv("GITLAB_TOKEN")
+	switch {
+	case gitlabWebhookSecret == "" && gitlabToken == "":
+		log.Println("INFO: GITLAB_WEBHOOK_SECRET and GITLAB_TOKEN not found. Skipping GitLab handler setup.")
+	case gitlabWebhookSecret == "":
+		log.Fatalf("GITLAB_TOKEN is set but GITLAB_WEBHOOK_SECRET is empty, so GitLab webhooks could not be verified.")
+	case gitlabToken == "":
+		log.Fatalf("GITLAB_WEBHOOK_SECRET is set but GITLAB_TOKEN is empty.")
+	default:
+		log.Println("GitLab credentials found. Initializing GitLab handler...")
+		gitlabHandler, err := webhook.NewGitLabWebhookHandler(reviews, cfg, gitlabWebhookSecret)
+		if err != nil {
+			log.Fatalf("Failed to create GitLab webhook handler: %v", err)
+		}
+		router.POST("/api/gitlab/webhook", metrics.Webhook(constants.GITLAB), tracing.Webhook(constants.GITLAB), gitlabHandler.Handle)
+		log.Println("✅ GitLab webhook endpoint (/api/gitlab/webhook) is active.")
+	}
+
+	// set up the Bitbucket Server handler.
+	bitbucketWebhookSecret := os.Getenv("BITBUCKET_WEBHOOK_SECRET")
+	bitbucketToken := os.Getenv("BITBUCKET_TOKEN")
+	switch {
+	case bitbucketWebhookSecret == "" && bitbucketToken == "":
+		log.Println("INFO: BITBUCKET_WEBHOOK_SECRET and BITBUCKET_TOKEN not found. Skipping Bitbucket handler setup.")
+	case bitbucketWebhookSecret == "":
+		log.Fatalf("BITBUCKET_TOKEN is set but BITBUCKET_WEBHOOK_SECRET is empty, so Bitbucket webhooks could not be verified.")
+	case bitbucketToken == "":
+		log.Fatalf("BITBUCKET_WEBHOOK_SECRET is set but BITBUCKET_TOKEN is empty.")
+	default:
+		log.Println("Bitbucket credentials found. Initializing Bitbucket handler...")
+		bitbucketHandler, err := webhook.NewBitbucketWebhookHandler(reviews, cfg, bitbucketWebhookSecret)
+		if err != nil {
+			log.Fatalf("Failed to create Bitbucket webhook handler: %v", err)
+		}
+		router.POST("/api/bitbucket/webhook", metrics.Webhook(constants.BITBUCKET), tracing.Webhook(constants.BITBUCKET), bitbucketHandler.Handle)
+		log.Println("✅ Bitbucket webhook endpoint (/api/bitbucket/webhook) is active.")
+	}
+
+	// set up the Azure DevOps handler.
+	azureWebhookSecret := os.Getenv("AZURE_DEVOPS_WEBHOOK_SECRET")
+	azureToken := os.Getenv("AZURE_DEVOPS_TOKEN")
+	switch {
+	case azureWebhookSecret == "" && azureToken == "":
+		log.Println("INFO: AZURE_DEVOPS_WEBHOOK_SECRET and AZURE_DEVOPS_TOKEN not found. Skipping Azure DevOps handler setup.")
+	case azureWebhookSecret == "":
+		log.Fatalf("AZURE_DEVOPS_TOKEN is set but AZURE_DEVOPS_WEBHOOK_SECRET is empty, so Azure DevOps webhooks could not be verified.")
+	case azureToken == "":
+		log.Fatalf("AZURE_DEVOPS_WEBHOOK_SECRET is set but AZURE_DEVOPS_TOKEN is empty.")
+	default:
+		log.Println("Azure DevOps credentials found. Initializing Azure DevOps handler...")
+		azureHandler, err := webhook.NewAzureDevOpsWebhookHandler(reviews, cfg, azureWebhookSecret)
+		if err != nil {
+			log.Fatalf("Failed to create Azure DevOps webhook handler: %v", err)
+		}
+		router.POST("/api/azuredevops/webhook", metrics.Webhook(constants.AZUREDEVOPS), tracing.Webhook(constants.AZUREDEVOPS), azureHandler.Handle)
+		log.Println("✅ Azure DevOps webhook endpoint (/api/azuredevops/webhook) is active.")
 	}
 
 	router.GET("/", func(c *gin.Context) {
 		c.String(http.StatusOK, "AI Code Reviewer Bot is running.")
 	})
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	// Handlers are registered by now, so reviews left over from a previous run can resume.
+	reviews.Start(ctx)
+
 	log.Printf("Listening for webhooks on port %s", port)
 	if err := router.Run(":" + port); err != nil {
 		log.Fatalf("Failed to start Gin server: %v", err)
 	}
 }
-
-// initGenkit initializes the Genkit instance and loads the appropriate LLM plugin.
-func initGenkit(ctx context.Context, cfg *config.Config) (*genkit.Genkit, error) {
-	var plugin genkit.Plugin
-	switch cfg.LLM.Provider {
-	case constants.GOOGLEAI:
-		plugin = &googlegenai.GoogleAI{APIKey: cfg.LLM.GoogleAI.APIKey}
-	case constants.OPENAI:
-		plugin = &openai.OpenAI{APIKey: cfg.LLM.OpenAI.APIKey}
-	default:
-		return nil, fmt.Errorf("unsupported LLM provider in config: %s", cfg.LLM.Provider)
-	}
-	return genkit.Init(ctx, genkit.WithPlugins(plugin))
-}
@@ -2,31 +2,53 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 
+	"github.com/surya84/code-reviewer-bot/constants"
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds the application's configuration.
 type Config struct {
-	VCS              VCSConfig `yaml:"vcs"`
-	LLM              LLMConfig `yaml:"llm"`
-	ReviewPromptFile string    `yaml:"review_prompt_file"`
+	VCS              VCSConfig     `yaml:"vcs"`
+	LLM              LLMConfig     `yaml:"llm"`
+	Review           ReviewConfig  `yaml:"review"`
+	Server           ServerConfig  `yaml:"server"`
+	Tracing          TracingConfig `yaml:"tracing"`
+	ReviewPromptFile string        `yaml:"review_prompt_file"`
 	// This now holds the fully assembled prompt after loading.
 	ReviewPrompt string `yaml:"review_prompt"`
+	// SummaryPrompt optionally overrides the built-in template for the PR summary comment.
+	SummaryPrompt string `yaml:"summary_prompt"`
 }
 
 // VCSConfig holds configuration for the version control system.
 type VCSConfig struct {
-	Provider string       `yaml:"provider"`
-	GitHub   GitHubConfig `yaml:"github"`
-	Gitea    GiteaConfig  `yaml:"gitea"`
+	Provider    string            `yaml:"provider"`
+	GitHub      GitHubConfig      `yaml:"github"`
+	Gitea       GiteaConfig       `yaml:"gitea"`
+	GitLab      GitLabConfig      `yaml:"gitlab"`
+	Bitbucket   BitbucketConfig   `yaml:"bitbucket"`
+	AzureDevOps AzureDevOpsConfig `yaml:"azure_devops"`
 }
 
 // GitHubConfig holds GitHub-specific settings.
 type GitHubConfig struct {
 	Token string `yaml:"token"`
+	// BaseURL and UploadURL point the client at GitHub Enterprise Server; empty means github.com.
+	BaseURL   string `yaml:"base_url"`
+	UploadURL string `yaml:"upload_url"`
+	// CABundlePath adds PEM certificates to the trusted roots; ProxyURL overrides HTTP(S)_PROXY.
+	CABundlePath string `yaml:"ca_bundle_path"`
+	ProxyURL     string `yaml:"proxy_url"`
+	// GitHub App authentication, used instead of Token when AppID is set.
+	AppID          int64  `yaml:"app_id"`
+	PrivateKeyPath string `yaml:"private_key_path"`
+	// InstallationID is used when the installation cannot be taken from a webhook event
+	// or looked up from the repository.
+	InstallationID int64 `yaml:"installation_id"`
 }
 
 // GiteaConfig holds Gitea-specific settings.
@@ -35,16 +57,195 @@ type GiteaConfig struct {
 	Token   string `yaml:"token"`
 }
 
+// GitLabConfig holds GitLab-specific settings.
+type GitLabConfig struct {
+	BaseURL string `yaml:"base_url"`
+	Token   string `yaml:"token"`
+}
+
+// BitbucketConfig holds Bitbucket Server / Data Center settings.
+type BitbucketConfig struct {
+	BaseURL string `yaml:"base_url"`
+	Token   string `yaml:"token"` // An HTTP access token with PR write permission.
+}
+
+// AzureDevOpsConfig holds Azure DevOps Repos settings.
+type AzureDevOpsConfig struct {
+	BaseURL string `yaml:"base_url"` // The organization URL, e.g. https://dev.azure.com/my-org.
+	Token   string `yaml:"token"`    // A personal access token with Code (Read & Write) scope.
+}
+
 // LLMConfig holds configuration for the language model.
 type LLMConfig struct {
 	Provider  string `yaml:"provider"`
 	ModelName string `yaml:"model_name"`
-	GoogleAI  struct {
+	// Workers is how many diff chunks are analyzed concurrently; zero means DefaultLLMWorkers.
+	Workers int `yaml:"workers"`
+	// RateLimits caps requests and tokens per minute, keyed by provider name (e.g. "openai").
+	RateLimits map[string]RateLimitConfig `yaml:"rate_limits"`
+	GoogleAI   struct {
 		APIKey string `yaml:"api_key"`
 	} `yaml:"googleai"`
-	OpenAI struct {
-		APIKey string `yaml:"api_key"`
-	} `yaml:"openai"`
+	OpenAI    OpenAIConfig    `yaml:"openai"`
+	Ollama    OllamaConfig    `yaml:"ollama"`
+	Anthropic AnthropicConfig `yaml:"anthropic"`
+	// Fallbacks are tried in order when the model above keeps failing with rate limit, timeout
+	// or server errors, or its provider's circuit is open. Each uses its provider's settings.
+	Fallbacks []ModelRef `yaml:"fallbacks"`
+	// Retry controls how often a failing model is retried before the next one is tried.
+	Retry RetryConfig `yaml:"retry"`
+	// CircuitBreaker skips a provider for a while after repeated failures.
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+}
+
+// ModelRef names a model of a provider, e.g. provider "openai" and model_name "openai/gpt-4o".
+type ModelRef struct {
+	Provider  string `yaml:"provider"`
+	ModelName string `yaml:"model_name"`
+}
+
+// Models returns the configured model followed by its fallbacks, in the order they are tried.
+func (c *LLMConfig) Models() []ModelRef {
+	return append([]ModelRef{{Provider: c.Provider, ModelName: c.ModelName}}, c.Fallbacks...)
+}
+
+// RetryConfig controls the retries of rate limit, timeout and server errors. Zero values mean
+// the defaults below.
+type RetryConfig struct {
+	// MaxAttempts is how many times each model is called before failing over.
+	MaxAttempts int `yaml:"max_attempts"`
+	// BackoffMillis is the delay before the first retry, doubled for each further retry up to
+	// MaxBackoffMillis. Delays are jittered between half and all of that.
+	BackoffMillis    int `yaml:"backoff_ms"`
+	MaxBackoffMillis int `yaml:"max_backoff_ms"`
+}
+
+// CircuitBreakerConfig controls when a provider is skipped. Zero values mean the defaults below.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is how many consecutive rate limit, timeout or server errors open the circuit.
+	FailureThreshold int `yaml:"failure_threshold"`
+	// CooldownSeconds is how long an open circuit skips the provider before it is tried again.
+	CooldownSeconds int `yaml:"cooldown_seconds"`
+}
+
+// Defaults for retrying and skipping failing models.
+const (
+	DefaultLLMMaxAttempts      = 3
+	DefaultLLMBackoffMillis    = 1000
+	DefaultLLMMaxBackoffMillis = 30000
+	DefaultFailureThreshold    = 5
+	DefaultCooldownSeconds     = 60
+)
+
+// OpenAIConfig holds the settings for OpenAI and OpenAI-compatible endpoints such as vLLM,
+// LiteLLM, Azure OpenAI or OpenRouter.
+type OpenAIConfig struct {
+	// APIKey is sent as a bearer token; empty falls back to OPENAI_API_KEY, and gateways that
+	// authenticate differently can leave both unset and use Headers.
+	APIKey string `yaml:"api_key"`
+	// BaseURL is the API root the chat completions path is appended to, e.g.
+	// "http://vllm:8000/v1"; empty means api.openai.com.
+	BaseURL string `yaml:"base_url"`
+	// Model is the model ID sent to the endpoint, e.g. "meta-llama/Llama-3.1-8B-Instruct". It
+	// defaults to model_name without the "openai/" prefix, and model_name defaults to
+	// "openai/" plus Model.
+	Model string `yaml:"model"`
+	// Headers are added to every request, e.g. {"api-key": ...} for Azure OpenAI.
+	Headers map[string]string `yaml:"headers"`
+	// APIVersion is sent as the api-version query parameter that Azure OpenAI requires.
+	APIVersion   string `yaml:"api_version"`
+	Organization string `yaml:"organization"`
+	// TimeoutSeconds bounds each request attempt; zero means DefaultOpenAITimeoutSeconds.
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+}
+
+// DefaultOpenAITimeoutSeconds is the OpenAI request timeout when llm.openai.timeout_seconds is not set.
+const DefaultOpenAITimeoutSeconds = 120
+
+// OllamaConfig holds the settings for models served by an Ollama server.
+type OllamaConfig struct {
+	// ServerAddress is the Ollama server URL; empty means DefaultOllamaServerAddress.
+	ServerAddress string `yaml:"server_address"`
+	// Model is the model tag to run, e.g. "qwen2.5-coder:14b". It defaults to model_name
+	// without the "ollama/" prefix, and model_name defaults to "ollama/" plus Model.
+	Model string `yaml:"model"`
+	// Options are passed to Ollama unchanged, e.g. temperature or num_ctx.
+	Options map[string]any `yaml:"options"`
+	// TimeoutSeconds bounds each request; zero means DefaultOllamaTimeoutSeconds.
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+}
+
+// Defaults for the Ollama provider. Local models can be slow, so the timeout is generous.
+const (
+	DefaultOllamaServerAddress  = "http://localhost:11434"
+	DefaultOllamaTimeoutSeconds = 300
+)
+
+// AnthropicConfig holds the settings for Claude models served by the Anthropic Messages API.
+type AnthropicConfig struct {
+	APIKey string `yaml:"api_key"`
+	// BaseURL is the API root, e.g. a proxy in front of the API; empty means DefaultAnthropicBaseURL.
+	BaseURL string `yaml:"base_url"`
+	// Model is the model ID, e.g. "claude-sonnet-4-5". It defaults to model_name without the
+	// "anthropic/" prefix, and model_name defaults to "anthropic/" plus Model.
+	Model string `yaml:"model"`
+	// MaxTokens caps each response, which the API requires; zero means DefaultAnthropicMaxTokens.
+	MaxTokens int `yaml:"max_tokens"`
+	// TimeoutSeconds bounds each request; zero means DefaultAnthropicTimeoutSeconds.
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+}
+
+// Defaults for the Anthropic provider.
+const (
+	DefaultAnthropicBaseURL        = "https://api.anthropic.com"
+	DefaultAnthropicMaxTokens      = 4096
+	DefaultAnthropicTimeoutSeconds = 120
+)
+
+// ReviewConfig controls which LLM findings are posted.
+type ReviewConfig struct {
+	// MinConfidence drops comments the model is less sure about (0-1); 0 keeps everything.
+	MinConfidence float64 `yaml:"min_confidence"`
+	// MinSeverity drops comments below blocker, major, minor or nit; empty keeps everything.
+	MinSeverity string `yaml:"min_severity"`
+}
+
+// ServerConfig holds settings for the webhook server.
+type ServerConfig struct {
+	// DebounceSeconds delays each review so a burst of pushes to the same PR triggers only one.
+	DebounceSeconds int `yaml:"debounce_seconds"`
+	// QueuePath is the file that stores queued reviews across restarts; empty means DefaultQueuePath.
+	QueuePath string `yaml:"queue_path"`
+	// Workers is how many reviews run at once, and MaxAttempts how often a failing review is
+	// tried before it is given up; zero means the queue's defaults.
+	Workers     int `yaml:"workers"`
+	MaxAttempts int `yaml:"max_attempts"`
+	// DeliveryTTLMinutes is how long webhook delivery IDs are remembered to reject duplicates;
+	// zero means a day. DeliveryCachePath keeps them across restarts; empty keeps them in memory.
+	DeliveryTTLMinutes int    `yaml:"delivery_ttl_minutes"`
+	DeliveryCachePath  string `yaml:"delivery_cache_path"`
+}
+
+// TracingConfig holds the OpenTelemetry trace export settings.
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP collector URL, e.g. "http://localhost:4318"; empty disables export.
+	Endpoint string `yaml:"endpoint"`
+	// SampleRatio is the fraction of traces recorded, between 0 and 1; zero means every trace.
+	SampleRatio float64 `yaml:"sample_ratio"`
+	// ServiceName identifies the bot in traces; empty means "code-reviewer-bot".
+	ServiceName string `yaml:"service_name"`
+}
+
+// DefaultQueuePath is the review queue file used when server.queue_path is not set.
+const DefaultQueuePath = "review-queue.db"
+
+// DefaultLLMWorkers is the number of concurrent chunk analyses when llm.workers is not set.
+const DefaultLLMWorkers = 4
+
+// RateLimitConfig limits how fast a provider is called. Zero values mean no limit.
+type RateLimitConfig struct {
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+	TokensPerMinute   int `yaml:"tokens_per_minute"`
 }
 
 // LoadConfig reads the configuration, loads the base prompt from a file,
@@ -63,6 +264,70 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	switch cfg.LLM.Provider {
+	case constants.OPENAI:
+		if err := resolveModel(constants.OPENAI, &cfg.LLM.ModelName, &cfg.LLM.OpenAI.Model); err != nil {
+			return nil, err
+		}
+		if cfg.LLM.OpenAI.BaseURL != "" {
+			if u, err := url.Parse(cfg.LLM.OpenAI.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+				return nil, fmt.Errorf("'llm.openai.base_url' must be an absolute URL, got '%s'", cfg.LLM.OpenAI.BaseURL)
+			}
+		}
+		if cfg.LLM.OpenAI.TimeoutSeconds < 0 {
+			return nil, fmt.Errorf("'llm.openai.timeout_seconds' must not be negative, got %d", cfg.LLM.OpenAI.TimeoutSeconds)
+		}
+	case constants.ANTHROPIC:
+		if err := resolveModel(constants.ANTHROPIC, &cfg.LLM.ModelName, &cfg.LLM.Anthropic.Model); err != nil {
+			return nil, err
+		}
+		if cfg.LLM.Anthropic.MaxTokens < 0 || cfg.LLM.Anthropic.TimeoutSeconds < 0 {
+			return nil, fmt.Errorf("'llm.anthropic.max_tokens' and 'llm.anthropic.timeout_seconds' must not be negative")
+		}
+	case constants.OLLAMA:
+		if err := resolveModel(constants.OLLAMA, &cfg.LLM.ModelName, &cfg.LLM.Ollama.Model); err != nil {
+			return nil, err
+		}
+		if cfg.LLM.Ollama.TimeoutSeconds < 0 {
+			return nil, fmt.Errorf("'llm.ollama.timeout_seconds' must not be negative, got %d", cfg.LLM.Ollama.TimeoutSeconds)
+		}
+	}
+
+	for i, m := range cfg.LLM.Fallbacks {
+		if m.Provider == "" || !strings.HasPrefix(m.ModelName, m.Provider+"/") || m.ModelName == m.Provider+"/" {
+			return nil, fmt.Errorf("'llm.fallbacks[%d]' needs a provider and a model_name of the form '<provider>/<model>'", i)
+		}
+	}
+	if cfg.LLM.Retry.MaxAttempts < 0 || cfg.LLM.Retry.BackoffMillis < 0 || cfg.LLM.Retry.MaxBackoffMillis < 0 {
+		return nil, fmt.Errorf("'llm.retry' settings must not be negative")
+	}
+	if cfg.LLM.CircuitBreaker.FailureThreshold < 0 || cfg.LLM.CircuitBreaker.CooldownSeconds < 0 {
+		return nil, fmt.Errorf("'llm.circuit_breaker' settings must not be negative")
+	}
+
+	if cfg.Review.MinConfidence < 0 || cfg.Review.MinConfidence > 1 {
+		return nil, fmt.Errorf("'review.min_confidence' must be between 0 and 1, got %v", cfg.Review.MinConfidence)
+	}
+	switch cfg.Review.MinSeverity {
+	case "", constants.SEVERITY_BLOCKER, constants.SEVERITY_MAJOR, constants.SEVERITY_MINOR, constants.SEVERITY_NIT:
+	default:
+		return nil, fmt.Errorf("'review.min_severity' must be one of blocker, major, minor or nit, got '%s'", cfg.Review.MinSeverity)
+	}
+
+	if cfg.Server.DebounceSeconds < 0 {
+		return nil, fmt.Errorf("'server.debounce_seconds' must not be negative, got %d", cfg.Server.DebounceSeconds)
+	}
+	if cfg.Server.Workers < 0 || cfg.Server.MaxAttempts < 0 {
+		return nil, fmt.Errorf("'server.workers' and 'server.max_attempts' must not be negative")
+	}
+	if cfg.Server.DeliveryTTLMinutes < 0 {
+		return nil, fmt.Errorf("'server.delivery_ttl_minutes' must not be negative, got %d", cfg.Server.DeliveryTTLMinutes)
+	}
+
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		return nil, fmt.Errorf("'tracing.sample_ratio' must be between 0 and 1, got %v", cfg.Tracing.SampleRatio)
+	}
+
 	// Check if a prompt file is specified.
 	if cfg.ReviewPromptFile == "" {
 		return nil, fmt.Errorf("'review_prompt_file' must be specified in config.yaml")
@@ -86,3 +351,23 @@ func LoadConfig(path string) (*Config, error) {
 
 	return &cfg, nil
 }
+
+// resolveModel fills in whichever of model_name and the provider's model setting is missing.
+// Genkit resolves model_name by splitting it at the first slash, so it must be the provider
+// followed by the model ID, which may contain slashes itself: "openai/meta-llama/Llama-3.1-8B".
+func resolveModel(provider string, modelName, model *string) error {
+	prefix := provider + "/"
+	if *model == "" {
+		*model = strings.TrimPrefix(*modelName, prefix)
+	}
+	if *modelName == "" {
+		*modelName = prefix + *model
+	}
+	if *model == "" {
+		return fmt.Errorf("'llm.%s.model' or 'llm.model_name' must be set for the %s provider", provider, provider)
+	}
+	if *modelName != prefix+*model {
+		return fmt.Errorf("'llm.model_name' must be '%s%s' to use 'llm.%s.model', got '%s'", prefix, *model, provider, *modelName)
+	}
+	return nil
+}
@@ -2,17 +2,49 @@ package constants
 
 // Add your variable declarations here, or remove this block if not needed.
 const (
-	OPENED            string = "opened"
-	SYNCHRONIZE       string = "synchronize"
-	REOPENED          string = "reopened"
-	GITHUB            string = "github"
-	PR_NUMBER         string = "PR_NUMBER"
-	REPO_OWNER        string = "REPO_OWNER"
-	REPO_NAME         string = "REPO_NAME"
-	GITHUB_REPOSITORY string = "GITHUB_REPOSITORY"
-	GITEA             string = "gitea"
-	OPEN              string = "open"
-	REVIEW_PROMPT     string = "review_prompt"
-	GOOGLEAI          string = "googleai"
-	OPENAI            string = "openai"
+	OPENED              string = "opened"
+	SYNCHRONIZE         string = "synchronize"
+	REOPENED            string = "reopened"
+	GITHUB              string = "github"
+	PR_NUMBER           string = "PR_NUMBER"
+	REPO_OWNER          string = "REPO_OWNER"
+	REPO_NAME           string = "REPO_NAME"
+	GITHUB_REPOSITORY   string = "GITHUB_REPOSITORY"
+	GITEA               string = "gitea"
+	OPEN                string = "open"
+	REVIEW_PROMPT       string = "review_prompt"
+	GOOGLEAI            string = "googleai"
+	OPENAI              string = "openai"
+	GITLAB              string = "gitlab"
+	REOPEN              string = "reopen"
+	UPDATE              string = "update"
+	MERGE_REQUEST       string = "merge_request"
+	BITBUCKET           string = "bitbucket"
+	PR_OPENED           string = "pr:opened"
+	PR_FROM_REF_UPDATED string = "pr:from_ref_updated"
+	STATE_OPEN          string = "OPEN"
+	AZUREDEVOPS         string = "azuredevops"
+	PR_CREATED_EVENT    string = "git.pullrequest.created"
+	PR_UPDATED_EVENT    string = "git.pullrequest.updated"
+	ACTIVE              string = "active"
+	OLLAMA              string = "ollama"
+	ANTHROPIC           string = "anthropic"
+)
+
+// Severities and categories the LLM assigns to review comments, from most to least severe,
+// and the risk ratings used in the PR summary.
+const (
+	SEVERITY_BLOCKER     string = "blocker"
+	SEVERITY_MAJOR       string = "major"
+	SEVERITY_MINOR       string = "minor"
+	SEVERITY_NIT         string = "nit"
+	CATEGORY_BUG         string = "bug"
+	CATEGORY_SECURITY    string = "security"
+	CATEGORY_PERFORMANCE string = "performance"
+	CATEGORY_STYLE       string = "style"
+	CATEGORY_NAMING      string = "naming"
+	CATEGORY_DOCS        string = "docs"
+	RISK_LOW             string = "low"
+	RISK_MEDIUM          string = "medium"
+	RISK_HIGH            string = "high"
 )
@@ -1,74 +1,477 @@
 package diffparser
 
 import (
+	"regexp"
 	"strconv"
 	"strings"
 )
 
+// FileStatus describes how a file changed in a diff.
+type FileStatus string
+
+const (
+	StatusModified FileStatus = "modified"
+	StatusAdded    FileStatus = "added"
+	StatusDeleted  FileStatus = "deleted"
+	StatusRenamed  FileStatus = "renamed"
+	StatusCopied   FileStatus = "copied"
+)
+
+// LineKind identifies the role of a line inside a hunk.
+type LineKind byte
+
+const (
+	LineContext LineKind = ' '
+	LineAdded   LineKind = '+'
+	LineDeleted LineKind = '-'
+)
+
+// FileDiff holds the metadata and hunks for a single file in a diff.
+type FileDiff struct {
+	OldPath string // Empty for added files.
+	NewPath string // Empty for deleted files.
+	Status  FileStatus
+	Binary  bool
+	OldMode string // Set when the diff records the file mode, e.g. "100644".
+	NewMode string
+	Chunks  []*DiffChunk
+}
+
+// Path returns the path a reviewer should use for the file: the new path, or the old one if it was deleted.
+func (f *FileDiff) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// Stats counts the lines the file's hunks add and remove.
+func (f *FileDiff) Stats() (added, deleted int) {
+	for _, chunk := range f.Chunks {
+		for _, l := range chunk.Lines {
+			switch l.Kind {
+			case LineAdded:
+				added++
+			case LineDeleted:
+				deleted++
+			}
+		}
+	}
+	return added, deleted
+}
+
+// DiffLine is a single body line of a hunk.
+type DiffLine struct {
+	Kind    LineKind
+	Content string // The line without its leading marker.
+	OldLine int    // Line number in the old file; 0 for added lines.
+	NewLine int    // Line number in the new file; 0 for deleted lines.
+	// Position is the 1-based offset of the line below the file's first "@@" header,
+	// counting later hunk headers, as used by GitHub's review comment API.
+	Position int
+	// NoNewlineAtEOF reports that the line was followed by "\ No newline at end of file".
+	NoNewlineAtEOF bool
+}
+
 // DiffChunk represents a single hunk of a diff for a single file.
 type DiffChunk struct {
 	FilePath     string
-	CodeSnippet  string
-	StartLineNew int // The starting line number of this hunk in the new file.
+	CodeSnippet  string // The raw hunk text, starting with its "@@" header line.
+	StartLineNew int    // The starting line number of this hunk in the new file.
+	StartLineOld int    // The starting line number of this hunk in the old file.
+	Lines        []DiffLine
+	File         *FileDiff
+}
+
+// HasChanges reports whether the hunk adds or removes any lines.
+func (c *DiffChunk) HasChanges() bool {
+	for _, l := range c.Lines {
+		if l.Kind != LineContext {
+			return true
+		}
+	}
+	return false
+}
+
+// NewLineRange returns the first and last new-file lines the hunk covers.
+func (c *DiffChunk) NewLineRange() (start, end int) {
+	end = c.StartLineNew
+	for _, l := range c.Lines {
+		end = max(end, l.NewLine)
+	}
+	return c.StartLineNew, end
 }
 
-// Parse takes a raw diff string and splits it into analyzable hunks.
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Parse takes a raw diff string and splits it into analyzable hunks,
+// skipping binary files and hunks without additions or removals.
 func Parse(diffStr string) []*DiffChunk {
-	var chunks []*DiffChunk
+	return Chunks(ParseFiles(diffStr))
+}
 
-	files := strings.Split(diffStr, "diff --git a/")
+// Chunks returns the analyzable hunks of parsed files, skipping binary files and
+// hunks without additions or removals.
+func Chunks(files []*FileDiff) []*DiffChunk {
+	var chunks []*DiffChunk
 	for _, file := range files {
-		if strings.TrimSpace(file) == "" {
+		if file.Binary {
 			continue
 		}
+		for _, chunk := range file.Chunks {
+			if chunk.HasChanges() {
+				chunks = append(chunks, chunk)
+			}
+		}
+	}
+	return chunks
+}
 
-		parts := strings.SplitN(file, "\n", 2)
-		if len(parts) < 2 {
-			continue
+// ParseFiles parses a unified diff into per-file records. It understands git's extended
+// headers (renames, copies, mode changes, binary markers, quoted paths) as well as plain
+// "diff -u" output, and ignores surrounding text such as "git format-patch" email headers.
+func ParseFiles(diffStr string) []*FileDiff {
+	p := &parser{}
+	lines := strings.Split(strings.ReplaceAll(diffStr, "\r\n", "\n"), "\n")
+	for _, line := range lines {
+		p.feed(line)
+	}
+	p.finishHunk()
+	p.finishFile()
+	return p.files
+}
+
+// parser is a line-driven state machine. Between hunks it interprets header lines;
+// inside a hunk it consumes exactly the number of lines announced by the "@@" header,
+// so body lines that look like headers are never misread.
+type parser struct {
+	files []*FileDiff
+	file  *FileDiff
+	// gitHeader is true while reading the extended header block after "diff --git".
+	gitHeader bool
+	// skipBinaryPatch is true while skipping the payload of a "GIT binary patch".
+	skipBinaryPatch bool
+
+	hunk     *DiffChunk
+	snippet  []string
+	oldLeft  int
+	newLeft  int
+	oldLine  int
+	newLine  int
+	position int
+	sawHunk  bool
+}
+
+func (p *parser) feed(line string) {
+	if p.hunk != nil {
+		if p.feedHunkLine(line) {
+			return
 		}
+		p.finishHunk()
+	}
 
-		headerParts := strings.Fields(parts[0])
-		if len(headerParts) < 2 {
-			continue
+	switch {
+	case strings.HasPrefix(line, "diff --git "):
+		p.startFile()
+		p.gitHeader = true
+		p.file.OldPath, p.file.NewPath = parseGitHeaderPaths(strings.TrimPrefix(line, "diff --git "))
+	case p.skipBinaryPatch:
+		// The binary payload runs until the next file header.
+	case strings.HasPrefix(line, "@@ "):
+		p.startHunk(line)
+	case p.gitHeader && p.feedExtendedHeader(line):
+	case strings.HasPrefix(line, "--- "):
+		// Plain unified diffs have no "diff --git" line; "---" starts the next file.
+		if p.file == nil || !p.gitHeader {
+			p.startFile()
 		}
-		filePath := strings.TrimPrefix(headerParts[1], "b/")
-		content := parts[1]
+		p.file.OldPath = parseFilePath(strings.TrimPrefix(line, "--- "), "a/")
+	case strings.HasPrefix(line, "+++ ") && p.file != nil:
+		p.file.NewPath = parseFilePath(strings.TrimPrefix(line, "+++ "), "b/")
+	}
+}
 
-		// Split the content by hunk headers.
-		hunks := strings.Split(content, "\n@@")
-		for i, hunk := range hunks {
-			if i == 0 { // Skip file mode info before the first hunk.
-				continue
-			}
+// feedExtendedHeader handles git's header lines between "diff --git" and the first hunk.
+func (p *parser) feedExtendedHeader(line string) bool {
+	f := p.file
+	switch {
+	case strings.HasPrefix(line, "new file mode "):
+		f.Status = StatusAdded
+		f.NewMode = strings.TrimPrefix(line, "new file mode ")
+	case strings.HasPrefix(line, "deleted file mode "):
+		f.Status = StatusDeleted
+		f.OldMode = strings.TrimPrefix(line, "deleted file mode ")
+	case strings.HasPrefix(line, "old mode "):
+		f.OldMode = strings.TrimPrefix(line, "old mode ")
+	case strings.HasPrefix(line, "new mode "):
+		f.NewMode = strings.TrimPrefix(line, "new mode ")
+	case strings.HasPrefix(line, "rename from "):
+		f.Status = StatusRenamed
+		f.OldPath = unquotePath(strings.TrimPrefix(line, "rename from "))
+	case strings.HasPrefix(line, "rename to "):
+		f.Status = StatusRenamed
+		f.NewPath = unquotePath(strings.TrimPrefix(line, "rename to "))
+	case strings.HasPrefix(line, "copy from "):
+		f.Status = StatusCopied
+		f.OldPath = unquotePath(strings.TrimPrefix(line, "copy from "))
+	case strings.HasPrefix(line, "copy to "):
+		f.Status = StatusCopied
+		f.NewPath = unquotePath(strings.TrimPrefix(line, "copy to "))
+	case strings.HasPrefix(line, "index "):
+		// "index <old>..<new> <mode>" carries the mode when it did not change.
+		if fields := strings.Fields(line); len(fields) == 3 && f.OldMode == "" && f.NewMode == "" {
+			f.OldMode, f.NewMode = fields[2], fields[2]
+		}
+	case strings.HasPrefix(line, "similarity index "), strings.HasPrefix(line, "dissimilarity index "):
+	case strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ"):
+		f.Binary = true
+	case line == "GIT binary patch":
+		f.Binary = true
+		p.skipBinaryPatch = true
+	default:
+		return false
+	}
+	return true
+}
 
-			// Extract the starting line number for the new file.
-			hunkHeaderEnd := strings.Index(hunk, "@@")
-			if hunkHeaderEnd == -1 {
-				continue
-			}
-			headerLine := hunk[:hunkHeaderEnd] // e.g., " -10,6 +10,7 "
-			headerParts := strings.Fields(headerLine)
-			var startLine int
-			if len(headerParts) > 1 {
-				// The new file info is the second part, e.g., "+10,7"
-				lineInfo := strings.Split(strings.TrimPrefix(headerParts[1], "+"), ",")
-				startLine, _ = strconv.Atoi(lineInfo[0])
-			}
+// feedHunkLine consumes a hunk body line and reports whether it belonged to the hunk.
+func (p *parser) feedHunkLine(line string) bool {
+	if strings.HasPrefix(line, `\`) {
+		// "\ No newline at end of file" applies to the previous line but still occupies a diff position.
+		if n := len(p.hunk.Lines); n > 0 {
+			p.hunk.Lines[n-1].NoNewlineAtEOF = true
+		}
+		p.snippet = append(p.snippet, line)
+		p.position++
+		return true
+	}
+	if p.oldLeft <= 0 && p.newLeft <= 0 {
+		return false
+	}
 
-			// Re-add the separator for the snippet.
-			codeSnippet := "@@" + hunk
+	// Some tools strip the trailing space from empty context lines, leaving a bare "".
+	kind := LineContext
+	content := line
+	if line != "" {
+		kind = LineKind(line[0])
+		content = line[1:]
+	}
+
+	dl := DiffLine{Kind: kind, Content: content}
+	switch kind {
+	case LineContext:
+		if p.oldLeft <= 0 || p.newLeft <= 0 {
+			return false
+		}
+		dl.OldLine, dl.NewLine = p.oldLine, p.newLine
+		p.oldLine++
+		p.newLine++
+		p.oldLeft--
+		p.newLeft--
+	case LineDeleted:
+		if p.oldLeft <= 0 {
+			return false
+		}
+		dl.OldLine = p.oldLine
+		p.oldLine++
+		p.oldLeft--
+	case LineAdded:
+		if p.newLeft <= 0 {
+			return false
+		}
+		dl.NewLine = p.newLine
+		p.newLine++
+		p.newLeft--
+	default:
+		return false
+	}
+
+	p.position++
+	dl.Position = p.position
+	p.hunk.Lines = append(p.hunk.Lines, dl)
+	p.snippet = append(p.snippet, line)
+	return true
+}
+
+func (p *parser) startFile() {
+	p.finishFile()
+	p.file = &FileDiff{Status: StatusModified}
+	p.gitHeader = false
+	p.skipBinaryPatch = false
+	p.sawHunk = false
+	p.position = 0
+}
+
+func (p *parser) finishFile() {
+	if p.file == nil {
+		return
+	}
+	f := p.file
+	switch {
+	case f.OldPath == "" && f.NewPath != "":
+		f.Status = StatusAdded
+	case f.NewPath == "" && f.OldPath != "":
+		f.Status = StatusDeleted
+	}
+	if f.Status == StatusAdded {
+		f.OldPath = ""
+	}
+	if f.Status == StatusDeleted {
+		f.NewPath = ""
+	}
+	for _, chunk := range f.Chunks {
+		chunk.FilePath = f.Path()
+	}
+	p.files = append(p.files, f)
+	p.file = nil
+}
+
+func (p *parser) startHunk(header string) {
+	m := hunkHeaderRe.FindStringSubmatch(header)
+	if m == nil || p.file == nil {
+		return
+	}
+	oldStart, _ := strconv.Atoi(m[1])
+	oldCount := atoiDefault(m[2], 1)
+	newStart, _ := strconv.Atoi(m[3])
+	newCount := atoiDefault(m[4], 1)
+
+	p.gitHeader = false
+	p.hunk = &DiffChunk{StartLineOld: oldStart, StartLineNew: newStart, File: p.file}
+	p.snippet = []string{header}
+	p.oldLeft, p.newLeft = oldCount, newCount
+	p.oldLine, p.newLine = oldStart, newStart
+	// Every hunk header after the first one in a file also takes up a position.
+	if p.sawHunk {
+		p.position++
+	}
+	p.sawHunk = true
+}
+
+func (p *parser) finishHunk() {
+	if p.hunk == nil {
+		return
+	}
+	p.hunk.CodeSnippet = strings.Join(p.snippet, "\n")
+	p.file.Chunks = append(p.file.Chunks, p.hunk)
+	p.hunk = nil
+	p.snippet = nil
+}
+
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// parseGitHeaderPaths extracts the old and new paths from the remainder of a "diff --git" line.
+// Unquoted paths may contain spaces, so when both sides are unquoted the line is split where
+// the two paths are equal; renames are corrected later by the "rename"/"---"/"+++" lines.
+func parseGitHeaderPaths(rest string) (string, string) {
+	if strings.HasPrefix(rest, `"`) {
+		oldPath, remainder, ok := cutQuoted(rest)
+		if !ok {
+			return "", ""
+		}
+		return strings.TrimPrefix(oldPath, "a/"), parseFilePath(strings.TrimPrefix(remainder, " "), "b/")
+	}
+	if strings.HasSuffix(rest, `"`) {
+		if i := strings.Index(rest, ` "`); i != -1 {
+			return strings.TrimPrefix(rest[:i], "a/"), parseFilePath(rest[i+1:], "b/")
+		}
+	}
+	if strings.HasPrefix(rest, "a/") && (len(rest)-5)%2 == 0 && len(rest) > 5 {
+		n := (len(rest) - 5) / 2
+		path := rest[2 : 2+n]
+		if rest[2+n:] == " b/"+path {
+			return path, path
+		}
+	}
+	if i := strings.Index(rest, " b/"); i != -1 {
+		return strings.TrimPrefix(rest[:i], "a/"), rest[i+3:]
+	}
+	return "", ""
+}
 
-			// Only create a chunk if it has actual changes.
-			if strings.Contains(hunk, "\n+") || strings.Contains(hunk, "\n-") {
-				chunk := &DiffChunk{
-					FilePath:     filePath,
-					CodeSnippet:  codeSnippet,
-					StartLineNew: startLine,
+// parseFilePath parses the path from a "---"/"+++" line, handling quoting, /dev/null,
+// trailing timestamps from "diff -u", and the git "a/" or "b/" prefix.
+func parseFilePath(s, prefix string) string {
+	if strings.HasPrefix(s, `"`) {
+		path, _, ok := cutQuoted(s)
+		if !ok {
+			return ""
+		}
+		return strings.TrimPrefix(path, prefix)
+	}
+	if i := strings.IndexByte(s, '\t'); i != -1 {
+		s = s[:i]
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// cutQuoted unquotes a leading C-style quoted string and returns it with the remaining text.
+func cutQuoted(s string) (string, string, bool) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return unquotePath(s[:i+1]), s[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// unquotePath decodes git's C-style quoting ("\t", "\"", "\\" and octal byte escapes).
+// Unquoted input is returned unchanged.
+func unquotePath(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 >= len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '0', '1', '2', '3':
+			if i+2 < len(s) {
+				if n, err := strconv.ParseUint(s[i:i+3], 8, 8); err == nil {
+					b.WriteByte(byte(n))
+					i += 2
+					continue
 				}
-				chunks = append(chunks, chunk)
 			}
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(s[i])
 		}
 	}
-
-	return chunks
+	return b.String()
 }
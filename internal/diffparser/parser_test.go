@@ -1,9 +1,13 @@
 package diffparser
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParse(t *testing.T) {
@@ -32,3 +36,175 @@ index abc..def 100644
 	assert.Equal(t, "README.md", chunks[1].FilePath)
 	assert.Equal(t, 1, chunks[1].StartLineNew)
 }
+
+// expectedFile describes the parsed metadata of one file in a test diff.
+type expectedFile struct {
+	oldPath, newPath string
+	status           FileStatus
+	binary           bool
+	oldMode, newMode string
+	hunks            int
+}
+
+func TestParseFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		want  []expectedFile
+		lines []DiffLine // When set, the line records of the first hunk.
+	}{
+		{
+			name: "added file",
+			file: "added_file.diff",
+			want: []expectedFile{{newPath: "added.go", status: StatusAdded, newMode: "100644", hunks: 1}},
+			lines: []DiffLine{
+				{Kind: LineAdded, Content: "new file", NewLine: 1, Position: 1},
+			},
+		},
+		{
+			name: "deleted file",
+			file: "deleted_file.diff",
+			want: []expectedFile{{oldPath: "gone.txt", status: StatusDeleted, oldMode: "100644", hunks: 1}},
+			lines: []DiffLine{
+				{Kind: LineDeleted, Content: "line1", OldLine: 1, Position: 1},
+				{Kind: LineDeleted, Content: "line2", OldLine: 2, Position: 2},
+				{Kind: LineDeleted, Content: "line3", OldLine: 3, Position: 3},
+			},
+		},
+		{
+			name: "binary file",
+			file: "binary_file.diff",
+			want: []expectedFile{{newPath: "blob.bin", status: StatusAdded, binary: true, newMode: "100644"}},
+		},
+		{
+			name: "pure rename",
+			file: "rename_only.diff",
+			want: []expectedFile{{oldPath: "moved.txt", newPath: "dir/moved.txt", status: StatusRenamed}},
+		},
+		{
+			name: "rename with changes",
+			file: "rename_with_changes.diff",
+			want: []expectedFile{{oldPath: "old_name.txt", newPath: "new_name.txt", status: StatusRenamed, oldMode: "100644", newMode: "100644", hunks: 1}},
+			lines: []DiffLine{
+				{Kind: LineContext, Content: "four", OldLine: 4, NewLine: 4, Position: 1},
+				{Kind: LineContext, Content: "five", OldLine: 5, NewLine: 5, Position: 2},
+				{Kind: LineContext, Content: "six", OldLine: 6, NewLine: 6, Position: 3},
+				{Kind: LineDeleted, Content: "seven", OldLine: 7, Position: 4},
+				{Kind: LineAdded, Content: "SEVEN", NewLine: 7, Position: 5},
+			},
+		},
+		{
+			name: "mode-only change",
+			file: "mode_change.diff",
+			want: []expectedFile{{oldPath: "script.sh", newPath: "script.sh", status: StatusModified, oldMode: "100644", newMode: "100755"}},
+		},
+		{
+			name: "no newline at end of file",
+			file: "no_newline_at_eof.diff",
+			want: []expectedFile{{oldPath: "eof.txt", newPath: "eof.txt", status: StatusModified, oldMode: "100644", newMode: "100644", hunks: 1}},
+			lines: []DiffLine{
+				{Kind: LineDeleted, Content: "no newline", OldLine: 1, Position: 1, NoNewlineAtEOF: true},
+				{Kind: LineAdded, Content: "no newline", NewLine: 1, Position: 3},
+				{Kind: LineAdded, Content: "now has more", NewLine: 2, Position: 4, NoNewlineAtEOF: true},
+			},
+		},
+		{
+			name: "quoted path",
+			file: "quoted_path.diff",
+			want: []expectedFile{{oldPath: "tab\tname.txt", newPath: "tab\tname.txt", status: StatusModified, oldMode: "100644", newMode: "100644", hunks: 1}},
+		},
+		{
+			name: "path with spaces",
+			file: "path_with_spaces.diff",
+			want: []expectedFile{{oldPath: "with space.txt", newPath: "with space.txt", status: StatusModified, oldMode: "100644", newMode: "100644", hunks: 1}},
+		},
+		{
+			name: "content lines that look like headers",
+			file: "header_like_content.diff",
+			want: []expectedFile{{oldPath: "tricky.txt", newPath: "tricky.txt", status: StatusModified, oldMode: "100644", newMode: "100644", hunks: 1}},
+			lines: []DiffLine{
+				{Kind: LineContext, Content: "first", OldLine: 1, NewLine: 1, Position: 1},
+				{Kind: LineDeleted, Content: "-- foo", OldLine: 2, Position: 2},
+				{Kind: LineAdded, Content: "++ bar", NewLine: 2, Position: 3},
+				{Kind: LineAdded, Content: "@@ -1 +1 @@", NewLine: 3, Position: 4},
+				{Kind: LineContext, Content: "last", OldLine: 3, NewLine: 4, Position: 5},
+			},
+		},
+		{
+			name: "multiple hunks",
+			file: "multiple_hunks.diff",
+			want: []expectedFile{{oldPath: "multi.go", newPath: "multi.go", status: StatusModified, oldMode: "100644", newMode: "100644", hunks: 2}},
+		},
+		{
+			name: "format-patch with binary patch and signature",
+			file: "format_patch.patch",
+			want: []expectedFile{
+				{newPath: "logo.png", status: StatusAdded, binary: true, newMode: "100644"},
+				{oldPath: "main.go", newPath: "main.go", status: StatusModified, oldMode: "100644", newMode: "100644", hunks: 1},
+			},
+		},
+		{
+			name: "plain unified diff",
+			file: "plain_unified.diff",
+			want: []expectedFile{{oldPath: "config.yaml.orig", newPath: "config.yaml", status: StatusModified, hunks: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			require.NoError(t, err)
+
+			files := ParseFiles(string(data))
+			require.Len(t, files, len(tt.want))
+			for i, want := range tt.want {
+				got := files[i]
+				assert.Equal(t, want.oldPath, got.OldPath, "old path")
+				assert.Equal(t, want.newPath, got.NewPath, "new path")
+				assert.Equal(t, want.status, got.Status, "status")
+				assert.Equal(t, want.binary, got.Binary, "binary")
+				assert.Equal(t, want.oldMode, got.OldMode, "old mode")
+				assert.Equal(t, want.newMode, got.NewMode, "new mode")
+				assert.Len(t, got.Chunks, want.hunks, "hunks")
+				for _, chunk := range got.Chunks {
+					assert.Equal(t, got.Path(), chunk.FilePath)
+					assert.Same(t, got, chunk.File)
+				}
+			}
+			if tt.lines != nil {
+				assert.Equal(t, tt.lines, files[0].Chunks[0].Lines)
+			}
+		})
+	}
+}
+
+func TestParse_MultipleHunkPositions(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "multiple_hunks.diff"))
+	require.NoError(t, err)
+
+	chunks := Parse(string(data))
+	require.Len(t, chunks, 2)
+
+	// The first hunk has 9 lines; the second hunk's header takes position 10.
+	assert.Equal(t, 13, chunks[1].StartLineOld)
+	assert.Equal(t, 15, chunks[1].StartLineNew)
+	added := chunks[1].Lines[3]
+	assert.Equal(t, LineAdded, added.Kind)
+	assert.Equal(t, "\tfmt.Println(\"e\")", added.Content)
+	assert.Equal(t, 18, added.NewLine)
+	assert.Equal(t, 14, added.Position)
+	assert.True(t, strings.HasPrefix(chunks[1].CodeSnippet, "@@ -13,5 +15,6 @@ func c() {}\n"))
+}
+
+func TestParse_SkipsBinaryAndMetadataOnlyFiles(t *testing.T) {
+	var corpus strings.Builder
+	for _, name := range []string{"binary_file.diff", "rename_only.diff", "mode_change.diff", "added_file.diff"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		require.NoError(t, err)
+		corpus.Write(data)
+	}
+
+	chunks := Parse(corpus.String())
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "added.go", chunks[0].FilePath)
+}
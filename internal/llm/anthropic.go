@@ -0,0 +1,168 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+)
+
+// anthropicVersion is the Messages API version the requests are written against.
+const anthropicVersion = "2023-06-01"
+
+// anthropicMessagesRequest is the body of the Messages API's /v1/messages endpoint.
+type anthropicMessagesRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicMessagesResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+type anthropicErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+var anthropicRoles = map[ai.Role]string{
+	ai.RoleUser:  "user",
+	ai.RoleModel: "assistant",
+}
+
+// anthropicModel calls a Claude model through the Messages API. Genkit's Anthropic plugin goes
+// through the OpenAI compatibility layer, which cannot set max_tokens or a base URL.
+type anthropicModel struct {
+	baseURL   string
+	apiKey    string
+	model     string
+	maxTokens int
+	client    *http.Client
+}
+
+// defineAnthropicModel registers the model as "anthropic/<model>".
+func defineAnthropicModel(g *genkit.Genkit, cfg *config.AnthropicConfig, model string) ai.Model {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = config.DefaultAnthropicBaseURL
+	}
+	maxTokens := cfg.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = config.DefaultAnthropicMaxTokens
+	}
+	timeout := cfg.TimeoutSeconds
+	if timeout == 0 {
+		timeout = config.DefaultAnthropicTimeoutSeconds
+	}
+	m := &anthropicModel{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		apiKey:    cfg.APIKey,
+		model:     model,
+		maxTokens: maxTokens,
+		client:    &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}
+	info := &ai.ModelInfo{
+		Label: "Anthropic - " + model,
+		Supports: &ai.ModelSupports{
+			Multiturn:  true,
+			SystemRole: true,
+		},
+	}
+	return genkit.DefineModel(g, constants.ANTHROPIC, model, info, func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+		return m.generate(ctx, req)
+	})
+}
+
+func (m *anthropicModel) generate(ctx context.Context, req *ai.ModelRequest) (*ai.ModelResponse, error) {
+	body := anthropicMessagesRequest{Model: m.model, MaxTokens: m.maxTokens}
+	var system []string
+	for _, msg := range req.Messages {
+		if msg.Role == ai.RoleSystem {
+			system = append(system, msg.Text())
+			continue
+		}
+		role, ok := anthropicRoles[msg.Role]
+		if !ok {
+			return nil, fmt.Errorf("anthropic: unsupported message role '%s'", msg.Role)
+		}
+		body.Messages = append(body.Messages, anthropicMessage{Role: role, Content: msg.Text()})
+	}
+	body.System = strings.Join(system, "\n\n")
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/v1/messages", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Api-Key", m.apiKey)
+	httpReq.Header.Set("Anthropic-Version", anthropicVersion)
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		statusErr := &StatusError{Provider: constants.ANTHROPIC, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+		var apiErr anthropicErrorResponse
+		if json.Unmarshal(msg, &apiErr) == nil && apiErr.Error.Message != "" {
+			statusErr.Message = apiErr.Error.Type + ": " + apiErr.Error.Message
+		}
+		return nil, statusErr
+	}
+
+	var out anthropicMessagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode anthropic response: %w", err)
+	}
+	var text strings.Builder
+	for _, block := range out.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	finishReason := ai.FinishReasonStop
+	if out.StopReason == "max_tokens" {
+		finishReason = ai.FinishReasonLength
+	}
+	return &ai.ModelResponse{
+		Request:      req,
+		Message:      ai.NewModelTextMessage(text.String()),
+		FinishReason: finishReason,
+		Usage: &ai.GenerationUsage{
+			InputTokens:  out.Usage.InputTokens,
+			OutputTokens: out.Usage.OutputTokens,
+			TotalTokens:  out.Usage.InputTokens + out.Usage.OutputTokens,
+		},
+	}, nil
+}
@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+)
+
+func TestInit_AnthropicError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has exceeded your rate limit."}}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{}
+	cfg.LLM.Provider = constants.ANTHROPIC
+	cfg.LLM.ModelName = "anthropic/claude-sonnet-4-5"
+	cfg.LLM.Anthropic = config.AnthropicConfig{APIKey: "test-key", BaseURL: server.URL + "/", Model: "claude-sonnet-4-5"}
+	g, err := Init(context.Background(), cfg)
+	require.NoError(t, err)
+
+	_, err = genkit.GenerateText(context.Background(), g, ai.WithModelName(cfg.LLM.ModelName), ai.WithPrompt("Review main.go"))
+	assert.ErrorContains(t, err, "anthropic returned 429: rate_limit_error: Number of requests has exceeded your rate limit.")
+}
@@ -0,0 +1,97 @@
+// Package llm sets up Genkit with the model providers selected in the configuration. Both the
+// CLI and the review server use it, so they support the same providers.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/googlegenai"
+	openaiGo "github.com/openai/openai-go"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"google.golang.org/genai"
+)
+
+// Init initializes Genkit and registers the configured model and its fallbacks.
+func Init(ctx context.Context, cfg *config.Config) (*genkit.Genkit, error) {
+	models := cfg.LLM.Models()
+	var plugins []genkit.Plugin
+	for _, m := range models {
+		switch m.Provider {
+		case constants.GOOGLEAI:
+			if len(plugins) == 0 {
+				plugins = append(plugins, &googlegenai.GoogleAI{APIKey: cfg.LLM.GoogleAI.APIKey})
+			}
+		case constants.OPENAI, constants.OLLAMA, constants.ANTHROPIC:
+		default:
+			return nil, fmt.Errorf("unsupported LLM provider in config: %s", m.Provider)
+		}
+	}
+	g, err := genkit.Init(ctx, genkit.WithPlugins(plugins...))
+	if err != nil {
+		return nil, err
+	}
+
+	defined := make(map[string]bool)
+	for _, m := range models {
+		if defined[m.ModelName] {
+			continue
+		}
+		defined[m.ModelName] = true
+		model := strings.TrimPrefix(m.ModelName, m.Provider+"/")
+		switch m.Provider {
+		case constants.OPENAI:
+			defineOpenAIModel(g, &cfg.LLM.OpenAI, model)
+		case constants.OLLAMA:
+			defineOllamaModel(g, &cfg.LLM.Ollama, model)
+		case constants.ANTHROPIC:
+			defineAnthropicModel(g, &cfg.LLM.Anthropic, model)
+		}
+	}
+	return g, nil
+}
+
+// StatusError is returned by the models defined here when the provider answers with an error status.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Transient reports whether a model call failed with a rate limit, timeout or server error,
+// which may succeed when retried later or with another provider.
+func Transient(err error) bool {
+	var statusErr *StatusError
+	var openAIErr *openaiGo.Error
+	var genaiErr genai.APIError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &statusErr):
+		return transientStatus(statusErr.StatusCode)
+	case errors.As(err, &openAIErr):
+		return transientStatus(openAIErr.StatusCode)
+	case errors.As(err, &genaiErr):
+		return transientStatus(genaiErr.Code)
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &netErr):
+		return netErr.Timeout()
+	}
+	return false
+}
+
+func transientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
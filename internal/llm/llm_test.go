@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"google.golang.org/genai"
+)
+
+func TestInit_Fallbacks(t *testing.T) {
+	cfg := ollamaConfig("http://localhost:11434")
+	cfg.LLM.Fallbacks = []config.ModelRef{
+		{Provider: constants.ANTHROPIC, ModelName: "anthropic/claude-sonnet-4-5"},
+		{Provider: constants.OPENAI, ModelName: "openai/meta-llama/Llama-3.1-8B-Instruct"},
+		{Provider: constants.OLLAMA, ModelName: "ollama/qwen2.5-coder:14b"},
+	}
+	g, err := Init(context.Background(), cfg)
+	require.NoError(t, err)
+
+	for _, m := range cfg.LLM.Models() {
+		assert.NotNil(t, genkit.LookupModel(g, m.Provider, m.ModelName[len(m.Provider)+1:]), m.ModelName)
+	}
+
+	cfg.LLM.Fallbacks = append(cfg.LLM.Fallbacks, config.ModelRef{Provider: "mystery", ModelName: "mystery/model"})
+	_, err = Init(context.Background(), cfg)
+	assert.EqualError(t, err, "unsupported LLM provider in config: mystery")
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Rate Limited", &StatusError{Provider: constants.OLLAMA, StatusCode: 429}, true},
+		{"Server Error", fmt.Errorf("generate: %w", &StatusError{Provider: constants.ANTHROPIC, StatusCode: 529}), true},
+		{"Unauthorized", &StatusError{Provider: constants.ANTHROPIC, StatusCode: 401}, false},
+		{"Gemini Unavailable", genai.APIError{Code: 503}, true},
+		{"Gemini Bad Request", genai.APIError{Code: 400}, false},
+		{"Timeout", fmt.Errorf("request failed: %w", context.DeadlineExceeded), true},
+		{"Other", errors.New("quota exceeded"), false},
+		{"None", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Transient(tt.err))
+		})
+	}
+}
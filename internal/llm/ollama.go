@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+)
+
+// ollamaChatRequest is the body of Ollama's /api/chat endpoint.
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	// Format is a JSON schema the response must follow.
+	Format  map[string]any `json:"format,omitempty"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+var ollamaRoles = map[ai.Role]string{
+	ai.RoleSystem: "system",
+	ai.RoleUser:   "user",
+	ai.RoleModel:  "assistant",
+}
+
+// ollamaModel calls a model on an Ollama server. Genkit's own Ollama plugin cannot pass model
+// options or a response schema and times out after 30 seconds, which is too short for large
+// local models.
+type ollamaModel struct {
+	serverAddress string
+	model         string
+	options       map[string]any
+	client        *http.Client
+}
+
+// defineOllamaModel registers the model as "ollama/<model>".
+func defineOllamaModel(g *genkit.Genkit, cfg *config.OllamaConfig, model string) ai.Model {
+	serverAddress := cfg.ServerAddress
+	if serverAddress == "" {
+		serverAddress = config.DefaultOllamaServerAddress
+	}
+	timeout := cfg.TimeoutSeconds
+	if timeout == 0 {
+		timeout = config.DefaultOllamaTimeoutSeconds
+	}
+	m := &ollamaModel{
+		serverAddress: strings.TrimSuffix(serverAddress, "/"),
+		model:         model,
+		options:       cfg.Options,
+		client:        &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}
+	info := &ai.ModelInfo{
+		Label: "Ollama - " + model,
+		Supports: &ai.ModelSupports{
+			Multiturn:   true,
+			SystemRole:  true,
+			Constrained: ai.ConstrainedSupportAll,
+		},
+	}
+	return genkit.DefineModel(g, constants.OLLAMA, model, info, func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+		return m.generate(ctx, req)
+	})
+}
+
+func (m *ollamaModel) generate(ctx context.Context, req *ai.ModelRequest) (*ai.ModelResponse, error) {
+	body := ollamaChatRequest{Model: m.model, Options: m.options}
+	for _, msg := range req.Messages {
+		role, ok := ollamaRoles[msg.Role]
+		if !ok {
+			return nil, fmt.Errorf("ollama: unsupported message role '%s'", msg.Role)
+		}
+		body.Messages = append(body.Messages, ollamaMessage{Role: role, Content: msg.Text()})
+	}
+	if req.Output != nil && req.Output.Schema != nil {
+		body.Format = req.Output.Schema
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.serverAddress+"/api/chat", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ollama request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &StatusError{Provider: constants.OLLAMA, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+
+	var out ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode ollama response: %w", err)
+	}
+	finishReason := ai.FinishReasonStop
+	if out.DoneReason == "length" {
+		finishReason = ai.FinishReasonLength
+	}
+	return &ai.ModelResponse{
+		Request:      req,
+		Message:      ai.NewModelTextMessage(out.Message.Content),
+		FinishReason: finishReason,
+		Usage: &ai.GenerationUsage{
+			InputTokens:  out.PromptEvalCount,
+			OutputTokens: out.EvalCount,
+			TotalTokens:  out.PromptEvalCount + out.EvalCount,
+		},
+	}, nil
+}
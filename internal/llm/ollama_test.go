@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+)
+
+type finding struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func ollamaConfig(serverAddress string) *config.Config {
+	cfg := &config.Config{}
+	cfg.LLM.Provider = constants.OLLAMA
+	cfg.LLM.ModelName = "ollama/qwen2.5-coder:14b"
+	cfg.LLM.Ollama = config.OllamaConfig{
+		ServerAddress: serverAddress,
+		Model:         "qwen2.5-coder:14b",
+		Options:       map[string]any{"temperature": 0.1, "num_ctx": 16384},
+	}
+	return cfg
+}
+
+func TestInit_Ollama(t *testing.T) {
+	var got ollamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		json.NewEncoder(w).Encode(map[string]any{
+			"message":           map[string]string{"role": "assistant", "content": `{"line":3,"message":"Unused variable."}`},
+			"done_reason":       "stop",
+			"prompt_eval_count": 120,
+			"eval_count":        15,
+		})
+	}))
+	defer server.Close()
+
+	cfg := ollamaConfig(server.URL + "/")
+	g, err := Init(context.Background(), cfg)
+	require.NoError(t, err)
+
+	out, resp, err := genkit.GenerateData[finding](context.Background(), g,
+		ai.WithModelName(cfg.LLM.ModelName),
+		ai.WithPrompt("Review main.go"),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, &finding{Line: 3, Message: "Unused variable."}, out)
+	assert.Equal(t, 120, resp.Usage.InputTokens)
+	assert.Equal(t, 15, resp.Usage.OutputTokens)
+
+	assert.Equal(t, "qwen2.5-coder:14b", got.Model)
+	assert.False(t, got.Stream)
+	assert.Equal(t, map[string]any{"temperature": 0.1, "num_ctx": float64(16384)}, got.Options)
+	require.NotEmpty(t, got.Messages)
+	assert.Equal(t, "user", got.Messages[len(got.Messages)-1].Role)
+	assert.Contains(t, got.Messages[len(got.Messages)-1].Content, "Review main.go")
+	assert.Equal(t, "object", got.Format["type"], "the output schema is sent as the response format")
+}
+
+func TestInit_OllamaError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"model 'qwen2.5-coder:14b' not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	cfg := ollamaConfig(server.URL)
+	g, err := Init(context.Background(), cfg)
+	require.NoError(t, err)
+
+	_, err = genkit.GenerateText(context.Background(), g, ai.WithModelName(cfg.LLM.ModelName), ai.WithPrompt("Review main.go"))
+	assert.ErrorContains(t, err, "ollama returned 404")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestInit_UnsupportedProvider(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.LLM.Provider = "mystery"
+	_, err := Init(context.Background(), cfg)
+	assert.EqualError(t, err, "unsupported LLM provider in config: mystery")
+}
@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/compat_oai"
+	openaiGo "github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+)
+
+// openAIOptions returns the client options for the configured endpoint. The client reads
+// OPENAI_API_KEY, OPENAI_ORG_ID and OPENAI_PROJECT_ID itself; configured values take precedence.
+func openAIOptions(cfg *config.OpenAIConfig) []option.RequestOption {
+	timeout := cfg.TimeoutSeconds
+	if timeout == 0 {
+		timeout = config.DefaultOpenAITimeoutSeconds
+	}
+	opts := []option.RequestOption{option.WithRequestTimeout(time.Duration(timeout) * time.Second)}
+	if cfg.APIKey != "" {
+		opts = append(opts, option.WithAPIKey(cfg.APIKey))
+	}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+	}
+	if cfg.Organization != "" {
+		opts = append(opts, option.WithOrganization(cfg.Organization))
+	}
+	if cfg.APIVersion != "" {
+		opts = append(opts, option.WithQuery("api-version", cfg.APIVersion))
+	}
+	for name, value := range cfg.Headers {
+		opts = append(opts, option.WithHeader(name, value))
+	}
+	return opts
+}
+
+// defineOpenAIModel registers the model as "openai/<model>". Genkit's OpenAI plugin
+// only registers OpenAI's own models and strips a leading "openai/" from the model ID, which
+// gateways such as OpenRouter use in their IDs, so the model is defined here and its ID is
+// sent unchanged.
+func defineOpenAIModel(g *genkit.Genkit, cfg *config.OpenAIConfig, model string) ai.Model {
+	client := openaiGo.NewClient(openAIOptions(cfg)...)
+	info := &ai.ModelInfo{
+		Label:    "OpenAI-compatible - " + model,
+		Supports: compat_oai.BasicText.Supports,
+	}
+	return genkit.DefineModel(g, constants.OPENAI, model, info, func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+		return compat_oai.NewModelGenerator(client, model).
+			WithMessages(req.Messages).
+			WithConfig(req.Config).
+			WithTools(req.Tools, req.ToolChoice).
+			Generate(ctx, cb)
+	})
+}
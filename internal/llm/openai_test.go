@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+)
+
+func openAIConfig(baseURL string) *config.Config {
+	cfg := &config.Config{}
+	cfg.LLM.Provider = constants.OPENAI
+	cfg.LLM.ModelName = "openai/meta-llama/Llama-3.1-8B-Instruct"
+	cfg.LLM.OpenAI = config.OpenAIConfig{
+		APIKey:       "gateway-key",
+		BaseURL:      baseURL,
+		Model:        "meta-llama/Llama-3.1-8B-Instruct",
+		Headers:      map[string]string{"X-Team": "reviews"},
+		APIVersion:   "2024-10-21",
+		Organization: "org-123",
+	}
+	return cfg
+}
+
+func TestInit_OpenAICompatible(t *testing.T) {
+	var got struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"` // A string or an array of content parts.
+		} `json:"messages"`
+	}
+	var header http.Header
+	var apiVersion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		header = r.Header
+		apiVersion = r.URL.Query().Get("api-version")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-1",
+			"object":  "chat.completion",
+			"created": 1,
+			"model":   got.Model,
+			"choices": []map[string]any{{
+				"index":         0,
+				"finish_reason": "stop",
+				"message":       map[string]string{"role": "assistant", "content": `{"line":3,"message":"Unused variable."}`},
+			}},
+			"usage": map[string]int{"prompt_tokens": 120, "completion_tokens": 15, "total_tokens": 135},
+		})
+	}))
+	defer server.Close()
+
+	cfg := openAIConfig(server.URL + "/v1")
+	g, err := Init(context.Background(), cfg)
+	require.NoError(t, err)
+
+	out, resp, err := genkit.GenerateData[finding](context.Background(), g,
+		ai.WithModelName(cfg.LLM.ModelName),
+		ai.WithPrompt("Review main.go"),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, &finding{Line: 3, Message: "Unused variable."}, out)
+	assert.Equal(t, 120, resp.Usage.InputTokens)
+	assert.Equal(t, 15, resp.Usage.OutputTokens)
+
+	assert.Equal(t, "meta-llama/Llama-3.1-8B-Instruct", got.Model, "the model ID is sent without the provider prefix")
+	require.NotEmpty(t, got.Messages)
+	assert.Contains(t, string(got.Messages[len(got.Messages)-1].Content), "Review main.go")
+	assert.Equal(t, "Bearer gateway-key", header.Get("Authorization"))
+	assert.Equal(t, "reviews", header.Get("X-Team"))
+	assert.Equal(t, "org-123", header.Get("OpenAI-Organization"))
+	assert.Equal(t, "2024-10-21", apiVersion)
+}
+
+func TestInit_OpenAICompatibleError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"The model does not exist."}}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	cfg := openAIConfig(server.URL + "/v1")
+	g, err := Init(context.Background(), cfg)
+	require.NoError(t, err)
+
+	_, err = genkit.GenerateText(context.Background(), g, ai.WithModelName(cfg.LLM.ModelName), ai.WithPrompt("Review main.go"))
+	assert.ErrorContains(t, err, "404")
+	assert.ErrorContains(t, err, "The model does not exist.")
+}
@@ -0,0 +1,455 @@
+// Package queue is a durable job queue stored in a bbolt file. Jobs that were pending or
+// running when the process stopped are picked up again the next time the queue starts.
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	pendingBucket = []byte("pending")
+	deadBucket    = []byte("dead")
+)
+
+// Defaults used for zero Options fields.
+const (
+	DefaultWorkers     = 2
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = 30 * time.Second
+	DefaultMaxBackoff  = 30 * time.Minute
+)
+
+// idleWait is how long the dispatcher sleeps when no job is scheduled.
+const idleWait = time.Minute
+
+// State is the lifecycle state of a job.
+type State string
+
+const (
+	StatePending State = "pending"
+	StateRunning State = "running"
+	StateDead    State = "dead"
+)
+
+// Job is a unit of work and its delivery state.
+type Job struct {
+	ID uint64 `json:"id"`
+	// Key groups jobs for the same subject: a newer job supersedes older ones with the same key,
+	// and jobs with the same key never run concurrently.
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	State     State           `json:"state"`
+	Attempts  int             `json:"attempts"` // Failed attempts so far.
+	RunAt     time.Time       `json:"run_at"`
+	LastError string          `json:"last_error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Handler processes a job. Returning an error schedules a retry. The context is cancelled
+// when the job is superseded or the queue is closed.
+type Handler func(ctx context.Context, job *Job) error
+
+// Options configures a Queue.
+type Options struct {
+	Workers     int
+	MaxAttempts int // Attempts before a job is moved to the dead-letter bucket.
+	// Backoff is the delay before the first retry; it doubles for each later retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Debounce delays new jobs so that a burst for the same key runs only the last one.
+	Debounce time.Duration
+	// OnDead is called after a job exhausts its attempts.
+	OnDead func(job *Job)
+}
+
+// Queue stores jobs in bbolt and runs them on a pool of workers.
+type Queue struct {
+	db   *bolt.DB
+	opts Options
+	wake chan struct{}
+
+	mu      sync.Mutex
+	running map[string]*activeJob // By job key.
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// activeJob tracks a claimed job so that a newer job for the same key can cancel it.
+type activeJob struct {
+	job        *Job
+	ctx        context.Context
+	cancel     context.CancelFunc
+	superseded bool
+}
+
+// Open opens or creates the queue file at path. Jobs left running by a previous process are
+// made pending again without counting the interrupted attempt.
+func Open(path string, opts Options) (*Queue, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job queue %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(deadBucket); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucketIfNotExists(pendingBucket)
+		if err != nil {
+			return err
+		}
+		return forEachJob(b, func(job *Job) error {
+			if job.State != StateRunning {
+				return nil
+			}
+			log.Printf("Resuming job %d (%s) interrupted by a restart.", job.ID, job.Key)
+			job.State = StatePending
+			return putJob(b, job)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize job queue %s: %w", path, err)
+	}
+
+	return &Queue{
+		db:      db,
+		opts:    opts,
+		wake:    make(chan struct{}, 1),
+		running: make(map[string]*activeJob),
+		stop:    func() {},
+	}, nil
+}
+
+// Enqueue stores a job with the JSON-encoded payload. Pending jobs with the same key are
+// dropped and a running one is cancelled, since the new job makes them stale.
+func (q *Queue) Enqueue(key string, payload any) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+	now := time.Now()
+	job := &Job{Key: key, Payload: data, State: StatePending, RunAt: now.Add(q.opts.Debounce), CreatedAt: now}
+
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pendingBucket)
+		var stale [][]byte
+		err := forEachJob(b, func(other *Job) error {
+			if other.Key == key && other.State == StatePending {
+				log.Printf("Job %d (%s) is superseded by a newer event.", other.ID, key)
+				stale = append(stale, itob(other.ID))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		if job.ID, err = b.NextSequence(); err != nil {
+			return err
+		}
+		return putJob(b, job)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+
+	q.mu.Lock()
+	// The dispatcher may already have claimed the new job itself.
+	if active, ok := q.running[key]; ok && active.job.ID < job.ID {
+		log.Printf("Cancelling running job %d (%s) superseded by job %d.", active.job.ID, key, job.ID)
+		active.superseded = true
+		active.cancel()
+	}
+	q.mu.Unlock()
+
+	q.notify()
+	return job, nil
+}
+
+// Dead returns the jobs in the dead-letter bucket.
+func (q *Queue) Dead() ([]*Job, error) {
+	var jobs []*Job
+	err := q.db.View(func(tx *bolt.Tx) error {
+		return forEachJob(tx.Bucket(deadBucket), func(job *Job) error {
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// Start runs pending jobs with handle until ctx is cancelled or Close is called.
+func (q *Queue) Start(ctx context.Context, handle Handler) {
+	ctx, q.stop = context.WithCancel(ctx)
+	claimed := make(chan *activeJob)
+
+	for i := 0; i < q.opts.Workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for active := range claimed {
+				q.process(ctx, active, handle)
+			}
+		}()
+	}
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		defer close(claimed)
+		q.dispatch(ctx, claimed)
+	}()
+}
+
+// Close stops the workers, leaving unfinished jobs to resume on the next start, and closes the file.
+func (q *Queue) Close() error {
+	q.stop()
+	q.wg.Wait()
+	return q.db.Close()
+}
+
+// dispatch hands due jobs to the workers, sleeping until the next job is due or a job is enqueued.
+func (q *Queue) dispatch(ctx context.Context, claimed chan<- *activeJob) {
+	for {
+		active, wait, err := q.claim(ctx)
+		if err != nil {
+			log.Printf("Error claiming queued job: %v", err)
+			wait = time.Second
+		}
+		if active != nil {
+			select {
+			case claimed <- active:
+				continue
+			case <-ctx.Done():
+				q.release(active)
+				return
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// claim marks the earliest due job whose key is not already running as running. When no job
+// is due it returns how long to wait for the next one.
+func (q *Queue) claim(ctx context.Context) (*activeJob, time.Duration, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	wait := idleWait
+	var next *Job
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pendingBucket)
+		err := forEachJob(b, func(job *Job) error {
+			if next != nil || job.State != StatePending || q.running[job.Key] != nil {
+				return nil
+			}
+			if until := job.RunAt.Sub(now); until > 0 {
+				wait = min(wait, until)
+				return nil
+			}
+			next = job
+			return nil
+		})
+		if err != nil || next == nil {
+			return err
+		}
+		next.State = StateRunning
+		return putJob(b, next)
+	})
+	if err != nil || next == nil {
+		return nil, wait, err
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	active := &activeJob{job: next, ctx: jobCtx, cancel: cancel}
+	q.running[next.Key] = active
+	return active, 0, nil
+}
+
+// process runs a claimed job and records the outcome.
+func (q *Queue) process(ctx context.Context, active *activeJob, handle Handler) {
+	job := active.job
+	if ctx.Err() != nil {
+		// Claimed just as the queue closed.
+		q.release(active)
+		return
+	}
+	err := handle(active.ctx, job)
+
+	q.mu.Lock()
+	superseded := active.superseded
+	q.mu.Unlock()
+
+	switch {
+	case err == nil:
+		q.remove(job)
+	case superseded:
+		log.Printf("Job %d (%s) stopped after being superseded: %v", job.ID, job.Key, err)
+		q.remove(job)
+	case ctx.Err() != nil:
+		// The queue is closing, so the attempt did not fail on its own. release makes the job
+		// pending again without counting it.
+	default:
+		q.fail(job, err)
+	}
+	q.release(active)
+}
+
+// fail records a failed attempt and schedules a retry, or moves the job to the dead-letter bucket.
+func (q *Queue) fail(job *Job, cause error) {
+	job.Attempts++
+	job.LastError = cause.Error()
+	dead := job.Attempts >= q.opts.MaxAttempts
+
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		pending := tx.Bucket(pendingBucket)
+		if dead {
+			job.State = StateDead
+			if err := pending.Delete(itob(job.ID)); err != nil {
+				return err
+			}
+			return putJob(tx.Bucket(deadBucket), job)
+		}
+		job.State = StatePending
+		job.RunAt = time.Now().Add(q.backoff(job.Attempts))
+		return putJob(pending, job)
+	})
+	if err != nil {
+		log.Printf("Error recording failure of job %d: %v", job.ID, err)
+		return
+	}
+
+	if dead {
+		log.Printf("Job %d (%s) failed %d times and was moved to the dead-letter queue: %v", job.ID, job.Key, job.Attempts, cause)
+		if q.opts.OnDead != nil {
+			q.opts.OnDead(job)
+		}
+		return
+	}
+	log.Printf("Job %d (%s) failed (attempt %d of %d), retrying at %s: %v", job.ID, job.Key, job.Attempts, q.opts.MaxAttempts, job.RunAt.Format(time.RFC3339), cause)
+}
+
+// backoff returns the delay before the retry that follows the given number of failed attempts.
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.opts.Backoff
+	for i := 1; i < attempts && d < q.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, q.opts.MaxBackoff)
+}
+
+// remove deletes a finished job.
+func (q *Queue) remove(job *Job) {
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(pendingBucket).Delete(itob(job.ID))
+	})
+	if err != nil {
+		log.Printf("Error removing finished job %d: %v", job.ID, err)
+	}
+}
+
+// release forgets a claimed job and wakes the dispatcher, since jobs with the same key may now run.
+// A job that is still marked running is made pending again.
+func (q *Queue) release(active *activeJob) {
+	active.cancel()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pendingBucket)
+		job, err := getJob(b, active.job.ID)
+		if err != nil || job == nil || job.State != StateRunning {
+			return err
+		}
+		job.State = StatePending
+		return putJob(b, job)
+	})
+	if err != nil {
+		log.Printf("Error releasing job %d: %v", active.job.ID, err)
+	}
+
+	q.mu.Lock()
+	if q.running[active.job.Key] == active {
+		delete(q.running, active.job.Key)
+	}
+	q.mu.Unlock()
+	q.notify()
+}
+
+// notify wakes the dispatcher without blocking.
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func forEachJob(b *bolt.Bucket, fn func(job *Job) error) error {
+	return b.ForEach(func(k, v []byte) error {
+		var job Job
+		if err := json.Unmarshal(v, &job); err != nil {
+			return fmt.Errorf("corrupt job %d: %w", binary.BigEndian.Uint64(k), err)
+		}
+		return fn(&job)
+	})
+}
+
+func getJob(b *bolt.Bucket, id uint64) (*Job, error) {
+	v := b.Get(itob(id))
+	if v == nil {
+		return nil, nil
+	}
+	var job Job
+	if err := json.Unmarshal(v, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func putJob(b *bolt.Bucket, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return b.Put(itob(job.ID), data)
+}
+
+// itob encodes an ID big-endian so that bucket order is enqueue order.
+func itob(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
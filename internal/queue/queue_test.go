@@ -0,0 +1,227 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// recorder is a Handler that records the payloads it runs and signals each run on done.
+type recorder struct {
+	mu   sync.Mutex
+	ran  []string
+	done chan string
+	fail func(payload string, attempts int) error
+}
+
+func newRecorder() *recorder {
+	return &recorder{done: make(chan string, 16)}
+}
+
+func (r *recorder) handle(ctx context.Context, job *Job) error {
+	var payload string
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+	if r.fail != nil {
+		if err := r.fail(payload, job.Attempts); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	r.ran = append(r.ran, payload)
+	r.mu.Unlock()
+	r.done <- payload
+	return nil
+}
+
+// wait blocks until the recorder has run the wanted payloads, in any order.
+func (r *recorder) wait(t *testing.T, want ...string) {
+	t.Helper()
+	var got []string
+	for range want {
+		select {
+		case payload := <-r.done:
+			got = append(got, payload)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q, got %q", want, got)
+		}
+	}
+	assert.ElementsMatch(t, want, got)
+}
+
+func openQueue(t *testing.T, path string, opts Options) *Queue {
+	t.Helper()
+	q, err := Open(path, opts)
+	require.NoError(t, err)
+	return q
+}
+
+func countPending(t *testing.T, q *Queue) int {
+	t.Helper()
+	n := 0
+	require.NoError(t, q.db.View(func(tx *bolt.Tx) error {
+		return forEachJob(tx.Bucket(pendingBucket), func(*Job) error {
+			n++
+			return nil
+		})
+	}))
+	return n
+}
+
+func TestQueue_RunsAndRemovesJobs(t *testing.T) {
+	q := openQueue(t, filepath.Join(t.TempDir(), "queue.db"), Options{})
+	defer q.Close()
+	rec := newRecorder()
+	q.Start(context.Background(), rec.handle)
+
+	job, err := q.Enqueue("pr-1", "review")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), job.ID)
+
+	rec.wait(t, "review")
+	assert.Eventually(t, func() bool { return countPending(t, q) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestQueue_RetriesWithBackoff(t *testing.T) {
+	q := openQueue(t, filepath.Join(t.TempDir(), "queue.db"), Options{Backoff: 10 * time.Millisecond})
+	defer q.Close()
+	rec := newRecorder()
+	var attempts []int
+	rec.fail = func(payload string, failed int) error {
+		attempts = append(attempts, failed)
+		if failed < 2 {
+			return errors.New("provider unavailable")
+		}
+		return nil
+	}
+	q.Start(context.Background(), rec.handle)
+
+	_, err := q.Enqueue("pr-1", "review")
+	require.NoError(t, err)
+	rec.wait(t, "review")
+	assert.Equal(t, []int{0, 1, 2}, attempts)
+}
+
+func TestQueue_Backoff(t *testing.T) {
+	q := &Queue{opts: Options{Backoff: time.Second, MaxBackoff: 5 * time.Second}}
+	assert.Equal(t, time.Second, q.backoff(1))
+	assert.Equal(t, 2*time.Second, q.backoff(2))
+	assert.Equal(t, 4*time.Second, q.backoff(3))
+	assert.Equal(t, 5*time.Second, q.backoff(4))
+	assert.Equal(t, 5*time.Second, q.backoff(60))
+}
+
+func TestQueue_DeadLetter(t *testing.T) {
+	dead := make(chan *Job, 1)
+	q := openQueue(t, filepath.Join(t.TempDir(), "queue.db"), Options{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		OnDead:      func(job *Job) { dead <- job },
+	})
+	defer q.Close()
+	q.Start(context.Background(), func(ctx context.Context, job *Job) error {
+		return errors.New("invalid token")
+	})
+
+	_, err := q.Enqueue("pr-1", "review")
+	require.NoError(t, err)
+
+	select {
+	case job := <-dead:
+		assert.Equal(t, 3, job.Attempts)
+		assert.Equal(t, "invalid token", job.LastError)
+	case <-time.After(5 * time.Second):
+		t.Fatal("job was not dead-lettered")
+	}
+	jobs, err := q.Dead()
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, StateDead, jobs[0].State)
+	assert.Equal(t, 0, countPending(t, q))
+}
+
+func TestQueue_ResumesOnStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+
+	// The first process accepts two jobs and is stopped while running one of them.
+	q := openQueue(t, path, Options{Workers: 1})
+	started := make(chan struct{})
+	q.Start(context.Background(), func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	_, err := q.Enqueue("pr-1", "interrupted")
+	require.NoError(t, err)
+	<-started
+	_, err = q.Enqueue("pr-2", "pending")
+	require.NoError(t, err)
+	require.NoError(t, q.Close())
+
+	// The next process runs both, without counting the interrupted attempt.
+	q = openQueue(t, path, Options{})
+	defer q.Close()
+	rec := newRecorder()
+	var failedAttempts atomic.Int32
+	rec.fail = func(payload string, failed int) error {
+		failedAttempts.Add(int32(failed))
+		return nil
+	}
+	q.Start(context.Background(), rec.handle)
+	rec.wait(t, "interrupted", "pending")
+	assert.Zero(t, failedAttempts.Load())
+}
+
+func TestQueue_NewerJobSupersedesOlder(t *testing.T) {
+	t.Run("Debounce Drops Pending Jobs", func(t *testing.T) {
+		q := openQueue(t, filepath.Join(t.TempDir(), "queue.db"), Options{Debounce: 50 * time.Millisecond})
+		defer q.Close()
+		rec := newRecorder()
+		q.Start(context.Background(), rec.handle)
+
+		for _, e := range []struct{ key, payload string }{
+			{"pr-1", "push 1"}, {"pr-1", "push 2"}, {"pr-2", "other PR"}, {"pr-1", "push 3"},
+		} {
+			_, err := q.Enqueue(e.key, e.payload)
+			require.NoError(t, err)
+		}
+		rec.wait(t, "other PR", "push 3")
+		assert.Len(t, rec.ran, 2)
+	})
+
+	t.Run("Cancels Running Job", func(t *testing.T) {
+		q := openQueue(t, filepath.Join(t.TempDir(), "queue.db"), Options{Backoff: time.Millisecond})
+		defer q.Close()
+		rec := newRecorder()
+		started := make(chan struct{})
+		var calls atomic.Int32
+		q.Start(context.Background(), func(ctx context.Context, job *Job) error {
+			if calls.Add(1) == 1 {
+				close(started)
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return rec.handle(ctx, job)
+		})
+
+		_, err := q.Enqueue("pr-1", "stale")
+		require.NoError(t, err)
+		<-started
+		_, err = q.Enqueue("pr-1", "current")
+		require.NoError(t, err)
+
+		rec.wait(t, "current")
+		assert.Eventually(t, func() bool { return countPending(t, q) == 0 }, time.Second, 10*time.Millisecond, "the cancelled job is not retried")
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
@@ -0,0 +1,72 @@
+package reviewer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/internal/llm"
+)
+
+func TestAnalyzeChunk_Anthropic(t *testing.T) {
+	chunk := parseSingleChunk(t, duplicateLinesDiff)
+	cfg := &config.Config{ReviewPrompt: "Review {{.FilePath}}:\n{{.CodeSnippet}}"}
+	cfg.LLM.Provider = constants.ANTHROPIC
+	cfg.LLM.ModelName = "anthropic/claude-sonnet-4-5"
+	wantPrompt, err := preparePrompt(cfg.ReviewPrompt, chunk.FilePath, numberSnippet(chunk))
+	require.NoError(t, err)
+
+	var got struct {
+		Model     string `json:"model"`
+		MaxTokens int    `json:"max_tokens"`
+		Messages  []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		header = r.Header
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":          "msg_1",
+			"type":        "message",
+			"role":        "assistant",
+			"stop_reason": "end_turn",
+			"content": []map[string]string{{
+				"type": "text",
+				"text": `{"comments":[{"line":18,"line_content":"+\treturn nil","message":"Wrap the error.","severity":"minor","category":"bug","confidence":0.8}]}`,
+			}},
+			"usage": map[string]int{"input_tokens": 250, "output_tokens": 40},
+		})
+	}))
+	defer server.Close()
+	cfg.LLM.Anthropic = config.AnthropicConfig{
+		APIKey:    "test-key",
+		BaseURL:   server.URL,
+		Model:     "claude-sonnet-4-5",
+		MaxTokens: 2048,
+	}
+
+	g, err := llm.Init(context.Background(), cfg)
+	require.NoError(t, err)
+	comments, err := analyzeChunk(context.Background(), g, cfg, chunk)
+	require.NoError(t, err)
+	assert.Equal(t, []ReviewComment{{Line: 18, LineContent: "+\treturn nil", Message: "Wrap the error.", Severity: "minor", Category: "bug", Confidence: 0.8}}, comments)
+
+	assert.Equal(t, "claude-sonnet-4-5", got.Model)
+	assert.Equal(t, 2048, got.MaxTokens)
+	assert.Equal(t, "test-key", header.Get("X-Api-Key"))
+	assert.Equal(t, "2023-06-01", header.Get("Anthropic-Version"))
+	require.NotEmpty(t, got.Messages)
+	assert.Equal(t, "user", got.Messages[0].Role)
+	assert.Contains(t, got.Messages[0].Content, wantPrompt, "the prepared prompt is sent unchanged")
+}
@@ -0,0 +1,106 @@
+package reviewer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/surya84/code-reviewer-bot/pkg/tracing"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+// Hidden HTML markers that identify the bot's line comments and their state.
+const (
+	findingMarkerPrefix = "<!-- code-reviewer-bot:finding:"
+	resolvedMarker      = "<!-- code-reviewer-bot:resolved -->"
+)
+
+// fingerprint identifies a finding by file, line and issue. The issue is the category and the
+// commented line's content rather than the message, whose wording varies between runs.
+func fingerprint(path string, line int, category, content string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s\x00%s", path, line, category, strings.Join(strings.Fields(content), " "))))
+	return hex.EncodeToString(sum[:8])
+}
+
+// findingMarker is the hidden marker appended to the body of a posted finding.
+func findingMarker(fp string) string {
+	return findingMarkerPrefix + fp + " -->"
+}
+
+// parseFindingMarker returns the fingerprint carried by a comment body, or false if the
+// comment was not posted by the bot.
+func parseFindingMarker(body string) (string, bool) {
+	_, rest, ok := strings.Cut(body, findingMarkerPrefix)
+	if !ok {
+		return "", false
+	}
+	fp, _, ok := strings.Cut(rest, " -->")
+	return fp, ok && fp != ""
+}
+
+// markResolved prefixes a bot comment with a note that its finding is gone from the diff.
+func markResolved(body string) string {
+	return resolvedMarker + "\n> ✅ **Resolved:** this finding is no longer present in the latest changes.\n\n" + body
+}
+
+// reconcileComments compares new findings with the bot's comments already on the PR. It returns
+// the findings that still need posting, with their finding markers appended, and marks earlier
+// comments whose finding has gone away as resolved. Only comments on lines for which inScope
+// reports true can be resolved: lines this run did not review, such as those in a chunk that
+// failed analysis, have unknown findings rather than none. Markers are only trusted on comments
+// written by botUser, since anyone on the PR can copy them.
+func reconcileComments(ctx context.Context, vcsClient vcs.VCSAdapter, prDetails *PRDetails, botUser string, comments []*vcs.Comment, inScope func(path string, line int) bool) []*vcs.Comment {
+	all, err := vcsClient.ListReviewComments(ctx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber)
+	if err != nil {
+		tracing.Logf(ctx, "Warning: could not list existing review comments, posting all findings: %v", err)
+		return withFindingMarkers(comments)
+	}
+	var existing []*vcs.LineComment
+	for _, c := range all {
+		if c.Author == botUser {
+			existing = append(existing, c)
+		}
+	}
+
+	posted := make(map[string]bool)
+	for _, c := range existing {
+		if fp, ok := parseFindingMarker(c.Body); ok && !strings.Contains(c.Body, resolvedMarker) {
+			posted[fp] = true
+		}
+	}
+
+	current := make(map[string]bool, len(comments))
+	var fresh []*vcs.Comment
+	for _, c := range comments {
+		current[c.Fingerprint] = true
+		if posted[c.Fingerprint] {
+			tracing.Logf(ctx, "Skipping finding on %s:%d that was already posted.", c.Path, c.Line)
+			continue
+		}
+		fresh = append(fresh, c)
+	}
+
+	for _, c := range existing {
+		fp, ok := parseFindingMarker(c.Body)
+		if !ok || !posted[fp] || current[fp] || !inScope(c.Path, c.Line) {
+			continue
+		}
+		tracing.Logf(ctx, "Marking comment %d on %s:%d as resolved.", c.ID, c.Path, c.Line)
+		if err := vcsClient.EditReviewComment(ctx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber, c.ID, markResolved(c.Body)); err != nil {
+			tracing.Logf(ctx, "Warning: could not mark comment %d as resolved: %v", c.ID, err)
+		}
+	}
+	return withFindingMarkers(fresh)
+}
+
+// withFindingMarkers appends each comment's finding marker to its body.
+func withFindingMarkers(comments []*vcs.Comment) []*vcs.Comment {
+	for _, c := range comments {
+		if _, ok := parseFindingMarker(c.Body); !ok {
+			c.Body += "\n\n" + findingMarker(c.Fingerprint)
+		}
+	}
+	return comments
+}
@@ -0,0 +1,97 @@
+package reviewer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+func TestFingerprint(t *testing.T) {
+	fp := fingerprint("a.go", 10, "bug", "return  err")
+	assert.Len(t, fp, 16)
+	assert.Equal(t, fp, fingerprint("a.go", 10, "bug", "\treturn err "), "whitespace is normalized")
+	assert.NotEqual(t, fp, fingerprint("a.go", 11, "bug", "return err"))
+	assert.NotEqual(t, fp, fingerprint("a.go", 10, "style", "return err"))
+	assert.NotEqual(t, fp, fingerprint("a.go", 10, "bug", "return nil"))
+
+	got, ok := parseFindingMarker("Message.\n\n" + findingMarker(fp))
+	assert.True(t, ok)
+	assert.Equal(t, fp, got)
+	_, ok = parseFindingMarker("A comment from a human.")
+	assert.False(t, ok)
+}
+
+func TestRunReview_ReconcilesComments(t *testing.T) {
+	prDetails := &PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1}
+
+	// A first run on a fresh PR posts the finding with its marker.
+	g, cfg := setupFakeModel(t, summaryTestModel(nil))
+	first := &fakeAdapter{diff: duplicateLinesDiff}
+	_, err := RunReview(context.Background(), g, prDetails, cfg, first)
+	require.NoError(t, err)
+	require.Len(t, first.reviews, 1)
+	require.Len(t, first.reviews[0], 1)
+	posted := first.reviews[0][0]
+	assert.True(t, strings.HasSuffix(posted.Body, "\n\n"+findingMarker(posted.Fingerprint)))
+
+	stale := "Old finding.\n\n" + findingMarker("0123456789abcdef")
+	resolved := markResolved("Older finding.\n\n" + findingMarker("fedcba9876543210"))
+
+	t.Run("Skips Posted And Resolves Stale Findings", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, summaryTestModel(nil))
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, lineComments: []*vcs.LineComment{
+			{ID: 1, Path: "dup.go", Line: 18, Body: posted.Body, Author: fakeBotUser},
+			{ID: 2, Path: "dup.go", Line: 12, Body: stale, Author: fakeBotUser},
+			{ID: 3, Path: "dup.go", Line: 13, Body: resolved, Author: fakeBotUser},
+			{ID: 4, Path: "dup.go", Line: 14, Body: "A comment from a human.", Author: "someone"},
+		}}
+
+		msg, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.NoError(t, err)
+		assert.Equal(t, "Review complete. Submitted 0 comments.", msg)
+		assert.Empty(t, adapter.reviews)
+		assert.Equal(t, map[int64]string{2: markResolved(stale)}, adapter.editedComments)
+	})
+
+	t.Run("Reposts A Finding That Was Resolved Before", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, summaryTestModel(nil))
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, lineComments: []*vcs.LineComment{
+			{ID: 1, Path: "dup.go", Line: 18, Body: markResolved(posted.Body), Author: fakeBotUser},
+		}}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.NoError(t, err)
+		require.Len(t, adapter.reviews, 1)
+		assert.Len(t, adapter.reviews[0], 1)
+		assert.Empty(t, adapter.editedComments)
+	})
+
+	t.Run("Ignores Markers On Other Users' Comments", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, summaryTestModel(nil))
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, lineComments: []*vcs.LineComment{
+			{ID: 1, Path: "dup.go", Line: 18, Body: posted.Body, Author: "someone"},
+			{ID: 2, Path: "dup.go", Line: 12, Body: stale, Author: "someone"},
+		}}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.NoError(t, err)
+		require.Len(t, adapter.reviews, 1, "a copied marker does not suppress the finding")
+		assert.Len(t, adapter.reviews[0], 1)
+		assert.Empty(t, adapter.editedComments, "comments by others are never marked resolved")
+	})
+
+	t.Run("Leaves Comments On Unreviewed Files", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, &fakeModel{respond: func(string) (string, error) { return "not json", nil }})
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, lineComments: []*vcs.LineComment{
+			{ID: 1, Path: "dup.go", Line: 18, Body: posted.Body, Author: fakeBotUser},
+		}}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.Error(t, err, "no chunk could be reviewed")
+		assert.Empty(t, adapter.editedComments)
+	})
+}
@@ -0,0 +1,203 @@
+package reviewer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/internal/llm"
+	"github.com/surya84/code-reviewer-bot/pkg/metrics"
+	"github.com/surya84/code-reviewer-bot/pkg/tracing"
+)
+
+// errCircuitOpen is returned for a model whose provider is being skipped after repeated failures.
+var errCircuitOpen = errors.New("circuit open after repeated failures")
+
+// generateWithFallback asks the configured models in order until one answers. Rate limit,
+// timeout and server errors are retried with jittered backoff and then fail over to the next
+// model. Other errors end the call. The name of the model that answered is returned with its
+// raw response, whether or not the response could be parsed into Out.
+func generateWithFallback[Out any](ctx context.Context, g *genkit.Genkit, cfg *config.Config, request string) (*Out, *modelCall, string, error) {
+	models := cfg.LLM.Models()
+	var errs []error
+	for i, m := range models {
+		out, call, err := generateWithRetry[Out](ctx, g, cfg, m, request)
+		if call != nil || ctx.Err() != nil || !(errors.Is(err, errCircuitOpen) || llm.Transient(err)) {
+			return out, call, m.ModelName, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", m.ModelName, err))
+		if i+1 < len(models) {
+			metrics.LLMFailovers.WithLabelValues(m.ModelName).Inc()
+			tracing.Logf(ctx, "Failing over from %s to %s: %v", m.ModelName, models[i+1].ModelName, err)
+		}
+	}
+	return nil, nil, models[len(models)-1].ModelName, errors.Join(errs...)
+}
+
+// generateWithRetry calls one model, retrying rate limit, timeout and server errors up to the
+// configured number of attempts while its provider's circuit stays closed.
+func generateWithRetry[Out any](ctx context.Context, g *genkit.Genkit, cfg *config.Config, m config.ModelRef, request string) (*Out, *modelCall, error) {
+	limiter := limiterFor(m.Provider, cfg.LLM.RateLimits[m.Provider])
+	breaker := breakerFor(m.Provider, cfg.LLM.CircuitBreaker)
+	maxAttempts := cfg.LLM.Retry.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = config.DefaultLLMMaxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		if !breaker.allow() {
+			return nil, nil, errCircuitOpen
+		}
+		estimated := estimateTokens(request)
+		if err := limiter.wait(ctx, estimated); err != nil {
+			breaker.release()
+			return nil, nil, fmt.Errorf("rate limit wait cancelled: %w", err)
+		}
+
+		// Capture the raw model response so a rejected one can be shown back to the model.
+		var call *modelCall
+		out, _, err := genkit.GenerateData[Out](ctx, g,
+			ai.WithModelName(m.ModelName),
+			ai.WithPrompt(request),
+			ai.WithMiddleware(captureModelCall(m.ModelName, &call)),
+		)
+		if call != nil {
+			limiter.record(estimated, call.usage)
+		}
+		if call == nil && ctx.Err() != nil {
+			// A cancelled call says nothing about the provider's health.
+			breaker.release()
+			return out, call, err
+		}
+		transient := call == nil && llm.Transient(err)
+		if breaker.record(transient) {
+			tracing.Logf(ctx, "Skipping provider %s for %s after repeated failures.", m.Provider, breaker.cooldown)
+		}
+		if !transient || attempt == maxAttempts {
+			return out, call, err
+		}
+
+		delay := retryDelay(cfg.LLM.Retry, attempt)
+		tracing.Logf(ctx, "Model %s failed (attempt %d of %d), retrying in %s: %v", m.ModelName, attempt, maxAttempts, delay.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			return nil, nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryDelay returns the backoff before the given retry: the initial backoff doubled for each
+// earlier retry and capped, jittered to between half and all of that so that concurrent chunk
+// analyses do not retry in step.
+func retryDelay(cfg config.RetryConfig, retry int) time.Duration {
+	backoff := time.Duration(cfg.BackoffMillis) * time.Millisecond
+	if backoff == 0 {
+		backoff = config.DefaultLLMBackoffMillis * time.Millisecond
+	}
+	maxBackoff := time.Duration(cfg.MaxBackoffMillis) * time.Millisecond
+	if maxBackoff == 0 {
+		maxBackoff = config.DefaultLLMMaxBackoffMillis * time.Millisecond
+	}
+	delay := backoff
+	for i := 1; i < retry && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxBackoff)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// circuitBreaker stops calls to a provider after consecutive failures. Once the cool-down has
+// passed, a single call is let through to probe the provider: its success closes the circuit
+// again and its failure restarts the cool-down.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int       // Consecutive failed calls.
+	openUntil time.Time // When the cool-down of an open circuit ends.
+	probing   bool      // A probe call is in flight.
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*circuitBreaker)
+)
+
+// breakerFor returns the breaker shared by every review that calls the given provider, so one
+// review's failures spare the others the wait.
+func breakerFor(provider string, cfg config.CircuitBreakerConfig) *circuitBreaker {
+	key := fmt.Sprintf("%s/%d/%d", provider, cfg.FailureThreshold, cfg.CooldownSeconds)
+
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	if b, ok := breakers[key]; ok {
+		return b
+	}
+	b := newCircuitBreaker(cfg)
+	breakers[key] = b
+	return b
+}
+
+func newCircuitBreaker(cfg config.CircuitBreakerConfig) *circuitBreaker {
+	b := &circuitBreaker{
+		threshold: cfg.FailureThreshold,
+		cooldown:  time.Duration(cfg.CooldownSeconds) * time.Second,
+		now:       time.Now,
+	}
+	if b.threshold == 0 {
+		b.threshold = config.DefaultFailureThreshold
+	}
+	if b.cooldown == 0 {
+		b.cooldown = config.DefaultCooldownSeconds * time.Second
+	}
+	return b
+}
+
+// allow reports whether a call may be made now. Every allowed call must be followed by record
+// or release.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record counts the outcome of an allowed call and reports whether it opened the circuit.
+func (b *circuitBreaker) record(failed bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.probing
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	if b.failures < b.threshold {
+		return false
+	}
+	b.openUntil = b.now().Add(b.cooldown)
+	return b.failures == b.threshold || probe
+}
+
+// release ends an allowed call that was abandoned before the provider answered, such as one
+// cancelled with its review. It frees the probe slot without counting a success or failure.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
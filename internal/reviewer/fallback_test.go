@@ -0,0 +1,149 @@
+package reviewer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/internal/llm"
+	"github.com/surya84/code-reviewer-bot/pkg/metrics"
+)
+
+const emptyReview = `{"comments":[]}`
+
+// setupFallbackModels registers a primary and a backup fake model under providers unique to
+// the test, so that the shared circuit breakers of other tests are not affected.
+func setupFallbackModels(t *testing.T, primary, backup *fakeModel) (*genkit.Genkit, *config.Config) {
+	g, err := genkit.Init(context.Background())
+	require.NoError(t, err)
+
+	info := &ai.ModelInfo{Supports: &ai.ModelSupports{Constrained: ai.ConstrainedSupportAll}}
+	cfg := &config.Config{ReviewPrompt: "Review {{.FilePath}}:\n{{.CodeSnippet}}"}
+	for i, model := range []*fakeModel{primary, backup} {
+		provider := strings.ReplaceAll(t.Name(), "/", "-") + []string{"-primary", "-backup"}[i]
+		genkit.DefineModel(g, provider, "reviewer", info, func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			return model.generate(ctx, req)
+		})
+		ref := config.ModelRef{Provider: provider, ModelName: provider + "/reviewer"}
+		if i == 0 {
+			cfg.LLM.Provider, cfg.LLM.ModelName = ref.Provider, ref.ModelName
+		} else {
+			cfg.LLM.Fallbacks = append(cfg.LLM.Fallbacks, ref)
+		}
+	}
+	cfg.LLM.Retry = config.RetryConfig{MaxAttempts: 2, BackoffMillis: 1}
+	return g, cfg
+}
+
+func TestAnalyzeChunk_Fallback(t *testing.T) {
+	chunk := parseSingleChunk(t, duplicateLinesDiff)
+
+	t.Run("Retries Then Fails Over", func(t *testing.T) {
+		primary := &fakeModel{err: &llm.StatusError{Provider: "primary", StatusCode: 503, Message: "overloaded"}}
+		backup := &fakeModel{responses: []string{emptyReview}}
+		g, cfg := setupFallbackModels(t, primary, backup)
+
+		comments, err := analyzeChunk(context.Background(), g, cfg, chunk)
+		require.NoError(t, err)
+		assert.Empty(t, comments)
+		assert.Len(t, primary.prompts, 2)
+		assert.Len(t, backup.prompts, 1)
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.LLMFailovers.WithLabelValues(cfg.LLM.ModelName)))
+	})
+
+	t.Run("Other Errors Do Not Fail Over", func(t *testing.T) {
+		primary := &fakeModel{err: &llm.StatusError{Provider: "primary", StatusCode: 401, Message: "invalid key"}}
+		backup := &fakeModel{responses: []string{emptyReview}}
+		g, cfg := setupFallbackModels(t, primary, backup)
+
+		_, err := analyzeChunk(context.Background(), g, cfg, chunk)
+		assert.ErrorContains(t, err, "invalid key")
+		assert.Len(t, primary.prompts, 1)
+		assert.Empty(t, backup.prompts)
+	})
+
+	t.Run("Open Circuit Skips The Provider", func(t *testing.T) {
+		primary := &fakeModel{err: &llm.StatusError{Provider: "primary", StatusCode: 429, Message: "rate limited"}}
+		backup := &fakeModel{responses: []string{emptyReview, emptyReview}}
+		g, cfg := setupFallbackModels(t, primary, backup)
+		cfg.LLM.CircuitBreaker = config.CircuitBreakerConfig{FailureThreshold: 2, CooldownSeconds: 60}
+
+		for range 2 {
+			_, err := analyzeChunk(context.Background(), g, cfg, chunk)
+			require.NoError(t, err)
+		}
+		assert.Len(t, primary.prompts, 2, "the second analysis skips the provider after two failures")
+		assert.Len(t, backup.prompts, 2)
+	})
+
+	t.Run("Every Model Failing Is An Error", func(t *testing.T) {
+		primary := &fakeModel{err: &llm.StatusError{Provider: "primary", StatusCode: 500, Message: "internal"}}
+		backup := &fakeModel{err: &llm.StatusError{Provider: "backup", StatusCode: 504, Message: "gateway timeout"}}
+		g, cfg := setupFallbackModels(t, primary, backup)
+
+		_, err := analyzeChunk(context.Background(), g, cfg, chunk)
+		assert.ErrorContains(t, err, "internal")
+		assert.ErrorContains(t, err, "gateway timeout")
+		assert.Len(t, primary.prompts, 2)
+		assert.Len(t, backup.prompts, 2)
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker(config.CircuitBreakerConfig{FailureThreshold: 2, CooldownSeconds: 30})
+	b.now = func() time.Time { return now }
+
+	assert.True(t, b.allow())
+	assert.False(t, b.record(true))
+	assert.True(t, b.allow())
+	assert.True(t, b.record(true), "the second consecutive failure opens the circuit")
+	assert.False(t, b.allow())
+
+	now = now.Add(31 * time.Second)
+	assert.True(t, b.allow(), "a probe is let through after the cool-down")
+	assert.False(t, b.allow(), "only one probe at a time")
+	assert.True(t, b.record(true), "a failed probe reopens the circuit")
+	assert.False(t, b.allow())
+
+	now = now.Add(31 * time.Second)
+	assert.True(t, b.allow())
+	b.release()
+	assert.True(t, b.allow(), "a cancelled probe frees the probe slot")
+	assert.False(t, b.allow(), "a cancelled probe does not close the circuit")
+	assert.True(t, b.record(true), "failures are kept across a cancelled probe")
+	assert.False(t, b.allow())
+
+	now = now.Add(31 * time.Second)
+	assert.True(t, b.allow())
+	assert.False(t, b.record(false))
+	assert.True(t, b.allow())
+	assert.True(t, b.allow(), "a successful probe closes the circuit")
+}
+
+func TestRetryDelay(t *testing.T) {
+	cfg := config.RetryConfig{BackoffMillis: 100, MaxBackoffMillis: 500}
+	for _, tt := range []struct {
+		retry int
+		want  time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 500 * time.Millisecond},
+		{60, 500 * time.Millisecond},
+	} {
+		for range 20 {
+			delay := retryDelay(cfg, tt.retry)
+			assert.GreaterOrEqual(t, delay, tt.want/2)
+			assert.LessOrEqual(t, delay, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package reviewer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/surya84/code-reviewer-bot/internal/diffparser"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+// reviewedSHAPrefix starts the hidden marker that records, in the summary comment, the head
+// commit the review covered. The next run reviews only the commits pushed after it.
+const reviewedSHAPrefix = "<!-- code-reviewer-bot:reviewed-sha:"
+
+// reviewedSHAMarker is the hidden marker recording the reviewed head commit.
+func reviewedSHAMarker(sha string) string {
+	return reviewedSHAPrefix + sha + " -->"
+}
+
+// parseReviewedSHA returns the commit recorded in a summary comment, or "" if there is none.
+func parseReviewedSHA(body string) string {
+	_, rest, ok := strings.Cut(body, reviewedSHAPrefix)
+	if !ok {
+		return ""
+	}
+	sha, _, _ := strings.Cut(rest, " -->")
+	return strings.TrimSpace(sha)
+}
+
+// reviewFooter notes the range an incremental review covered and records the reviewed head commit.
+func reviewFooter(baseSHA, headSHA string) string {
+	var b strings.Builder
+	if baseSHA != "" {
+		fmt.Fprintf(&b, "\n_Incremental review of the changes since `%s`._\n", shortSHA(baseSHA))
+	}
+	if headSHA != "" {
+		b.WriteString("\n" + reviewedSHAMarker(headSHA) + "\n")
+	}
+	return b.String()
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// lineSet holds new-file line numbers per path.
+type lineSet map[string]map[int]bool
+
+// addedLines collects the added lines of a diff.
+func addedLines(files []*diffparser.FileDiff) lineSet {
+	set := make(lineSet)
+	for _, chunk := range diffparser.Chunks(files) {
+		for _, l := range chunk.Lines {
+			if l.Kind != diffparser.LineAdded {
+				continue
+			}
+			if set[chunk.FilePath] == nil {
+				set[chunk.FilePath] = make(map[int]bool)
+			}
+			set[chunk.FilePath][l.NewLine] = true
+		}
+	}
+	return set
+}
+
+func (s lineSet) contains(path string, line int) bool {
+	return s[path][line]
+}
+
+// touches reports whether any added line of the chunk is in the set.
+func (s lineSet) touches(chunk *diffparser.DiffChunk) bool {
+	for _, l := range chunk.Lines {
+		if l.Kind == diffparser.LineAdded && s.contains(chunk.FilePath, l.NewLine) {
+			return true
+		}
+	}
+	return false
+}
+
+// changedSince returns the lines added between baseSHA and headSHA. Chunks of the PR diff are
+// still what gets reviewed, so comments keep positions that are valid for the PR; the compare
+// diff only decides which of them to send and which comments to keep. It returns an error when
+// the compare diff is unavailable, including after a force-push, and the caller then falls back
+// to a full review.
+func changedSince(ctx context.Context, vcsClient vcs.VCSAdapter, prDetails *PRDetails, baseSHA, headSHA string) (lineSet, error) {
+	diff, err := vcsClient.GetCompareDiff(ctx, prDetails.Owner, prDetails.Repo, baseSHA, headSHA)
+	if errors.Is(err, vcs.ErrNotAncestor) {
+		return nil, fmt.Errorf("history was rewritten since %s", shortSHA(baseSHA))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return addedLines(diffparser.ParseFiles(diff)), nil
+}
+
+// lastReviewedSHA picks the commit to review from: the explicit base, or else the one recorded
+// in the previous summary.
+func lastReviewedSHA(prDetails *PRDetails, previousSummary *vcs.GeneralComment) string {
+	if prDetails.BaseSHA != "" {
+		return prDetails.BaseSHA
+	}
+	if previousSummary != nil {
+		return parseReviewedSHA(previousSummary.Body)
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+package reviewer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+// functionBDiff is the compare diff of a commit that added function b on top of the early
+// return in a, which duplicateLinesDiff shows as one hunk.
+const functionBDiff = `diff --git a/dup.go b/dup.go
+index 3333333..2222222 100644
+--- a/dup.go
++++ b/dup.go
+@@ -14,2 +14,6 @@ func a() error {
+ 	return nil
+ }
++
++func b() error {
++	return nil
++}
+`
+
+func TestParseReviewedSHA(t *testing.T) {
+	assert.Equal(t, "abc123", parseReviewedSHA(summaryMarker+"\nSummary.\n"+reviewFooter("", "abc123")))
+	assert.Empty(t, parseReviewedSHA(summaryMarker+"\nSummary."))
+}
+
+func TestRunReview_Incremental(t *testing.T) {
+	// The model flags the early return in a (line 12) and the body of b (line 18).
+	newModel := func() *fakeModel {
+		return &fakeModel{respond: func(prompt string) (string, error) {
+			if strings.HasPrefix(prompt, "Review ") {
+				return `{"comments":[
+					{"line":12,"line_content":"","message":"Early return.","severity":"minor","category":"bug","confidence":0.9},
+					{"line":18,"line_content":"","message":"Wrap the error.","severity":"minor","category":"bug","confidence":0.9}
+				]}`, nil
+			}
+			return summaryResponse, nil
+		}}
+	}
+	previousSummary := func(sha string) *vcs.GeneralComment {
+		return &vcs.GeneralComment{ID: 42, Body: summaryMarker + "\nold summary\n" + reviewFooter("", sha)}
+	}
+	lines := func(comments []*vcs.Comment) []int {
+		var got []int
+		for _, c := range comments {
+			got = append(got, c.Line)
+		}
+		return got
+	}
+
+	t.Run("Success - Reviews Only New Commits", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, newModel())
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, existing: previousSummary("base1"), compareDiff: functionBDiff}
+
+		_, err := RunReview(context.Background(), g, &PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1}, cfg, adapter)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"base1..abc123"}, adapter.compared)
+		require.Len(t, adapter.reviews, 1)
+		assert.Equal(t, []int{18}, lines(adapter.reviews[0]), "lines reviewed by earlier runs are not commented on again")
+		assert.Contains(t, adapter.edited[42], "_Incremental review of the changes since `base1`._")
+		assert.Equal(t, "abc123", parseReviewedSHA(adapter.edited[42]))
+	})
+
+	t.Run("Success - Explicit Base Overrides Previous Summary", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, newModel())
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, existing: previousSummary("base1"), compareDiff: functionBDiff}
+
+		_, err := RunReview(context.Background(), g, &PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1, BaseSHA: "base2"}, cfg, adapter)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"base2..abc123"}, adapter.compared)
+	})
+
+	t.Run("Success - Head Already Reviewed", func(t *testing.T) {
+		model := newModel()
+		g, cfg := setupFakeModel(t, model)
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, existing: previousSummary("abc123")}
+
+		msg, err := RunReview(context.Background(), g, &PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1}, cfg, adapter)
+		require.NoError(t, err)
+		assert.Equal(t, "Commit abc123 was already reviewed.", msg)
+		assert.Empty(t, model.prompts)
+		assert.Empty(t, adapter.edited)
+	})
+
+	t.Run("Security - Ignores Reviewed SHA Posted By Another User", func(t *testing.T) {
+		model := newModel()
+		g, cfg := setupFakeModel(t, model)
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, existing: previousSummary("abc123"), existingBy: "someone"}
+
+		_, err := RunReview(context.Background(), g, &PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1}, cfg, adapter)
+		require.NoError(t, err)
+		assert.NotEmpty(t, model.prompts, "a forged marker does not skip the review")
+		assert.Empty(t, adapter.compared)
+		require.Len(t, adapter.reviews, 1)
+		assert.Equal(t, []int{12, 18}, lines(adapter.reviews[0]))
+	})
+
+	t.Run("Fallback - Unknown Bot Account Triggers Full Review", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, newModel())
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, existing: previousSummary("abc123"), userErr: errors.New("forbidden")}
+
+		_, err := RunReview(context.Background(), g, &PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1}, cfg, adapter)
+		require.NoError(t, err)
+		assert.Empty(t, adapter.findings, "summaries are not looked up without the bot's account")
+		require.Len(t, adapter.reviews, 1)
+		assert.Equal(t, []int{12, 18}, lines(adapter.reviews[0]))
+	})
+
+	t.Run("Success - No Reviewable Changes Since Base", func(t *testing.T) {
+		model := newModel()
+		g, cfg := setupFakeModel(t, model)
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, existing: previousSummary("base1"), compareDiff: "diff --git a/other.go b/other.go\n--- a/other.go\n+++ b/other.go\n@@ -1 +1,2 @@\n x\n+y\n"}
+
+		msg, err := RunReview(context.Background(), g, &PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1}, cfg, adapter)
+		require.NoError(t, err)
+		assert.Equal(t, "No reviewable changes found.", msg)
+		assert.Empty(t, model.prompts)
+	})
+
+	t.Run("Fallback - Force Push Triggers Full Review", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, newModel())
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, existing: previousSummary("base1"), compareErr: vcs.ErrNotAncestor}
+
+		_, err := RunReview(context.Background(), g, &PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1}, cfg, adapter)
+		require.NoError(t, err)
+
+		require.Len(t, adapter.reviews, 1)
+		assert.Equal(t, []int{12, 18}, lines(adapter.reviews[0]))
+		assert.NotContains(t, adapter.edited[42], "Incremental review")
+		assert.Equal(t, "abc123", parseReviewedSHA(adapter.edited[42]))
+	})
+}
@@ -0,0 +1,84 @@
+package reviewer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/surya84/code-reviewer-bot/config"
+	"golang.org/x/time/rate"
+)
+
+// providerLimiter enforces a provider's requests-per-minute and tokens-per-minute limits.
+// A nil limiter field means that dimension is unlimited.
+type providerLimiter struct {
+	requests *rate.Limiter
+	tokens   *rate.Limiter
+	maxBurst int
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*providerLimiter)
+)
+
+// limiterFor returns the limiter shared by every review that calls the given provider,
+// so concurrent reviews in the server stay within the provider's quota together.
+func limiterFor(provider string, cfg config.RateLimitConfig) *providerLimiter {
+	key := fmt.Sprintf("%s/%d/%d", provider, cfg.RequestsPerMinute, cfg.TokensPerMinute)
+
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	if l, ok := limiters[key]; ok {
+		return l
+	}
+	l := newProviderLimiter(cfg)
+	limiters[key] = l
+	return l
+}
+
+func newProviderLimiter(cfg config.RateLimitConfig) *providerLimiter {
+	l := &providerLimiter{}
+	if cfg.RequestsPerMinute > 0 {
+		l.requests = rate.NewLimiter(rate.Limit(float64(cfg.RequestsPerMinute)/60), 1)
+	}
+	if cfg.TokensPerMinute > 0 {
+		// Allow a whole minute's budget in one burst so a single large prompt can always proceed.
+		l.tokens = rate.NewLimiter(rate.Limit(float64(cfg.TokensPerMinute)/60), cfg.TokensPerMinute)
+		l.maxBurst = cfg.TokensPerMinute
+	}
+	return l
+}
+
+// wait blocks until a request with the estimated number of tokens may be sent.
+func (l *providerLimiter) wait(ctx context.Context, estimatedTokens int) error {
+	if l.requests != nil {
+		if err := l.requests.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if l.tokens != nil && estimatedTokens > 0 {
+		if err := l.tokens.WaitN(ctx, min(estimatedTokens, l.maxBurst)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record charges tokens the provider reported beyond the estimate taken by wait,
+// which delays later requests instead of letting the quota be overrun.
+func (l *providerLimiter) record(estimatedTokens int, usage *ai.GenerationUsage) {
+	if l.tokens == nil || usage == nil {
+		return
+	}
+	if extra := usage.InputTokens + usage.OutputTokens - estimatedTokens; extra > 0 {
+		l.tokens.ReserveN(time.Now(), min(extra, l.maxBurst))
+	}
+}
+
+// estimateTokens approximates a prompt's token count at roughly four characters per token.
+func estimateTokens(prompt string) int {
+	return len(prompt)/4 + 1
+}
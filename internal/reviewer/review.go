@@ -3,19 +3,23 @@ package reviewer
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"html/template"
-	"log"
-	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
 	"github.com/surya84/code-reviewer-bot/config"
 	"github.com/surya84/code-reviewer-bot/constants"
 	"github.com/surya84/code-reviewer-bot/internal/diffparser"
+	"github.com/surya84/code-reviewer-bot/pkg/metrics"
+	"github.com/surya84/code-reviewer-bot/pkg/tracing"
 	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // PRDetails holds information about the pull request being reviewed.
@@ -23,164 +27,552 @@ type PRDetails struct {
 	Owner    string
 	Repo     string
 	PRNumber int
+	// Title and Body give the summary phase context; both may be empty.
+	Title string
+	Body  string
+	// BaseSHA, when set, limits the review to the changes pushed after this commit. When empty,
+	// the head commit recorded by the previous review is used, if any.
+	BaseSHA string
 }
 
-// ReviewComment represents the structured response from the LLM, based on the line_content prompt.
+// ReviewComment represents the structured response from the LLM. Line is the number shown
+// next to the added line in the snippet; LineContent is only used when Line cannot be resolved.
 type ReviewComment struct {
-	LineContent string `json:"line_content"`
-	Message     string `json:"message"`
+	Line        int     `json:"line"`
+	LineContent string  `json:"line_content"`
+	Message     string  `json:"message"`
+	Severity    string  `json:"severity" jsonschema:"enum=blocker,enum=major,enum=minor,enum=nit"`
+	Category    string  `json:"category" jsonschema:"enum=bug,enum=security,enum=performance,enum=style,enum=naming,enum=docs"`
+	Confidence  float64 `json:"confidence" jsonschema:"minimum=0,maximum=1"`
 }
 
-// RunReview is the main function that orchestrates the entire review process.
+// severityRanks orders severities so thresholds can be compared; unknown severities rank 0.
+var severityRanks = map[string]int{
+	constants.SEVERITY_NIT:     1,
+	constants.SEVERITY_MINOR:   2,
+	constants.SEVERITY_MAJOR:   3,
+	constants.SEVERITY_BLOCKER: 4,
+}
+
+var severityBadges = map[string]string{
+	constants.SEVERITY_BLOCKER: "🛑 Blocker",
+	constants.SEVERITY_MAJOR:   "🔴 Major",
+	constants.SEVERITY_MINOR:   "🟡 Minor",
+	constants.SEVERITY_NIT:     "⚪ Nit",
+}
+
+var categoryBadges = map[string]string{
+	constants.CATEGORY_BUG:         "🐛 Bug",
+	constants.CATEGORY_SECURITY:    "🔒 Security",
+	constants.CATEGORY_PERFORMANCE: "⚡ Performance",
+	constants.CATEGORY_STYLE:       "🎨 Style",
+	constants.CATEGORY_NAMING:      "🏷️ Naming",
+	constants.CATEGORY_DOCS:        "📝 Docs",
+}
+
+// RunReview is the main function that orchestrates the entire review process. When a base
+// commit is known, either from PRDetails.BaseSHA or from the previous summary, only the changes
+// pushed since it are reviewed.
 func RunReview(ctx context.Context, g *genkit.Genkit, prDetails *PRDetails, cfg *config.Config, vcsClient vcs.VCSAdapter) (string, error) {
-	log.Printf("Starting review for PR #%d in %s/%s", prDetails.PRNumber, prDetails.Owner, prDetails.Repo)
+	ctx, span := tracing.Start(ctx, "review",
+		attribute.String("vcs.owner", prDetails.Owner),
+		attribute.String("vcs.repo", prDetails.Repo),
+		attribute.Int("vcs.pr_number", prDetails.PRNumber),
+	)
+	metrics.Reviews.WithLabelValues("started").Inc()
+	msg, err := runReview(ctx, g, prDetails, cfg, vcsClient)
+	metrics.ReviewFinished(ctx, err)
+	tracing.End(span, err)
+	return msg, err
+}
+
+func runReview(ctx context.Context, g *genkit.Genkit, prDetails *PRDetails, cfg *config.Config, vcsClient vcs.VCSAdapter) (string, error) {
+	tracing.Logf(ctx, "Starting review for PR #%d in %s/%s", prDetails.PRNumber, prDetails.Owner, prDetails.Repo)
 
-	commitID, err := vcsClient.GetPRCommitID(ctx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber)
+	spanCtx, span := tracing.Start(ctx, "vcs.GetPRCommitID")
+	commitID, err := vcsClient.GetPRCommitID(spanCtx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber)
+	tracing.End(span, err)
 	if err != nil {
-		log.Printf("Warning: could not get PR commit ID: %v", err)
+		tracing.Logf(ctx, "Warning: could not get PR commit ID: %v", err)
 	} else {
-		log.Printf("Found PR HEAD commit SHA: %s", commitID)
+		tracing.Logf(ctx, "Found PR HEAD commit SHA: %s", commitID)
 	}
 
-	diff, err := vcsClient.GetPRDiff(ctx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber)
+	// Earlier summaries and findings are only trusted when written by the bot's own account,
+	// since anyone on the PR can post a comment carrying the same hidden markers.
+	botUser, err := vcsClient.CurrentUser(ctx)
+	botKnown := err == nil
+	if !botKnown {
+		tracing.Logf(ctx, "Warning: could not identify the bot's account, ignoring earlier reviews: %v", err)
+	}
+	var previousSummary *vcs.GeneralComment
+	if botKnown {
+		previousSummary, err = vcsClient.FindGeneralComment(ctx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber, summaryMarker, botUser)
+		if err != nil {
+			tracing.Logf(ctx, "Warning: could not look up previous summary: %v", err)
+		}
+	}
+	baseSHA := lastReviewedSHA(prDetails, previousSummary)
+	if baseSHA != "" && baseSHA == commitID {
+		return fmt.Sprintf("Commit %s was already reviewed.", shortSHA(commitID)), nil
+	}
+
+	spanCtx, span = tracing.Start(ctx, "vcs.GetPRDiff")
+	diff, err := vcsClient.GetPRDiff(spanCtx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber)
+	span.SetAttributes(attribute.Int("vcs.diff_bytes", len(diff)))
+	tracing.End(span, err)
 	if err != nil {
 		return "", fmt.Errorf("failed to get PR diff: %w", err)
 	}
-	log.Println("Successfully fetched PR diff.")
+	tracing.Logf(ctx, "Successfully fetched PR diff.")
 
-	chunks := diffparser.Parse(diff)
+	files := diffparser.ParseFiles(diff)
+	chunks := diffparser.Chunks(files)
+
+	// changed is nil for a full review; otherwise it holds the lines added since baseSHA.
+	var changed lineSet
+	if baseSHA != "" && commitID != "" {
+		changed, err = changedSince(ctx, vcsClient, prDetails, baseSHA, commitID)
+		if err != nil {
+			tracing.Logf(ctx, "Falling back to a full review: %v", err)
+		} else {
+			tracing.Logf(ctx, "Reviewing only the changes since %s.", shortSHA(baseSHA))
+			var touched []*diffparser.DiffChunk
+			for _, chunk := range chunks {
+				if changed.touches(chunk) {
+					touched = append(touched, chunk)
+				}
+			}
+			chunks = touched
+		}
+	}
+	if changed == nil {
+		baseSHA = ""
+	}
 	if len(chunks) == 0 {
 		return "No reviewable changes found.", nil
 	}
-	log.Printf("Parsed diff into %d chunks.", len(chunks))
+	tracing.Logf(ctx, "Parsed diff into %d chunks.", len(chunks))
 
-	var allComments []*vcs.Comment
-	for _, chunk := range chunks {
-		comments, err := analyzeChunk(ctx, g, cfg, chunk)
-		if err != nil {
-			log.Printf("Error analyzing chunk for file %s: %v", chunk.FilePath, err)
-			continue
-		}
+	allComments, failed := reviewChunks(ctx, g, cfg, chunks)
+	if err := ctx.Err(); err != nil {
+		// A cancelled review must not post the partial results it has.
+		return "", fmt.Errorf("review was cancelled: %w", err)
+	}
+	if len(failed) > 0 {
+		tracing.Logf(ctx, "Could not review %d of %d chunks.", len(failed), len(chunks))
+	}
 
-		for _, llmComment := range comments {
-			// Find both the position-in-hunk and the absolute file line number for the commented line.
-			positionInHunk, fileLineNumber, err := findLocationForLineContent(chunk, llmComment.LineContent)
-			if err != nil {
-				log.Printf("Could not find location for line content in file %s: %v", chunk.FilePath, err)
-				continue
+	unreviewed := make(map[string]bool, len(failed))
+	for _, chunk := range failed {
+		unreviewed[chunk.FilePath] = true
+	}
+	inScope := func(path string, line int) bool {
+		return !unreviewed[path] && (changed == nil || changed.contains(path, line))
+	}
+	if changed != nil {
+		// Hunks are reviewed whole, but lines from earlier commits were covered by earlier runs.
+		var current []*vcs.Comment
+		for _, c := range allComments {
+			if changed.contains(c.Path, c.Line) {
+				current = append(current, c)
 			}
-			// Create a comment object with all necessary information for any VCS.
-			allComments = append(allComments, &vcs.Comment{
-				Body:     llmComment.Message,
-				Path:     chunk.FilePath,
-				Position: positionInHunk, // For GitHub
-				Line:     fileLineNumber, // For Gitea
-			})
 		}
+		allComments = current
 	}
 
-	if len(allComments) > 0 {
-		log.Printf("Submitting a review with %d comments.", len(allComments))
-		err := vcsClient.PostReview(ctx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber, allComments, commitID)
+	newComments := withFindingMarkers(allComments)
+	if botKnown {
+		newComments = reconcileComments(ctx, vcsClient, prDetails, botUser, allComments, inScope)
+	}
+	if len(newComments) > 0 {
+		tracing.Logf(ctx, "Submitting a review with %d comments.", len(newComments))
+		spanCtx, span := tracing.Start(ctx, "vcs.PostReview", attribute.Int("review.comments", len(newComments)))
+		err := vcsClient.PostReview(spanCtx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber, newComments, commitID)
+		tracing.End(span, err)
 		if err != nil {
 			return "", fmt.Errorf("failed to post review: %w", err)
 		}
-	} else {
-		log.Println("No comments to post. Submitting a general comment.")
-		vcsClient.PostGeneralComment(ctx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber, "✅ AI Review Complete: No issues found. Great work!")
+		for _, c := range newComments {
+			metrics.CommentsPosted.WithLabelValues(c.Severity).Inc()
+		}
+	}
+
+	// Chunks that could not be reviewed are covered by the next run only if this one does not
+	// record the head commit as reviewed.
+	reviewedSHA := commitID
+	if len(failed) > 0 {
+		reviewedSHA = baseSHA
+	}
+	summary, err := summarizePR(ctx, g, cfg, prDetails, files, allComments, failed)
+	if err != nil {
+		tracing.Logf(ctx, "Warning: could not generate PR summary, posting a short one instead: %v", err)
+		summary = fallbackSummary(allComments, failed)
+	}
+	if err := postSummary(ctx, vcsClient, prDetails, previousSummary, summary+reviewFooter(baseSHA, reviewedSHA)); err != nil {
+		tracing.Logf(ctx, "Warning: could not post PR summary: %v", err)
+	}
+
+	if len(failed) == len(chunks) {
+		// Nothing was reviewed, so the run is reported as failed and can be retried later.
+		return "", fmt.Errorf("could not review any of the %d chunks", len(chunks))
 	}
 
-	resultMessage := fmt.Sprintf("Review complete. Submitted %d comments.", len(allComments))
-	log.Println(resultMessage)
+	resultMessage := fmt.Sprintf("Review complete. Submitted %d comments.", len(newComments))
+	tracing.Logf(ctx, "%s", resultMessage)
 	return resultMessage, nil
 }
 
-// sanitizeJSONString removes common JSON formatting errors from LLM responses.
-func sanitizeJSONString(s string) string {
-	startIndex := strings.Index(s, "[")
-	endIndex := strings.LastIndex(s, "]")
-	if startIndex == -1 || endIndex == -1 || endIndex < startIndex {
+// ReviewDiff runs the chunk analysis pipeline on a raw unified diff and returns the
+// located comments without posting them anywhere, along with the chunks the model could
+// not review. Like RunReview, it fails when none of the chunks could be reviewed.
+func ReviewDiff(ctx context.Context, g *genkit.Genkit, cfg *config.Config, diff string) ([]*vcs.Comment, []*diffparser.DiffChunk, error) {
+	chunks := diffparser.Parse(diff)
+	tracing.Logf(ctx, "Parsed diff into %d chunks.", len(chunks))
+	comments, failed := reviewChunks(ctx, g, cfg, chunks)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, fmt.Errorf("review was cancelled: %w", err)
+	}
+	for _, chunk := range failed {
+		tracing.Logf(ctx, "Could not review %s.", chunkLocation(chunk))
+	}
+	if len(chunks) > 0 && len(failed) == len(chunks) {
+		return nil, failed, fmt.Errorf("could not review any of the %d chunks", len(chunks))
+	}
+	return comments, failed, nil
+}
+
+// reviewChunks analyzes chunks concurrently with the LLM and maps the returned comments back
+// to diff locations. Comments keep the order of the chunks, and a chunk that fails is logged
+// and skipped without affecting the others. Chunks that failed or were never analyzed because
+// ctx was cancelled are returned alongside the comments.
+func reviewChunks(ctx context.Context, g *genkit.Genkit, cfg *config.Config, chunks []*diffparser.DiffChunk) ([]*vcs.Comment, []*diffparser.DiffChunk) {
+	workers := cfg.LLM.Workers
+	if workers <= 0 {
+		workers = config.DefaultLLMWorkers
+	}
+	workers = min(workers, len(chunks))
+
+	results := make([][]*vcs.Comment, len(chunks))
+	reviewed := make([]bool, len(chunks))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				comments, err := reviewChunk(ctx, g, cfg, chunks[idx])
+				if err != nil {
+					metrics.ChunksAnalyzed.WithLabelValues("failed").Inc()
+					tracing.Logf(ctx, "Error analyzing chunk for file %s: %v", chunks[idx].FilePath, err)
+					continue
+				}
+				metrics.ChunksAnalyzed.WithLabelValues("ok").Inc()
+				results[idx], reviewed[idx] = comments, true
+			}
+		}()
+	}
+	for idx := range chunks {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+
+	var allComments []*vcs.Comment
+	var failed []*diffparser.DiffChunk
+	for idx, comments := range results {
+		if !reviewed[idx] {
+			failed = append(failed, chunks[idx])
+		}
+		allComments = append(allComments, comments...)
+	}
+	return allComments, failed
+}
+
+// reviewChunk analyzes a single chunk and locates its comments in the diff.
+func reviewChunk(ctx context.Context, g *genkit.Genkit, cfg *config.Config, chunk *diffparser.DiffChunk) ([]*vcs.Comment, error) {
+	comments, err := analyzeChunk(ctx, g, cfg, chunk)
+	if err != nil {
+		return nil, err
+	}
+	return locateComments(ctx, cfg, chunk, comments), nil
+}
+
+// locateComments maps the model's comments back to positions in the chunk, dropping those
+// below the configured thresholds and those whose line cannot be found.
+func locateComments(ctx context.Context, cfg *config.Config, chunk *diffparser.DiffChunk, comments []ReviewComment) []*vcs.Comment {
+	ctx, span := tracing.Start(ctx, "locateComments",
+		attribute.String("code.filepath", chunk.FilePath),
+		attribute.Int("review.comments", len(comments)),
+	)
+	defer span.End()
+
+	var located []*vcs.Comment
+	for _, llmComment := range comments {
+		if reason := belowThreshold(cfg.Review, llmComment); reason != "" {
+			tracing.Logf(ctx, "Dropping comment on %s: %s", chunk.FilePath, reason)
+			continue
+		}
+		// Find both the position-in-hunk and the absolute file line number for the commented line.
+		positionInHunk, fileLineNumber, err := findLocation(ctx, chunk, llmComment)
+		if err != nil {
+			tracing.Logf(ctx, "Could not find location for line content in file %s: %v", chunk.FilePath, err)
+			continue
+		}
+		// Create a comment object with all necessary information for any VCS.
+		located = append(located, &vcs.Comment{
+			Body:        formatCommentBody(llmComment),
+			Path:        chunk.FilePath,
+			OldPath:     renamedFrom(chunk),
+			Position:    positionInHunk, // For GitHub
+			Line:        fileLineNumber, // For Gitea
+			Severity:    llmComment.Severity,
+			Category:    llmComment.Category,
+			Confidence:  llmComment.Confidence,
+			Fingerprint: fingerprint(chunk.FilePath, fileLineNumber, llmComment.Category, lineContentAt(chunk, positionInHunk)),
+		})
+	}
+	span.SetAttributes(attribute.Int("review.located_comments", len(located)))
+	return located
+}
+
+// renamedFrom returns the chunk's file path before a rename, or an empty string if the file
+// was not renamed.
+func renamedFrom(chunk *diffparser.DiffChunk) string {
+	if chunk.File == nil || chunk.File.OldPath == chunk.FilePath {
 		return ""
 	}
-	s = s[startIndex : endIndex+1]
-	replacer := strings.NewReplacer("\n", " ", "\t", " ", "\r", " ")
-	s = replacer.Replace(s)
-	re := regexp.MustCompile(`,(\s*[\}\]])`)
-	return re.ReplaceAllString(s, "$1")
+	return chunk.File.OldPath
+}
+
+// lineContentAt returns the content of the chunk line at a diff position.
+func lineContentAt(chunk *diffparser.DiffChunk, position int) string {
+	for _, l := range chunk.Lines {
+		if l.Position == position {
+			return l.Content
+		}
+	}
+	return ""
+}
+
+// belowThreshold reports why a comment falls below the configured minimum confidence or
+// severity, or returns an empty string if it should be posted.
+func belowThreshold(cfg config.ReviewConfig, c ReviewComment) string {
+	if c.Confidence < cfg.MinConfidence {
+		return fmt.Sprintf("confidence %.2f is below %.2f", c.Confidence, cfg.MinConfidence)
+	}
+	if cfg.MinSeverity != "" && severityRanks[c.Severity] < severityRanks[cfg.MinSeverity] {
+		return fmt.Sprintf("severity '%s' is below '%s'", c.Severity, cfg.MinSeverity)
+	}
+	return ""
+}
+
+// formatCommentBody renders the comment message under a line of severity, category and confidence badges.
+func formatCommentBody(c ReviewComment) string {
+	badges := []string{"**" + severityBadges[c.Severity] + "**", categoryBadges[c.Category], fmt.Sprintf("%.0f%% confidence", c.Confidence*100)}
+	return strings.Join(badges, " · ") + "\n\n" + c.Message
 }
 
-// analyzeChunk sends a single diff chunk to the LLM.
-func analyzeChunk(ctx context.Context, g *genkit.Genkit, cfg *config.Config, chunk *diffparser.DiffChunk) ([]ReviewComment, error) {
-	prompt, err := preparePrompt(cfg.ReviewPrompt, chunk.FilePath, chunk.CodeSnippet)
+// reviewOutput is the structured response the model is asked to produce. The comments are
+// wrapped in an object because several providers only enforce JSON mode for objects.
+type reviewOutput struct {
+	Comments []ReviewComment `json:"comments"`
+}
+
+// maxRepairAttempts is how many times the model is re-asked after a response fails validation.
+const maxRepairAttempts = 2
+
+// analyzeChunk sends a single diff chunk to the LLM and returns its validated comments.
+func analyzeChunk(ctx context.Context, g *genkit.Genkit, cfg *config.Config, chunk *diffparser.DiffChunk) (comments []ReviewComment, err error) {
+	ctx, span := tracing.Start(ctx, "analyzeChunk", attribute.String("code.filepath", chunk.FilePath))
+	defer func() { tracing.End(span, err) }()
+
+	prompt, err := preparePrompt(cfg.ReviewPrompt, chunk.FilePath, numberSnippet(chunk))
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare prompt: %w", err)
 	}
 
-	res, err := genkit.Generate(ctx, g, ai.WithModelName(cfg.LLM.ModelName), ai.WithPrompt(prompt))
+	out, err := generateStructured(ctx, g, cfg, prompt, validateReviewOutput)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate LLM response: %w", err)
+		return nil, err
 	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate LLM response: %w", err)
+	return out.Comments, nil
+}
+
+// generateStructured asks the model for a response matching Out's JSON schema, failing over
+// to the configured fallbacks as described at generateWithFallback. Responses that fail the
+// schema or validate are counted and sent back to the model with the error, up to
+// maxRepairAttempts times. The model, prompt tokens and response size are recorded on the
+// span in ctx.
+func generateStructured[Out any](ctx context.Context, g *genkit.Genkit, cfg *config.Config, prompt string, validate func(*Out) error) (*Out, error) {
+	span := trace.SpanFromContext(ctx)
+	promptTokens := 0
+	request := prompt
+	for attempt := 0; ; attempt++ {
+		out, call, model, err := generateWithFallback[Out](ctx, g, cfg, request)
+		span.SetAttributes(attribute.String("llm.model", model))
+		if call == nil {
+			return nil, fmt.Errorf("failed to generate LLM response: %w", err)
+		}
+		if call.usage != nil && call.usage.InputTokens > 0 {
+			promptTokens += call.usage.InputTokens
+		} else {
+			promptTokens += estimateTokens(request)
+		}
+		span.SetAttributes(
+			attribute.Int("llm.attempts", attempt+1),
+			attribute.Int("llm.prompt_tokens", promptTokens),
+			attribute.Int("llm.response_bytes", len(call.text)),
+		)
+		if err == nil {
+			err = validate(out)
+		}
+		if err == nil {
+			return out, nil
+		}
+
+		metrics.LLMParseFailures.WithLabelValues(model).Inc()
+		tracing.Logf(ctx, "LLM response did not match the expected schema (attempt %d): %v. Raw response: '%s'", attempt+1, err, call.text)
+		if attempt == maxRepairAttempts {
+			return nil, fmt.Errorf("LLM response did not match the expected schema after %d attempts: %w", attempt+1, err)
+		}
+		request = repairPrompt(prompt, call.text, err)
 	}
+}
+
+// modelCall is the raw result of a successful model call, before schema validation.
+type modelCall struct {
+	text  string
+	usage *ai.GenerationUsage
+}
 
-	responseText := res.Text()
-	if responseText == "" {
-		return nil, fmt.Errorf("failed to get text from LLM response")
+// captureModelCall is model middleware that records the model's raw response text and
+// token usage before Genkit validates the response against the output schema. It also
+// reports the call's latency and token usage to the metrics.
+func captureModelCall(model string, call **modelCall) ai.ModelMiddleware {
+	return func(next ai.ModelFunc) ai.ModelFunc {
+		return func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			start := time.Now()
+			resp, err := next(ctx, req, cb)
+			if err == nil && resp != nil {
+				*call = &modelCall{text: resp.Text(), usage: resp.Usage}
+				var input, output int
+				if resp.Usage != nil {
+					input, output = resp.Usage.InputTokens, resp.Usage.OutputTokens
+				}
+				metrics.ObserveLLMCall(model, time.Since(start), input, output)
+			}
+			return resp, err
+		}
+	}
+}
+
+// validateReviewOutput checks the constraints the JSON schema cannot express.
+func validateReviewOutput(out *reviewOutput) error {
+	if out == nil {
+		return fmt.Errorf("response is empty")
+	}
+	for i, c := range out.Comments {
+		if strings.TrimSpace(c.Message) == "" {
+			return fmt.Errorf("comments[%d]: message is empty", i)
+		}
+		if c.Line <= 0 && strings.TrimSpace(c.LineContent) == "" {
+			return fmt.Errorf("comments[%d]: neither line nor line_content is set", i)
+		}
+		if _, ok := severityBadges[c.Severity]; !ok {
+			return fmt.Errorf("comments[%d]: unknown severity '%s'", i, c.Severity)
+		}
+		if _, ok := categoryBadges[c.Category]; !ok {
+			return fmt.Errorf("comments[%d]: unknown category '%s'", i, c.Category)
+		}
+		if c.Confidence < 0 || c.Confidence > 1 {
+			return fmt.Errorf("comments[%d]: confidence %v is not between 0 and 1", i, c.Confidence)
+		}
 	}
+	return nil
+}
+
+// repairPrompt re-asks the model with its rejected response and the reason it was rejected.
+func repairPrompt(prompt, response string, validationErr error) string {
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\nYour previous response could not be used:\n")
+	b.WriteString(response)
+	b.WriteString("\n\nIt was rejected because: ")
+	b.WriteString(validationErr.Error())
+	b.WriteString("\nRespond again with only a JSON object that follows the required schema.")
+	return b.String()
+}
 
-	sanitizedJSON := sanitizeJSONString(responseText)
-	if sanitizedJSON == "" {
-		log.Printf("Could not find valid JSON array in LLM response. Raw response: '%s'", responseText)
-		return nil, nil
+// numberSnippet renders a hunk for the prompt with the new-file line number of every added
+// line in a left-hand gutter, so the model can anchor its comments by number.
+func numberSnippet(chunk *diffparser.DiffChunk) string {
+	width := len(strconv.Itoa(chunk.StartLineNew + len(chunk.Lines)))
+	blank := strings.Repeat(" ", width)
+
+	var b strings.Builder
+	b.WriteString(strings.SplitN(chunk.CodeSnippet, "\n", 2)[0])
+	for _, line := range chunk.Lines {
+		b.WriteString("\n")
+		if line.Kind == diffparser.LineAdded {
+			fmt.Fprintf(&b, "%*d", width, line.NewLine)
+		} else {
+			b.WriteString(blank)
+		}
+		b.WriteString(" | ")
+		b.WriteByte(byte(line.Kind))
+		b.WriteString(line.Content)
 	}
+	return b.String()
+}
 
-	var comments []ReviewComment
-	if err := json.Unmarshal([]byte(sanitizedJSON), &comments); err != nil {
-		log.Printf("Failed to unmarshal sanitized JSON. Sanitized: '%s', Error: %v", sanitizedJSON, err)
-		return nil, fmt.Errorf("failed to parse LLM JSON response: %w", err)
+// findLocation resolves an LLM comment to a diff position and absolute file line number.
+// The line number from the numbered snippet is authoritative; content matching is only
+// used when the model omitted the number or returned one that is not an added line.
+func findLocation(ctx context.Context, chunk *diffparser.DiffChunk, comment ReviewComment) (int, int, error) {
+	if comment.Line > 0 {
+		for _, line := range chunk.Lines {
+			if line.Kind == diffparser.LineAdded && line.NewLine == comment.Line {
+				return line.Position, line.NewLine, nil
+			}
+		}
+		tracing.Logf(ctx, "LLM returned line %d which is not an added line in %s; falling back to content match", comment.Line, chunk.FilePath)
 	}
-	return comments, nil
+	return findLocationForLineContent(chunk, comment.LineContent)
 }
 
-// findLocationForLineContent iterates through a diff hunk to find both the 1-based position
-// and the absolute file line number for a specific line of code.
+// findLocationForLineContent searches a diff hunk's added lines for one matching the given
+// content and returns its diff position and absolute file line number. Content that matches
+// more than one added line is rejected rather than guessed.
 func findLocationForLineContent(chunk *diffparser.DiffChunk, lineContent string) (int, int, error) {
 	normalize := func(s string) string {
 		return strings.Join(strings.Fields(s), " ")
 	}
 
-	target := normalize(lineContent)
+	// The model may or may not echo the leading '+'.
+	target := normalize(strings.TrimPrefix(strings.TrimSpace(lineContent), "+"))
 	if target == "" {
 		return -1, -1, fmt.Errorf("LLM provided empty line content")
 	}
 
-	lines := strings.Split(chunk.CodeSnippet, "\n")
-	hunkPosition := 0
-	currentFileNumber := chunk.StartLineNew
-
-	for i, line := range lines {
-		hunkPosition = i + 1 // 1-based position within the hunk
-
-		if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "-") {
+	var match *diffparser.DiffLine
+	for i, line := range chunk.Lines {
+		if line.Kind != diffparser.LineAdded || normalize(line.Content) != target {
 			continue
 		}
-
-		// Normalize the current line from the diff for comparison.
-		current := normalize(line)
-		if current == target {
-			// Ensure the matched line is an added line, which is what we should be commenting on.
-			if !strings.HasPrefix(strings.TrimSpace(line), "+") {
-				return -1, -1, fmt.Errorf("matched line is not an added line ('+'): '%s'", line)
-			}
-			// Return both the position in the hunk and the absolute file line number.
-			return hunkPosition, currentFileNumber, nil
+		if match != nil {
+			return -1, -1, fmt.Errorf("line content matches several added lines in diff hunk: '%s'", lineContent)
 		}
-
-		// Increment the file line counter for both context (' ') and added ('+') lines.
-		currentFileNumber++
+		match = &chunk.Lines[i]
+	}
+	if match == nil {
+		return -1, -1, fmt.Errorf("line content not found among added lines in diff hunk: '%s'", lineContent)
 	}
-	return -1, -1, fmt.Errorf("line content not found in diff hunk: '%s'", lineContent)
+	return match.Position, match.NewLine, nil
 }
 
 // preparePrompt populates the Go template for the LLM prompt.
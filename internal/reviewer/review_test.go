@@ -0,0 +1,378 @@
+package reviewer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/internal/diffparser"
+	"github.com/surya84/code-reviewer-bot/pkg/metrics"
+)
+
+// duplicateLinesDiff adds two identical "return nil" lines and two closing braces.
+const duplicateLinesDiff = `diff --git a/dup.go b/dup.go
+index 1111111..2222222 100644
+--- a/dup.go
++++ b/dup.go
+@@ -10,4 +10,10 @@ package dup
+ func a() error {
++	if x {
++		return nil
++	}
+ 	return nil
+ }
++
++func b() error {
++	return nil
++}
+`
+
+func parseSingleChunk(t *testing.T, diff string) *diffparser.DiffChunk {
+	chunks := diffparser.Parse(diff)
+	require.Len(t, chunks, 1)
+	return chunks[0]
+}
+
+func TestNumberSnippet(t *testing.T) {
+	chunk := parseSingleChunk(t, duplicateLinesDiff)
+
+	want := "@@ -10,4 +10,10 @@ package dup\n" +
+		"   |  func a() error {\n" +
+		"11 | +\tif x {\n" +
+		"12 | +\t\treturn nil\n" +
+		"13 | +\t}\n" +
+		"   |  \treturn nil\n" +
+		"   |  }\n" +
+		"16 | +\n" +
+		"17 | +func b() error {\n" +
+		"18 | +\treturn nil\n" +
+		"19 | +}"
+	assert.Equal(t, want, numberSnippet(chunk))
+}
+
+func TestFindLocation(t *testing.T) {
+	chunk := parseSingleChunk(t, duplicateLinesDiff)
+
+	tests := []struct {
+		name         string
+		comment      ReviewComment
+		wantPosition int
+		wantLine     int
+		wantErr      bool
+	}{
+		{
+			name:         "line number picks the second duplicate",
+			comment:      ReviewComment{Line: 18, LineContent: "+\treturn nil"},
+			wantPosition: 9,
+			wantLine:     18,
+		},
+		{
+			name:         "line number picks the first duplicate",
+			comment:      ReviewComment{Line: 12, LineContent: "+\t\treturn nil"},
+			wantPosition: 3,
+			wantLine:     12,
+		},
+		{
+			name:         "line number wins over paraphrased content",
+			comment:      ReviewComment{Line: 19, LineContent: "closing brace"},
+			wantPosition: 10,
+			wantLine:     19,
+		},
+		{
+			name:         "invalid line number falls back to unique content",
+			comment:      ReviewComment{Line: 99, LineContent: "+func b() error {"},
+			wantPosition: 8,
+			wantLine:     17,
+		},
+		{
+			name:         "context line number falls back to content",
+			comment:      ReviewComment{Line: 14, LineContent: "if x {"},
+			wantPosition: 2,
+			wantLine:     11,
+		},
+		{
+			name:    "ambiguous content without line number is rejected",
+			comment: ReviewComment{LineContent: "+\treturn nil"},
+			wantErr: true,
+		},
+		{
+			name:    "content of a context line is rejected",
+			comment: ReviewComment{Line: 15, LineContent: " }"},
+			wantErr: true,
+		},
+		{
+			name:    "empty comment anchor is rejected",
+			comment: ReviewComment{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position, line, err := findLocation(context.Background(), chunk, tt.comment)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPosition, position)
+			assert.Equal(t, tt.wantLine, line)
+		})
+	}
+}
+
+// fakeModel is a scripted Genkit model that records the prompts it saw. It answers from
+// respond when set, otherwise with the next of responses, after sleeping for latency.
+type fakeModel struct {
+	responses []string
+	respond   func(prompt string) (string, error)
+	err       error
+	latency   time.Duration
+
+	mu       sync.Mutex
+	prompts  []string
+	inFlight int
+	peak     int
+}
+
+func (m *fakeModel) generate(ctx context.Context, req *ai.ModelRequest) (*ai.ModelResponse, error) {
+	prompt := req.Messages[len(req.Messages)-1].Text()
+	m.mu.Lock()
+	m.prompts = append(m.prompts, prompt)
+	m.inFlight++
+	m.peak = max(m.peak, m.inFlight)
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.inFlight--
+		m.mu.Unlock()
+	}()
+
+	time.Sleep(m.latency)
+	if m.err != nil {
+		return nil, m.err
+	}
+	var text string
+	if m.respond != nil {
+		var err error
+		if text, err = m.respond(prompt); err != nil {
+			return nil, err
+		}
+	} else {
+		m.mu.Lock()
+		if len(m.responses) == 0 {
+			m.mu.Unlock()
+			return nil, errors.New("unexpected model call")
+		}
+		text = m.responses[0]
+		m.responses = m.responses[1:]
+		m.mu.Unlock()
+	}
+	return &ai.ModelResponse{Request: req, Message: ai.NewModelTextMessage(text)}, nil
+}
+
+// setupFakeModel registers a fake model and returns a config that points the reviewer at it.
+func setupFakeModel(t *testing.T, model *fakeModel) (*genkit.Genkit, *config.Config) {
+	g, err := genkit.Init(context.Background())
+	require.NoError(t, err)
+
+	info := &ai.ModelInfo{Supports: &ai.ModelSupports{Constrained: ai.ConstrainedSupportAll}}
+	genkit.DefineModel(g, "fake", "reviewer", info, func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+		return model.generate(ctx, req)
+	})
+
+	cfg := &config.Config{ReviewPrompt: "Review {{.FilePath}}:\n{{.CodeSnippet}}"}
+	cfg.LLM.ModelName = "fake/reviewer"
+	return g, cfg
+}
+
+func TestAnalyzeChunk(t *testing.T) {
+	chunk := parseSingleChunk(t, duplicateLinesDiff)
+
+	t.Run("Success - Structured Output", func(t *testing.T) {
+		model := &fakeModel{responses: []string{`{"comments":[{"line":18,"line_content":"+\treturn nil","message":"Wrap the error.","severity":"minor","category":"bug","confidence":0.8}]}`}}
+		g, cfg := setupFakeModel(t, model)
+
+		comments, err := analyzeChunk(context.Background(), g, cfg, chunk)
+		require.NoError(t, err)
+		assert.Equal(t, []ReviewComment{{Line: 18, LineContent: "+\treturn nil", Message: "Wrap the error.", Severity: "minor", Category: "bug", Confidence: 0.8}}, comments)
+		require.Len(t, model.prompts, 1)
+		assert.Contains(t, model.prompts[0], "Review dup.go:")
+	})
+
+	t.Run("Success - Repaired After Invalid Response", func(t *testing.T) {
+		model := &fakeModel{responses: []string{
+			`[{"line": 18, "message": "trailing comma",}]`,
+			`{"comments":[{"line":12,"line_content":"","message":"   ","severity":"nit","category":"style","confidence":1}]}`,
+			`{"comments":[]}`,
+		}}
+		g, cfg := setupFakeModel(t, model)
+		before := parseFailureCount(cfg.LLM.ModelName)
+
+		comments, err := analyzeChunk(context.Background(), g, cfg, chunk)
+		require.NoError(t, err)
+		assert.Empty(t, comments)
+		require.Len(t, model.prompts, 3)
+		assert.Contains(t, model.prompts[1], `[{"line": 18, "message": "trailing comma",}]`)
+		assert.Contains(t, model.prompts[2], "comments[0]: message is empty")
+		assert.Equal(t, before+2, parseFailureCount(cfg.LLM.ModelName))
+	})
+
+	t.Run("Failure - Gives Up After Repair Attempts", func(t *testing.T) {
+		model := &fakeModel{responses: []string{"not json", "still not json", "nope"}}
+		g, cfg := setupFakeModel(t, model)
+		before := parseFailureCount(cfg.LLM.ModelName)
+
+		_, err := analyzeChunk(context.Background(), g, cfg, chunk)
+		assert.ErrorContains(t, err, "after 3 attempts")
+		assert.Len(t, model.prompts, maxRepairAttempts+1)
+		assert.Equal(t, before+3, parseFailureCount(cfg.LLM.ModelName))
+	})
+
+	t.Run("Failure - Model Error Is Not Retried", func(t *testing.T) {
+		model := &fakeModel{err: errors.New("quota exceeded")}
+		g, cfg := setupFakeModel(t, model)
+
+		_, err := analyzeChunk(context.Background(), g, cfg, chunk)
+		assert.ErrorContains(t, err, "quota exceeded")
+		assert.Len(t, model.prompts, 1)
+	})
+}
+
+func parseFailureCount(model string) float64 {
+	return testutil.ToFloat64(metrics.LLMParseFailures.WithLabelValues(model))
+}
+
+// multiFileDiff builds a diff that adds one line to each of n files named file0.go, file1.go, ...
+func multiFileDiff(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "diff --git a/file%[1]d.go b/file%[1]d.go\n--- a/file%[1]d.go\n+++ b/file%[1]d.go\n@@ -1 +1,2 @@\n package p\n+var x%[1]d = %[1]d\n", i)
+	}
+	return b.String()
+}
+
+func TestReviewChunks_Concurrent(t *testing.T) {
+	const files = 8
+	model := &fakeModel{
+		latency: 50 * time.Millisecond,
+		respond: func(prompt string) (string, error) {
+			var i int
+			if _, err := fmt.Sscanf(prompt, "Review file%d.go", &i); err != nil {
+				return "", err
+			}
+			if i == 3 {
+				return "", errors.New("provider unavailable")
+			}
+			return fmt.Sprintf(`{"comments":[{"line":2,"line_content":"+var x%d = %d","message":"file %d","severity":"major","category":"bug","confidence":0.9}]}`, i, i, i), nil
+		},
+	}
+	g, cfg := setupFakeModel(t, model)
+	cfg.LLM.Workers = 4
+
+	start := time.Now()
+	comments, failed, err := ReviewDiff(context.Background(), g, cfg, multiFileDiff(files))
+	elapsed := time.Since(start)
+	require.NoError(t, err)
+
+	var bodies []string
+	for _, c := range comments {
+		bodies = append(bodies, strings.TrimPrefix(c.Body, "**🔴 Major** · 🐛 Bug · 90% confidence\n\n"))
+		assert.Equal(t, 2, c.Line)
+	}
+	// The failed chunk is skipped; the rest keep the diff's order.
+	assert.Equal(t, []string{"file 0", "file 1", "file 2", "file 4", "file 5", "file 6", "file 7"}, bodies)
+	require.Len(t, failed, 1)
+	assert.Equal(t, "file3.go", failed[0].FilePath)
+	assert.Equal(t, 4, model.peak)
+	assert.Less(t, elapsed, time.Duration(files)*model.latency)
+}
+
+func TestProviderLimiter(t *testing.T) {
+	t.Run("Requests Per Minute", func(t *testing.T) {
+		// 1200 requests per minute allows one request every 50ms.
+		l := newProviderLimiter(config.RateLimitConfig{RequestsPerMinute: 1200})
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			require.NoError(t, l.wait(context.Background(), 100))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 140*time.Millisecond)
+	})
+
+	t.Run("Tokens Per Minute", func(t *testing.T) {
+		// 6000 tokens per minute refills 100 tokens every second.
+		l := newProviderLimiter(config.RateLimitConfig{TokensPerMinute: 6000})
+		require.NoError(t, l.wait(context.Background(), 5990))
+		start := time.Now()
+		require.NoError(t, l.wait(context.Background(), 20))
+		assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	})
+
+	t.Run("Reported Usage Delays Later Requests", func(t *testing.T) {
+		l := newProviderLimiter(config.RateLimitConfig{TokensPerMinute: 6000})
+		require.NoError(t, l.wait(context.Background(), 10))
+		l.record(10, &ai.GenerationUsage{InputTokens: 5000, OutputTokens: 1000})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.Error(t, l.wait(ctx, 100))
+	})
+
+	t.Run("No Limits", func(t *testing.T) {
+		l := newProviderLimiter(config.RateLimitConfig{})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.NoError(t, l.wait(ctx, 1_000_000))
+	})
+}
+
+func TestReviewChunk_Thresholds(t *testing.T) {
+	chunk := parseSingleChunk(t, duplicateLinesDiff)
+	model := &fakeModel{respond: func(string) (string, error) {
+		return `{"comments":[
+			{"line":11,"line_content":"","message":"Blocker.","severity":"blocker","category":"security","confidence":0.95},
+			{"line":12,"line_content":"","message":"Unsure.","severity":"major","category":"bug","confidence":0.3},
+			{"line":17,"line_content":"","message":"Nit.","severity":"nit","category":"naming","confidence":0.9},
+			{"line":18,"line_content":"","message":"Minor.","severity":"minor","category":"performance","confidence":0.6}
+		]}`, nil
+	}}
+	g, cfg := setupFakeModel(t, model)
+
+	tests := []struct {
+		name   string
+		review config.ReviewConfig
+		want   []string
+	}{
+		{name: "no thresholds", want: []string{"blocker", "major", "nit", "minor"}},
+		{name: "min confidence", review: config.ReviewConfig{MinConfidence: 0.5}, want: []string{"blocker", "nit", "minor"}},
+		{name: "min severity", review: config.ReviewConfig{MinSeverity: "minor"}, want: []string{"blocker", "major", "minor"}},
+		{name: "both", review: config.ReviewConfig{MinConfidence: 0.7, MinSeverity: "major"}, want: []string{"blocker"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Review = tt.review
+			comments, err := reviewChunk(context.Background(), g, cfg, chunk)
+			require.NoError(t, err)
+			var got []string
+			for _, c := range comments {
+				got = append(got, c.Severity)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatCommentBody(t *testing.T) {
+	body := formatCommentBody(ReviewComment{Message: "Do not log secrets.", Severity: "blocker", Category: "security", Confidence: 0.875})
+	assert.Equal(t, "**🛑 Blocker** · 🔒 Security · 88% confidence\n\nDo not log secrets.", body)
+}
@@ -0,0 +1,296 @@
+package reviewer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/internal/diffparser"
+	"github.com/surya84/code-reviewer-bot/pkg/tracing"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+// summaryMarker is a hidden HTML comment that identifies the bot's summary so later runs
+// on the same PR can edit it in place.
+const summaryMarker = "<!-- code-reviewer-bot:summary -->"
+
+// maxTopIssues caps the number of issues listed in the summary.
+const maxTopIssues = 5
+
+// defaultSummaryPrompt is used when the configuration does not set summary_prompt.
+const defaultSummaryPrompt = `You are an expert code reviewer writing the summary of a pull request review.
+
+Pull request title: {{.Title}}
+Pull request description:
+{{.Body}}
+
+Changed files:
+{{range .Files}}- {{.Path}} ({{.Status}}, +{{.Added}}/-{{.Deleted}})
+{{end}}
+Findings from the line-by-line review:
+{{range .Findings}}- {{.Path}}:{{.Line}} [{{.Severity}}/{{.Category}}] {{.Message}}
+{{else}}(none)
+{{end}}
+Respond with a JSON object containing:
+- "overview": (string) Two or three sentences describing what the pull request does.
+- "walkthrough": (array) One entry per changed file with "path" (string, exactly as listed above) and "summary" (string, one sentence describing the change).
+- "risk": (string) The overall risk of merging: "low", "medium" or "high".
+- "risk_reason": (string) One sentence justifying the risk rating.
+- "top_issues": (array of strings) The most important findings to address, most important first; empty if there are none.`
+
+// FileSummary is the model's one-line description of a changed file.
+type FileSummary struct {
+	Path    string `json:"path"`
+	Summary string `json:"summary"`
+}
+
+// PRSummary is the structured response for the PR-level summary.
+type PRSummary struct {
+	Overview    string        `json:"overview"`
+	Walkthrough []FileSummary `json:"walkthrough"`
+	Risk        string        `json:"risk" jsonschema:"enum=low,enum=medium,enum=high"`
+	RiskReason  string        `json:"risk_reason"`
+	TopIssues   []string      `json:"top_issues"`
+}
+
+var riskBadges = map[string]string{
+	constants.RISK_LOW:    "🟢 Low",
+	constants.RISK_MEDIUM: "🟡 Medium",
+	constants.RISK_HIGH:   "🔴 High",
+}
+
+// summarizePR asks the model for a walkthrough, risk rating and top issues for the whole PR
+// and renders them as a markdown comment, listing the chunks that could not be reviewed.
+func summarizePR(ctx context.Context, g *genkit.Genkit, cfg *config.Config, prDetails *PRDetails, files []*diffparser.FileDiff, comments []*vcs.Comment, unreviewed []*diffparser.DiffChunk) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "summarizePR")
+	defer func() { tracing.End(span, err) }()
+
+	prompt, err := prepareSummaryPrompt(cfg.SummaryPrompt, prDetails, files, comments)
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare summary prompt: %w", err)
+	}
+	summary, err := generateStructured(ctx, g, cfg, prompt, validatePRSummary)
+	if err != nil {
+		return "", err
+	}
+	return renderSummary(summary, files, comments, unreviewed), nil
+}
+
+// postSummary edits the summary left by a previous run on the PR, or posts a new one if there is
+// none. existing must have been looked up among the bot's own comments, since forges refuse to
+// edit anyone else's; if the edit still fails, for example because the comment was deleted, a
+// new summary is posted instead.
+func postSummary(ctx context.Context, vcsClient vcs.VCSAdapter, prDetails *PRDetails, existing *vcs.GeneralComment, summary string) error {
+	if existing != nil {
+		tracing.Logf(ctx, "Updating previous summary comment %d.", existing.ID)
+		err := vcsClient.EditGeneralComment(ctx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber, existing.ID, summary)
+		if err == nil {
+			return nil
+		}
+		tracing.Logf(ctx, "Warning: could not update summary comment %d, posting a new one: %v", existing.ID, err)
+	}
+	return vcsClient.PostGeneralComment(ctx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber, summary)
+}
+
+// prepareSummaryPrompt populates the summary template. It uses text/template because the
+// PR title and description are free text that must reach the model unescaped.
+func prepareSummaryPrompt(promptTmpl string, prDetails *PRDetails, files []*diffparser.FileDiff, comments []*vcs.Comment) (string, error) {
+	if promptTmpl == "" {
+		promptTmpl = defaultSummaryPrompt
+	}
+	tmpl, err := template.New("summary_prompt").Parse(promptTmpl)
+	if err != nil {
+		return "", err
+	}
+
+	type fileData struct {
+		Path, Status   string
+		Added, Deleted int
+	}
+	type findingData struct {
+		Path               string
+		Line               int
+		Severity, Category string
+		Message            string
+	}
+	data := struct {
+		Title, Body string
+		Files       []fileData
+		Findings    []findingData
+	}{Title: prDetails.Title, Body: prDetails.Body}
+	for _, f := range files {
+		added, deleted := f.Stats()
+		data.Files = append(data.Files, fileData{Path: f.Path(), Status: string(f.Status), Added: added, Deleted: deleted})
+	}
+	for _, c := range comments {
+		data.Findings = append(data.Findings, findingData{
+			Path: c.Path, Line: c.Line, Severity: c.Severity, Category: c.Category,
+			Message: strings.Join(strings.Fields(commentMessage(c.Body)), " "),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// validatePRSummary checks the constraints the JSON schema cannot express.
+func validatePRSummary(s *PRSummary) error {
+	if s == nil {
+		return fmt.Errorf("response is empty")
+	}
+	if strings.TrimSpace(s.Overview) == "" {
+		return fmt.Errorf("overview is empty")
+	}
+	if _, ok := riskBadges[s.Risk]; !ok {
+		return fmt.Errorf("unknown risk '%s'", s.Risk)
+	}
+	return nil
+}
+
+// renderSummary builds the markdown summary comment. The walkthrough table lists every file
+// from the diff in diff order, whether or not the model described it.
+func renderSummary(s *PRSummary, files []*diffparser.FileDiff, comments []*vcs.Comment, unreviewed []*diffparser.DiffChunk) string {
+	descriptions := make(map[string]string, len(s.Walkthrough))
+	for _, w := range s.Walkthrough {
+		descriptions[w.Path] = w.Summary
+	}
+
+	var b strings.Builder
+	b.WriteString(summaryMarker + "\n")
+	b.WriteString("## 🤖 AI Review Summary\n\n")
+	b.WriteString(strings.TrimSpace(s.Overview) + "\n\n")
+	fmt.Fprintf(&b, "**Risk:** %s", riskBadges[s.Risk])
+	if reason := strings.TrimSpace(s.RiskReason); reason != "" {
+		b.WriteString(" — " + reason)
+	}
+	b.WriteString("\n\n")
+
+	b.WriteString("### Walkthrough\n\n")
+	b.WriteString("| File | Change | Summary |\n|---|---|---|\n")
+	for _, f := range files {
+		added, deleted := f.Stats()
+		fmt.Fprintf(&b, "| `%s` | %s (+%d/-%d) | %s |\n", tableCell(f.Path()), f.Status, added, deleted, tableCell(descriptions[f.Path()]))
+	}
+	b.WriteString("\n")
+
+	b.WriteString("### Top issues\n\n")
+	issues := s.TopIssues
+	if len(issues) > maxTopIssues {
+		issues = issues[:maxTopIssues]
+	}
+	switch {
+	case len(issues) > 0:
+	case len(comments) > 0:
+		// The model listed no top issues, but the inline comments still report some.
+		issues = mostSevereFindings(comments)
+	case len(unreviewed) > 0:
+		b.WriteString("No issues found in the reviewed changes.\n")
+	default:
+		b.WriteString("✅ No issues found. Great work!\n")
+	}
+	for i, issue := range issues {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, strings.Join(strings.Fields(issue), " "))
+	}
+
+	if len(comments) > 0 {
+		fmt.Fprintf(&b, "\n_%d inline comments: %s._\n", len(comments), severityCounts(comments))
+	}
+	if len(unreviewed) > 0 {
+		b.WriteString("\n" + unreviewedSection(unreviewed))
+	}
+	return b.String()
+}
+
+// fallbackSummary builds the summary comment posted when the model could not write one. It
+// carries summaryMarker so that later runs edit it like any other summary.
+func fallbackSummary(comments []*vcs.Comment, unreviewed []*diffparser.DiffChunk) string {
+	var b strings.Builder
+	b.WriteString(summaryMarker + "\n")
+	switch {
+	case len(unreviewed) > 0:
+		b.WriteString("⚠️ AI Review Incomplete\n\n" + unreviewedSection(unreviewed))
+	case len(comments) == 0:
+		b.WriteString("✅ AI Review Complete: No issues found. Great work!\n")
+	default:
+		fmt.Fprintf(&b, "AI Review Complete: %d inline comments (%s).\n", len(comments), severityCounts(comments))
+	}
+	return b.String()
+}
+
+// unreviewedSection lists the chunks the model could not review, so that a partial review is
+// not mistaken for a clean one.
+func unreviewedSection(chunks []*diffparser.DiffChunk) string {
+	var b strings.Builder
+	b.WriteString("### ⚠️ Not reviewed\n\n")
+	b.WriteString("The model could not review these changes, so they may still contain issues. They are reviewed again on the next push.\n\n")
+	for _, chunk := range chunks {
+		fmt.Fprintf(&b, "- %s\n", chunkLocation(chunk))
+	}
+	return b.String()
+}
+
+// chunkLocation describes a chunk by its file and the new-file lines it covers, e.g. "`a.go` lines 10-19".
+func chunkLocation(chunk *diffparser.DiffChunk) string {
+	first, last := chunk.NewLineRange()
+	if last <= first {
+		return fmt.Sprintf("`%s` line %d", chunk.FilePath, first)
+	}
+	return fmt.Sprintf("`%s` lines %d-%d", chunk.FilePath, first, last)
+}
+
+// mostSevereFindings describes up to maxTopIssues inline comments, most severe first and in
+// diff order within a severity.
+func mostSevereFindings(comments []*vcs.Comment) []string {
+	sorted := slices.Clone(comments)
+	slices.SortStableFunc(sorted, func(a, b *vcs.Comment) int {
+		return severityRanks[b.Severity] - severityRanks[a.Severity]
+	})
+	if len(sorted) > maxTopIssues {
+		sorted = sorted[:maxTopIssues]
+	}
+	findings := make([]string, 0, len(sorted))
+	for _, c := range sorted {
+		message, _, _ := strings.Cut(commentMessage(c.Body), "\n")
+		findings = append(findings, fmt.Sprintf("%s `%s` line %d: %s", severityBadges[c.Severity], c.Path, c.Line, message))
+	}
+	return findings
+}
+
+// severityCounts summarizes comments per severity, most severe first, e.g. "1 blocker, 2 minor".
+func severityCounts(comments []*vcs.Comment) string {
+	counts := make(map[string]int)
+	for _, c := range comments {
+		counts[c.Severity]++
+	}
+	var parts []string
+	for _, sev := range []string{constants.SEVERITY_BLOCKER, constants.SEVERITY_MAJOR, constants.SEVERITY_MINOR, constants.SEVERITY_NIT} {
+		if counts[sev] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[sev], sev))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// commentMessage strips the badge line that formatCommentBody puts above the message and
+// the finding marker below it.
+func commentMessage(body string) string {
+	body, _, _ = strings.Cut(body, findingMarkerPrefix)
+	if _, message, ok := strings.Cut(body, "\n\n"); ok {
+		return strings.TrimSpace(message)
+	}
+	return strings.TrimSpace(body)
+}
+
+// tableCell makes text safe for a single markdown table cell.
+func tableCell(s string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(s), " "), "|", `\|`)
+}
@@ -0,0 +1,305 @@
+package reviewer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/internal/diffparser"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+// fakeAdapter is an in-memory VCSAdapter that serves a fixed diff and records what is posted.
+type fakeAdapter struct {
+	diff         string
+	existing     *vcs.GeneralComment
+	existingBy   string // Author of existing; the bot when empty.
+	userErr      error  // Returned by CurrentUser.
+	editErr      error  // Returned by EditGeneralComment.
+	lineComments []*vcs.LineComment
+	compareDiff  string
+	compareErr   error
+
+	reviews        [][]*vcs.Comment
+	posted         []string
+	edited         map[int64]string
+	editedComments map[int64]string // Review comments passed to EditReviewComment.
+	findings       []string         // Markers passed to FindGeneralComment.
+	compared       []string         // Ranges passed to GetCompareDiff, as "base..head".
+}
+
+func (f *fakeAdapter) GetPRDiff(ctx context.Context, owner, repo string, prNumber int) (string, error) {
+	return f.diff, nil
+}
+
+func (f *fakeAdapter) PostReview(ctx context.Context, owner, repo string, prNumber int, comments []*vcs.Comment, commitID string) error {
+	f.reviews = append(f.reviews, comments)
+	return nil
+}
+
+func (f *fakeAdapter) PostGeneralComment(ctx context.Context, owner, repo string, prNumber int, body string) error {
+	f.posted = append(f.posted, body)
+	return nil
+}
+
+func (f *fakeAdapter) GetPRCommitID(ctx context.Context, owner, repo string, prNumber int) (string, error) {
+	return "abc123", nil
+}
+
+func (f *fakeAdapter) FindGeneralComment(ctx context.Context, owner, repo string, prNumber int, marker, author string) (*vcs.GeneralComment, error) {
+	f.findings = append(f.findings, marker)
+	existingBy := f.existingBy
+	if existingBy == "" {
+		existingBy = fakeBotUser
+	}
+	if f.existing != nil && existingBy == author && strings.Contains(f.existing.Body, marker) {
+		return f.existing, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeAdapter) EditGeneralComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) error {
+	if f.editErr != nil {
+		return f.editErr
+	}
+	if f.edited == nil {
+		f.edited = make(map[int64]string)
+	}
+	f.edited[commentID] = body
+	return nil
+}
+
+func (f *fakeAdapter) GetCompareDiff(ctx context.Context, owner, repo, base, head string) (string, error) {
+	f.compared = append(f.compared, base+".."+head)
+	if f.compareErr != nil {
+		return "", f.compareErr
+	}
+	return f.compareDiff, nil
+}
+
+// fakeBotUser is the account fakeAdapter posts as.
+const fakeBotUser = "review-bot"
+
+func (f *fakeAdapter) CurrentUser(ctx context.Context) (string, error) {
+	if f.userErr != nil {
+		return "", f.userErr
+	}
+	return fakeBotUser, nil
+}
+
+func (f *fakeAdapter) ListReviewComments(ctx context.Context, owner, repo string, prNumber int) ([]*vcs.LineComment, error) {
+	return f.lineComments, nil
+}
+
+func (f *fakeAdapter) EditReviewComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) error {
+	if f.editedComments == nil {
+		f.editedComments = make(map[int64]string)
+	}
+	f.editedComments[commentID] = body
+	return nil
+}
+
+const summaryResponse = `{"overview":"Adds b and an early return to a.","walkthrough":[{"path":"dup.go","summary":"Adds function b."}],"risk":"low","risk_reason":"Small change.","top_issues":["Wrap the error returned by b."]}`
+
+// summaryTestModel answers review prompts with one comment and summary prompts with summaryResponse.
+func summaryTestModel(summaryErr error) *fakeModel {
+	return &fakeModel{respond: func(prompt string) (string, error) {
+		if strings.HasPrefix(prompt, "Review ") {
+			return `{"comments":[{"line":18,"line_content":"","message":"Wrap the error.","severity":"minor","category":"bug","confidence":0.9}]}`, nil
+		}
+		if summaryErr != nil {
+			return "", summaryErr
+		}
+		return summaryResponse, nil
+	}}
+}
+
+func TestRunReview_Summary(t *testing.T) {
+	prDetails := &PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1, Title: "Add b", Body: "Adds a second function."}
+
+	t.Run("Success - Posts New Summary", func(t *testing.T) {
+		model := summaryTestModel(nil)
+		g, cfg := setupFakeModel(t, model)
+		adapter := &fakeAdapter{diff: duplicateLinesDiff}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.NoError(t, err)
+
+		require.Len(t, adapter.reviews, 1)
+		require.Len(t, adapter.posted, 1)
+		assert.True(t, strings.HasPrefix(adapter.posted[0], summaryMarker))
+		assert.Contains(t, adapter.posted[0], "| `dup.go` | modified (+7/-0) | Adds function b. |")
+		assert.Contains(t, adapter.posted[0], "1. Wrap the error returned by b.")
+		assert.Empty(t, adapter.edited)
+
+		// The summary prompt carries the PR metadata and the inline findings without badges.
+		summaryPrompt := model.prompts[len(model.prompts)-1]
+		assert.Contains(t, summaryPrompt, "Pull request title: Add b")
+		assert.Contains(t, summaryPrompt, "- dup.go (modified, +7/-0)")
+		assert.Contains(t, summaryPrompt, "- dup.go:18 [minor/bug] Wrap the error.")
+	})
+
+	t.Run("Success - Edits Previous Summary", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, summaryTestModel(nil))
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, existing: &vcs.GeneralComment{ID: 42, Body: summaryMarker + "\nold summary"}}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.NoError(t, err)
+
+		assert.Empty(t, adapter.posted)
+		require.Contains(t, adapter.edited, int64(42))
+		assert.Contains(t, adapter.edited[42], "Adds b and an early return to a.")
+		assert.Equal(t, []string{summaryMarker}, adapter.findings)
+	})
+
+	t.Run("Success - Ignores Summary Marker On Another User's Comment", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, summaryTestModel(nil))
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, existing: &vcs.GeneralComment{ID: 42, Body: summaryMarker + "\nforged summary"}, existingBy: "someone"}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.NoError(t, err)
+		assert.Empty(t, adapter.edited)
+		require.Len(t, adapter.posted, 1)
+		assert.True(t, strings.HasPrefix(adapter.posted[0], summaryMarker))
+	})
+
+	t.Run("Fallback - Posts New Summary When Edit Fails", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, summaryTestModel(nil))
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, existing: &vcs.GeneralComment{ID: 42, Body: summaryMarker + "\nold summary"}, editErr: errors.New("403 Forbidden")}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.NoError(t, err)
+		require.Len(t, adapter.posted, 1)
+		assert.Contains(t, adapter.posted[0], "Adds b and an early return to a.")
+	})
+
+	t.Run("Failure - Summary Error Falls Back When There Are No Comments", func(t *testing.T) {
+		model := &fakeModel{respond: func(prompt string) (string, error) {
+			if strings.HasPrefix(prompt, "Review ") {
+				return `{"comments":[]}`, nil
+			}
+			return "", errors.New("model unavailable")
+		}}
+		g, cfg := setupFakeModel(t, model)
+		adapter := &fakeAdapter{diff: duplicateLinesDiff}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.NoError(t, err)
+		assert.Empty(t, adapter.reviews)
+		assert.Equal(t, []string{summaryMarker + "\n✅ AI Review Complete: No issues found. Great work!\n" + reviewFooter("", "abc123")}, adapter.posted)
+	})
+
+	t.Run("Failure - Summary Error Edits Previous Summary", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, summaryTestModel(errors.New("model unavailable")))
+		adapter := &fakeAdapter{diff: duplicateLinesDiff, existing: &vcs.GeneralComment{ID: 42, Body: summaryMarker + "\nold summary"}}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.NoError(t, err)
+		assert.Empty(t, adapter.posted)
+		require.Contains(t, adapter.edited, int64(42))
+		assert.Contains(t, adapter.edited[42], "AI Review Complete: 1 inline comments (1 minor).")
+		assert.Equal(t, "abc123", parseReviewedSHA(adapter.edited[42]))
+	})
+}
+
+func TestRunReview_UnreviewedChunks(t *testing.T) {
+	prDetails := &PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1}
+	testModel := func(summaryErr error) *fakeModel {
+		return &fakeModel{respond: func(prompt string) (string, error) {
+			switch {
+			case strings.HasPrefix(prompt, "Review file1.go"):
+				return "", errors.New("provider unavailable")
+			case strings.HasPrefix(prompt, "Review "):
+				return `{"comments":[]}`, nil
+			case summaryErr != nil:
+				return "", summaryErr
+			}
+			return summaryResponse, nil
+		}}
+	}
+
+	t.Run("Summary Lists Unreviewed Chunks", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, testModel(nil))
+		adapter := &fakeAdapter{diff: multiFileDiff(2)}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.NoError(t, err)
+		require.Len(t, adapter.posted, 1)
+		assert.Contains(t, adapter.posted[0], "### ⚠️ Not reviewed")
+		assert.Contains(t, adapter.posted[0], "- `file1.go` lines 1-2\n")
+		assert.NotContains(t, adapter.posted[0], "file0.go` lines")
+		assert.NotContains(t, adapter.posted[0], reviewedSHAPrefix, "the head commit is not recorded, so the next run reviews the chunk again")
+	})
+
+	t.Run("Summary Failure Still Reports Unreviewed Chunks", func(t *testing.T) {
+		g, cfg := setupFakeModel(t, testModel(errors.New("model unavailable")))
+		adapter := &fakeAdapter{diff: multiFileDiff(2)}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.NoError(t, err)
+		require.Len(t, adapter.posted, 1)
+		assert.True(t, strings.HasPrefix(adapter.posted[0], summaryMarker+"\n⚠️ AI Review Incomplete"))
+		assert.Contains(t, adapter.posted[0], "- `file1.go` lines 1-2\n")
+	})
+
+	t.Run("All Chunks Failing Is An Error", func(t *testing.T) {
+		model := &fakeModel{respond: func(prompt string) (string, error) {
+			return "", errors.New("provider unavailable")
+		}}
+		g, cfg := setupFakeModel(t, model)
+		adapter := &fakeAdapter{diff: multiFileDiff(2)}
+
+		_, err := RunReview(context.Background(), g, prDetails, cfg, adapter)
+		require.Error(t, err)
+		require.Len(t, adapter.posted, 1, "the summary still lists the unreviewed chunks")
+		assert.Contains(t, adapter.posted[0], "- `file0.go` lines 1-2\n")
+		assert.Contains(t, adapter.posted[0], "- `file1.go` lines 1-2\n")
+	})
+}
+
+func TestRenderSummary(t *testing.T) {
+	files := diffparser.ParseFiles(duplicateLinesDiff + "diff --git a/old.txt b/old.txt\ndeleted file mode 100644\n--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-gone\n")
+	summary := &PRSummary{
+		Overview:    "Does things.",
+		Walkthrough: []FileSummary{{Path: "dup.go", Summary: "Uses a | pipe\nand a newline."}},
+		Risk:        "high",
+		RiskReason:  "Touches error handling.",
+		TopIssues:   []string{"one", "two", "three", "four", "five", "six"},
+	}
+	comments := []*vcs.Comment{{Severity: "major"}, {Severity: "nit"}, {Severity: "major"}}
+
+	got := renderSummary(summary, files, comments, nil)
+
+	assert.Contains(t, got, "**Risk:** 🔴 High — Touches error handling.")
+	assert.Contains(t, got, "| `dup.go` | modified (+7/-0) | Uses a \\| pipe and a newline. |")
+	assert.Contains(t, got, "| `old.txt` | deleted (+0/-1) |  |")
+	assert.Contains(t, got, "5. five\n")
+	assert.NotContains(t, got, "six")
+	assert.Contains(t, got, "_3 inline comments: 2 major, 1 nit._")
+}
+
+func TestRenderSummary_NoTopIssues(t *testing.T) {
+	files := diffparser.ParseFiles(duplicateLinesDiff)
+	summary := &PRSummary{Overview: "Does things.", Risk: "low"}
+
+	t.Run("Lists Most Severe Inline Findings", func(t *testing.T) {
+		comments := []*vcs.Comment{
+			{Path: "a.go", Line: 3, Severity: "nit", Body: "**⚪ Nit** · 🎨 Style · 90% confidence\n\nRename x."},
+			{Path: "b.go", Line: 7, Severity: "blocker", Body: "**🛑 Blocker** · 🔒 Security · 95% confidence\n\nSQL injection.\nUse a prepared statement."},
+			{Path: "a.go", Line: 9, Severity: "major", Body: "**🔴 Major** · 🐛 Bug · 80% confidence\n\nNil dereference."},
+		}
+
+		got := renderSummary(summary, files, comments, nil)
+
+		assert.Contains(t, got, "### Top issues\n\n1. 🛑 Blocker `b.go` line 7: SQL injection.\n2. 🔴 Major `a.go` line 9: Nil dereference.\n3. ⚪ Nit `a.go` line 3: Rename x.\n")
+		assert.NotContains(t, got, "No issues found")
+	})
+
+	t.Run("Clean Review", func(t *testing.T) {
+		got := renderSummary(summary, files, nil, nil)
+		assert.Contains(t, got, "✅ No issues found. Great work!")
+	})
+}
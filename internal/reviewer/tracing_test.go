@@ -0,0 +1,49 @@
+package reviewer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRunReview_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider(sdktrace.WithSyncer(exporter), config.TracingConfig{})
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	g, cfg := setupFakeModel(t, summaryTestModel(nil))
+	adapter := &fakeAdapter{diff: duplicateLinesDiff}
+	_, err := RunReview(context.Background(), g, &PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1}, cfg, adapter)
+	require.NoError(t, err)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, stub := range exporter.GetSpans() {
+		spans[stub.Name] = stub.Snapshot()
+	}
+	for _, name := range []string{"review", "vcs.GetPRCommitID", "vcs.GetPRDiff", "analyzeChunk", "locateComments", "vcs.PostReview", "summarizePR"} {
+		require.Contains(t, spans, name)
+		assert.Equal(t, spans["review"].SpanContext().TraceID(), spans[name].SpanContext().TraceID(), "%s belongs to the review's trace", name)
+	}
+	assert.Equal(t, spans["review"].SpanContext().SpanID(), spans["analyzeChunk"].Parent().SpanID())
+	assert.Equal(t, spans["review"].SpanContext().SpanID(), spans["locateComments"].Parent().SpanID())
+
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range spans["analyzeChunk"].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	assert.Equal(t, "fake/reviewer", attrs["llm.model"].AsString())
+	assert.Equal(t, "dup.go", attrs["code.filepath"].AsString())
+	assert.Positive(t, attrs["llm.prompt_tokens"].AsInt64())
+	assert.Positive(t, attrs["llm.response_bytes"].AsInt64())
+	assert.Contains(t, spans["vcs.PostReview"].Attributes(), attribute.Int("review.comments", 1))
+}
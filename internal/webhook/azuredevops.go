@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/internal/reviewer"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+// AzureDevOpsPullRequestHook represents the structure of an Azure DevOps pull request service hook payload.
+type AzureDevOpsPullRequestHook struct {
+	EventType string `json:"eventType"`
+	Resource  struct {
+		PullRequestID int    `json:"pullRequestId"`
+		Status        string `json:"status"`
+		Title         string `json:"title"`
+		Description   string `json:"description"`
+		Repository    struct {
+			Name    string `json:"name"`
+			Project struct {
+				Name string `json:"name"`
+			} `json:"project"`
+		} `json:"repository"`
+	} `json:"resource"`
+}
+
+type AzureDevOpsWebhookHandler struct {
+	reviews *ReviewQueue
+	config  *config.Config
+	secret  string
+}
+
+func NewAzureDevOpsWebhookHandler(reviews *ReviewQueue, cfg *config.Config, secret string) (*AzureDevOpsWebhookHandler, error) {
+	if secret == "" {
+		return nil, errEmptySecret
+	}
+	h := &AzureDevOpsWebhookHandler{reviews: reviews, config: cfg, secret: secret}
+	reviews.register(constants.AZUREDEVOPS, func(ctx context.Context, req *ReviewRequest) (vcs.VCSAdapter, error) {
+		return vcs.NewAzureDevOpsClient(ctx, cfg.VCS.AzureDevOps.BaseURL, cfg.VCS.AzureDevOps.Token), nil
+	})
+	return h, nil
+}
+
+// Handle authenticates the service hook with the basic auth password configured on the
+// subscription, since Azure DevOps does not sign its payloads.
+func (h *AzureDevOpsWebhookHandler) Handle(c *gin.Context) {
+	_, password, ok := c.Request.BasicAuth()
+	if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(h.secret)) != 1 {
+		c.String(http.StatusForbidden, "Forbidden: Invalid credentials")
+		return
+	}
+
+	var payload AzureDevOpsPullRequestHook
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.String(http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	// Subscribe the "updated" hook with the "Source branch updated" filter so that
+	// only pushes, not votes or title edits, trigger a review.
+	eventType := payload.EventType
+	if eventType != constants.PR_CREATED_EVENT && eventType != constants.PR_UPDATED_EVENT {
+		log.Printf("Ignoring Azure DevOps event: %s", eventType)
+		c.String(http.StatusOK, "Event ignored.")
+		return
+	}
+	pr := payload.Resource
+	if pr.Status != constants.ACTIVE {
+		log.Printf("Ignoring PR #%d because its status is '%s'", pr.PullRequestID, pr.Status)
+		c.String(http.StatusOK, "Event ignored.")
+		return
+	}
+
+	log.Printf("Received Azure DevOps PR event: %s for PR #%d", eventType, pr.PullRequestID)
+	h.reviews.enqueue(c, &ReviewRequest{
+		Platform: constants.AZUREDEVOPS,
+		PR: reviewer.PRDetails{
+			Owner:    pr.Repository.Project.Name,
+			Repo:     pr.Repository.Name,
+			PRNumber: pr.PullRequestID,
+			Title:    pr.Title,
+			Body:     pr.Description,
+		},
+	})
+}
@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/internal/reviewer"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+func TestAzureDevOpsWebhookHandler_Handle(t *testing.T) {
+	prEvent := func(eventType, status string) string {
+		return `{"eventType":"` + eventType + `","resource":{"pullRequestId":7,"status":"` + status + `","repository":{"name":"repo","project":{"name":"proj"}}}}`
+	}
+	basic := func(user, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	}
+	tests := []struct {
+		name          string
+		authorization string
+		body          string
+		want          int
+	}{
+		{"Created", basic("azure", "secret"), prEvent("git.pullrequest.created", "active"), http.StatusAccepted},
+		{"Source Branch Updated", basic("azure", "secret"), prEvent("git.pullrequest.updated", "active"), http.StatusAccepted},
+		{"Any User Name", basic("", "secret"), prEvent("git.pullrequest.created", "active"), http.StatusAccepted},
+		{"Wrong Password", basic("azure", "wrong"), prEvent("git.pullrequest.created", "active"), http.StatusForbidden},
+		{"Password Prefix", basic("azure", "secre"), prEvent("git.pullrequest.created", "active"), http.StatusForbidden},
+		{"Password Too Long", basic("azure", "secret2"), prEvent("git.pullrequest.created", "active"), http.StatusForbidden},
+		{"Empty Password", basic("azure", ""), prEvent("git.pullrequest.created", "active"), http.StatusForbidden},
+		{"Missing Header", "", prEvent("git.pullrequest.created", "active"), http.StatusForbidden},
+		{"Not Basic Auth", "Bearer secret", prEvent("git.pullrequest.created", "active"), http.StatusForbidden},
+		{"Merge Attempted Ignored", basic("azure", "secret"), prEvent("git.pullrequest.merged", "active"), http.StatusOK},
+		{"Completed PR Ignored", basic("azure", "secret"), prEvent("git.pullrequest.updated", "completed"), http.StatusOK},
+		{"Abandoned PR Ignored", basic("azure", "secret"), prEvent("git.pullrequest.updated", "abandoned"), http.StatusOK},
+		{"Other Event Ignored", basic("azure", "secret"), `{"eventType":"git.push"}`, http.StatusOK},
+		{"Invalid JSON", basic("azure", "secret"), `{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := NewAzureDevOpsWebhookHandler(newHandlerTestQueue(t), &config.Config{}, "secret")
+			require.NoError(t, err)
+
+			w := serve(h.Handle, tt.body, map[string]string{"Authorization": tt.authorization})
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestAzureDevOpsWebhookHandler_QueuedRequest(t *testing.T) {
+	reviews := newHandlerTestQueue(t)
+	h, err := NewAzureDevOpsWebhookHandler(reviews, &config.Config{}, "secret")
+	require.NoError(t, err)
+
+	// Replace the Azure DevOps client factory to capture the queued request instead of reviewing it.
+	queued := make(chan reviewer.PRDetails, 1)
+	reviews.register(constants.AZUREDEVOPS, func(ctx context.Context, req *ReviewRequest) (vcs.VCSAdapter, error) {
+		select {
+		case queued <- req.PR:
+		default:
+		}
+		return nil, errors.New("not reviewed in this test")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reviews.Start(ctx)
+
+	body := `{"eventType":"git.pullrequest.created","resource":{"pullRequestId":7,"status":"active","title":"Add b","description":"Adds b.","repository":{"name":"repo","project":{"name":"proj"}}}}`
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("azure:secret"))
+	w := serve(h.Handle, body, map[string]string{"Authorization": auth})
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	select {
+	case pr := <-queued:
+		assert.Equal(t, reviewer.PRDetails{Owner: "proj", Repo: "repo", PRNumber: 7, Title: "Add b", Body: "Adds b."}, pr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the review was not queued")
+	}
+}
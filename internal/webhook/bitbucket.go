@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/internal/reviewer"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+// BitbucketPullRequestHook represents the structure of Bitbucket Server's pull request webhook payload.
+type BitbucketPullRequestHook struct {
+	EventKey    string `json:"eventKey"`
+	PullRequest struct {
+		ID          int    `json:"id"`
+		State       string `json:"state"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		ToRef       struct {
+			Repository struct {
+				Slug    string `json:"slug"`
+				Project struct {
+					Key string `json:"key"`
+				} `json:"project"`
+			} `json:"repository"`
+		} `json:"toRef"`
+	} `json:"pullRequest"`
+}
+
+type BitbucketWebhookHandler struct {
+	reviews *ReviewQueue
+	config  *config.Config
+	secret  string
+}
+
+func NewBitbucketWebhookHandler(reviews *ReviewQueue, cfg *config.Config, secret string) (*BitbucketWebhookHandler, error) {
+	if secret == "" {
+		return nil, errEmptySecret
+	}
+	h := &BitbucketWebhookHandler{reviews: reviews, config: cfg, secret: secret}
+	reviews.register(constants.BITBUCKET, func(ctx context.Context, req *ReviewRequest) (vcs.VCSAdapter, error) {
+		return vcs.NewBitbucketClient(ctx, cfg.VCS.Bitbucket.BaseURL, cfg.VCS.Bitbucket.Token), nil
+	})
+	return h, nil
+}
+
+func (h *BitbucketWebhookHandler) Handle(c *gin.Context) {
+	// Bitbucket Server sends "sha256=<hex digest>" when a webhook secret is configured.
+	signature := strings.TrimPrefix(c.GetHeader("X-Hub-Signature"), "sha256=")
+	body, _ := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+	mac := hmac.New(sha256.New, []byte(h.secret))
+	mac.Write(body)
+	expectedSignature := hex.EncodeToString(mac.Sum(nil))
+
+	if signature == "" || !hmac.Equal([]byte(signature), []byte(expectedSignature)) {
+		c.String(http.StatusForbidden, "Forbidden: Invalid signature")
+		return
+	}
+
+	var payload BitbucketPullRequestHook
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.String(http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	eventKey := c.GetHeader("X-Event-Key")
+	if eventKey == "" {
+		eventKey = payload.EventKey
+	}
+	if eventKey != constants.PR_OPENED && eventKey != constants.PR_FROM_REF_UPDATED {
+		log.Printf("Ignoring Bitbucket event: %s", eventKey)
+		c.String(http.StatusOK, "Event ignored.")
+		return
+	}
+	pr := payload.PullRequest
+	if pr.State != constants.STATE_OPEN {
+		log.Printf("Ignoring PR #%d because its state is '%s'", pr.ID, pr.State)
+		c.String(http.StatusOK, "Event ignored.")
+		return
+	}
+
+	log.Printf("Received Bitbucket PR event: %s for PR #%d", eventKey, pr.ID)
+	h.reviews.enqueue(c, &ReviewRequest{
+		Platform: constants.BITBUCKET,
+		PR: reviewer.PRDetails{
+			Owner:    pr.ToRef.Repository.Project.Key,
+			Repo:     pr.ToRef.Repository.Slug,
+			PRNumber: pr.ID,
+			Title:    pr.Title,
+			Body:     pr.Description,
+		},
+	})
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/internal/reviewer"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+func TestBitbucketWebhookHandler_Handle(t *testing.T) {
+	prEvent := func(eventKey, state string) string {
+		return `{"eventKey":"` + eventKey + `","pullRequest":{"id":7,"state":"` + state + `","title":"Add b","toRef":{"repository":{"slug":"repo","project":{"key":"PROJ"}}}}}`
+	}
+	opened := prEvent("pr:opened", "OPEN")
+	tests := []struct {
+		name      string
+		eventKey  string // X-Event-Key header; the payload's eventKey is used when empty.
+		signature string
+		body      string
+		want      int
+	}{
+		{"Opened", "pr:opened", "sha256=" + sign("secret", opened), opened, http.StatusAccepted},
+		{"Source Branch Updated", "pr:from_ref_updated", "sha256=" + sign("secret", prEvent("pr:from_ref_updated", "OPEN")), prEvent("pr:from_ref_updated", "OPEN"), http.StatusAccepted},
+		{"Event Key From Payload", "", "sha256=" + sign("secret", opened), opened, http.StatusAccepted},
+		{"Signature Without Prefix", "pr:opened", sign("secret", opened), opened, http.StatusAccepted},
+		{"Wrong Secret", "pr:opened", "sha256=" + sign("other", opened), opened, http.StatusForbidden},
+		{"Empty Signature", "pr:opened", "", opened, http.StatusForbidden},
+		{"Prefix Only", "pr:opened", "sha256=", opened, http.StatusForbidden},
+		{"Tampered Body", "pr:opened", "sha256=" + sign("secret", opened), prEvent("pr:opened", "MERGED"), http.StatusForbidden},
+		{"Comment Ignored", "pr:comment:added", "sha256=" + sign("secret", prEvent("pr:comment:added", "OPEN")), prEvent("pr:comment:added", "OPEN"), http.StatusOK},
+		{"Merged Ignored", "pr:merged", "sha256=" + sign("secret", prEvent("pr:merged", "MERGED")), prEvent("pr:merged", "MERGED"), http.StatusOK},
+		{"Closed PR Ignored", "pr:from_ref_updated", "sha256=" + sign("secret", prEvent("pr:from_ref_updated", "DECLINED")), prEvent("pr:from_ref_updated", "DECLINED"), http.StatusOK},
+		{"Invalid JSON", "pr:opened", "sha256=" + sign("secret", `{`), `{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := NewBitbucketWebhookHandler(newHandlerTestQueue(t), &config.Config{}, "secret")
+			require.NoError(t, err)
+
+			w := serve(h.Handle, tt.body, map[string]string{"X-Hub-Signature": tt.signature, "X-Event-Key": tt.eventKey})
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestBitbucketWebhookHandler_QueuedRequest(t *testing.T) {
+	reviews := newHandlerTestQueue(t)
+	h, err := NewBitbucketWebhookHandler(reviews, &config.Config{}, "secret")
+	require.NoError(t, err)
+
+	// Replace the Bitbucket client factory to capture the queued request instead of reviewing it.
+	queued := make(chan reviewer.PRDetails, 1)
+	reviews.register(constants.BITBUCKET, func(ctx context.Context, req *ReviewRequest) (vcs.VCSAdapter, error) {
+		select {
+		case queued <- req.PR:
+		default:
+		}
+		return nil, errors.New("not reviewed in this test")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reviews.Start(ctx)
+
+	body := `{"eventKey":"pr:opened","pullRequest":{"id":7,"state":"OPEN","title":"Add b","description":"Adds b.","toRef":{"repository":{"slug":"repo","project":{"key":"PROJ"}}}}}`
+	w := serve(h.Handle, body, map[string]string{"X-Hub-Signature": "sha256=" + sign("secret", body), "X-Event-Key": "pr:opened"})
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	select {
+	case pr := <-queued:
+		assert.Equal(t, reviewer.PRDetails{Owner: "PROJ", Repo: "repo", PRNumber: 7, Title: "Add b", Body: "Adds b."}, pr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the review was not queued")
+	}
+}
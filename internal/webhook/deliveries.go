@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"encoding/binary"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	bolt "go.etcd.io/bbolt"
+)
+
+// defaultDeliveryTTL is how long deliveries are remembered when server.delivery_ttl_minutes is unset.
+const defaultDeliveryTTL = 24 * time.Hour
+
+var deliveriesBucket = []byte("deliveries")
+
+// deliveryKeysContextKey holds the keys claimed for a request, so they can be released
+// again when the review could not be queued and the platform should be free to retry.
+const deliveryKeysContextKey = "webhook.deliveryKeys"
+
+// deliveryCache remembers recent webhook deliveries so that duplicates are rejected.
+type deliveryCache struct {
+	ttl time.Duration
+	db  *bolt.DB // Nil when deliveries are only kept in memory.
+	now func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time // Expiry by key.
+}
+
+// newDeliveryCache creates a cache whose entries expire after ttl. With a path, entries are
+// stored in a bbolt file and survive restarts.
+func newDeliveryCache(path string, ttl time.Duration) (*deliveryCache, error) {
+	d := &deliveryCache{ttl: ttl, now: time.Now, seen: make(map[string]time.Time)}
+	if path == "" {
+		return d, nil
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	now := d.now()
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(deliveriesBucket)
+		if err != nil {
+			return err
+		}
+		var expired [][]byte
+		err = b.ForEach(func(k, v []byte) error {
+			expiry := time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+			if expiry.After(now) {
+				d.seen[string(k)] = expiry
+			} else {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	d.db = db
+	return d, nil
+}
+
+// claim records the keys and reports true, or reports false without recording anything if
+// any of them was seen within the TTL. Expired keys are dropped from memory and the store.
+func (d *deliveryCache) claim(keys ...string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	var expired []string
+	for k, expiry := range d.seen {
+		if !expiry.After(now) {
+			delete(d.seen, k)
+			expired = append(expired, k)
+		}
+	}
+	claimed := true
+	for _, k := range keys {
+		if _, ok := d.seen[k]; ok {
+			claimed = false
+		}
+	}
+	expiry := now.Add(d.ttl)
+	if claimed {
+		for _, k := range keys {
+			d.seen[k] = expiry
+		}
+	}
+	if !claimed && len(expired) == 0 {
+		return false
+	}
+	d.persist(func(b *bolt.Bucket) error {
+		for _, k := range expired {
+			if err := b.Delete([]byte(k)); err != nil {
+				return err
+			}
+		}
+		if !claimed {
+			return nil
+		}
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(expiry.UnixNano()))
+		for _, k := range keys {
+			if err := b.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return claimed
+}
+
+// release forgets claimed keys.
+func (d *deliveryCache) release(keys ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, k := range keys {
+		delete(d.seen, k)
+	}
+	d.persist(func(b *bolt.Bucket) error {
+		for _, k := range keys {
+			if err := b.Delete([]byte(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// persist applies an update to the stored deliveries. Failures only cost the deliveries
+// their persistence, so they are logged rather than failing the webhook.
+func (d *deliveryCache) persist(update func(b *bolt.Bucket) error) {
+	if d.db == nil {
+		return
+	}
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		return update(tx.Bucket(deliveriesBucket))
+	})
+	if err != nil {
+		log.Printf("Error storing webhook deliveries: %v", err)
+	}
+}
+
+func (d *deliveryCache) Close() error {
+	if d.db == nil {
+		return nil
+	}
+	return d.db.Close()
+}
+
+// claimDelivery rejects a replayed webhook and reports whether the request may proceed. It must
+// only be called after the signature was verified and the event was parsed and found to start a
+// review, so that a delivery that is ignored is not remembered. Delivery IDs are not covered by the
+// signature, so the signature itself is remembered too: a captured payload sent again under a
+// fresh delivery ID is still rejected.
+func (r *ReviewQueue) claimDelivery(c *gin.Context, platform, deliveryID, signature string) bool {
+	if deliveryID == "" {
+		c.String(http.StatusBadRequest, "Bad Request: Missing delivery ID")
+		return false
+	}
+	keys := []string{platform + ":delivery:" + deliveryID, platform + ":signature:" + signature}
+	if !r.deliveries.claim(keys...) {
+		log.Printf("Rejecting duplicate %s webhook delivery %s", platform, deliveryID)
+		c.String(http.StatusConflict, "Conflict: Duplicate delivery")
+		return false
+	}
+	c.Set(deliveryKeysContextKey, keys)
+	return true
+}
+
+// releaseDelivery forgets the delivery claimed for the request, if any.
+func (r *ReviewQueue) releaseDelivery(c *gin.Context) {
+	if keys, ok := c.Get(deliveryKeysContextKey); ok {
+		r.deliveries.release(keys.([]string)...)
+	}
+}
@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/internal/queue"
+	bolt "go.etcd.io/bbolt"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newHandlerTestQueue(t *testing.T) *ReviewQueue {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	reviews, err := newReviewQueue(nil, &config.Config{}, filepath.Join(t.TempDir(), "queue.db"), queue.Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { reviews.Close() })
+	return reviews
+}
+
+func serve(handle gin.HandlerFunc, body string, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		c.Request.Header.Set(k, v)
+	}
+	handle(c)
+	return w
+}
+
+func TestDeliveryCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deliveries.db")
+	now := time.Now()
+	d, err := newDeliveryCache(path, time.Hour)
+	require.NoError(t, err)
+	d.now = func() time.Time { return now }
+
+	assert.True(t, d.claim("github:delivery:1", "github:signature:a"))
+	assert.False(t, d.claim("github:delivery:1", "github:signature:b"), "a redelivery is rejected")
+	assert.False(t, d.claim("github:delivery:2", "github:signature:a"), "a replay under a new ID is rejected")
+	assert.True(t, d.claim("github:delivery:3", "github:signature:c"))
+	d.release("github:delivery:3", "github:signature:c")
+	assert.True(t, d.claim("github:delivery:3", "github:signature:c"), "released deliveries may be retried")
+	require.NoError(t, d.Close())
+
+	// Deliveries are remembered across restarts until they expire.
+	d, err = newDeliveryCache(path, time.Hour)
+	require.NoError(t, err)
+	defer d.Close()
+	d.now = func() time.Time { return now }
+	assert.False(t, d.claim("github:delivery:1"))
+	now = now.Add(2 * time.Hour)
+	assert.True(t, d.claim("github:delivery:1"))
+
+	// Expired deliveries are removed from the store while the server runs.
+	var stored []string
+	require.NoError(t, d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(deliveriesBucket).ForEach(func(k, v []byte) error {
+			stored = append(stored, string(k))
+			return nil
+		})
+	}))
+	assert.Equal(t, []string{"github:delivery:1"}, stored)
+}
+
+func TestGitHubWebhookHandler_RejectsDuplicateDeliveries(t *testing.T) {
+	h, err := NewGitHubWebhookHandler(newHandlerTestQueue(t), &config.Config{}, "secret")
+	require.NoError(t, err)
+
+	body := `{"action":"opened","number":1,"pull_request":{"number":1,"state":"open","base":{"repo":{"name":"repo","owner":{"login":"owner"}}}}}`
+	headers := func(delivery string) map[string]string {
+		return map[string]string{
+			"X-GitHub-Event":      "pull_request",
+			"X-GitHub-Delivery":   delivery,
+			"X-Hub-Signature-256": "sha256=" + sign("secret", body),
+		}
+	}
+
+	assert.Equal(t, http.StatusAccepted, serve(h.Handle, body, headers("d1")).Code)
+	assert.Equal(t, http.StatusConflict, serve(h.Handle, body, headers("d1")).Code)
+	assert.Equal(t, http.StatusConflict, serve(h.Handle, body, headers("d2")).Code, "a replayed payload is rejected under a new delivery ID")
+	assert.Equal(t, http.StatusBadRequest, serve(h.Handle, body, headers("")).Code)
+}
+
+func TestGitHubWebhookHandler_ClaimsOnlyQueuedDeliveries(t *testing.T) {
+	h, err := NewGitHubWebhookHandler(newHandlerTestQueue(t), &config.Config{}, "secret")
+	require.NoError(t, err)
+
+	send := func(body, delivery string) int {
+		return serve(h.Handle, body, map[string]string{
+			"X-GitHub-Event":      "pull_request",
+			"X-GitHub-Delivery":   delivery,
+			"X-Hub-Signature-256": "sha256=" + sign("secret", body),
+		}).Code
+	}
+	opened := `{"action":"opened","number":1,"pull_request":{"number":1,"state":"open","base":{"repo":{"name":"repo","owner":{"login":"owner"}}}}}`
+	closed := strings.Replace(opened, `"opened"`, `"closed"`, 1)
+
+	assert.Equal(t, http.StatusOK, send(closed, "d1"))
+	assert.Equal(t, http.StatusOK, send(closed, "d1"), "an ignored delivery is not remembered")
+	assert.Equal(t, http.StatusBadRequest, send(`{"action":`, "d1"))
+	assert.Equal(t, http.StatusAccepted, send(opened, "d1"))
+}
+
+func TestGiteaWebhookHandler_Signature(t *testing.T) {
+	h, err := NewGiteaWebhookHandler(newHandlerTestQueue(t), &config.Config{}, "secret")
+	require.NoError(t, err)
+
+	body := `{"action":"opened","number":1,"repository":{"name":"repo","owner":{"login":"owner"}}}`
+	closedBody := strings.Replace(body, `"opened"`, `"closed"`, 1)
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		delivery  string
+		want      int
+	}{
+		{"Valid Signature", body, sign("secret", body), "d1", http.StatusAccepted},
+		{"Duplicate Delivery", body, sign("secret", body), "d1", http.StatusConflict},
+		{"Empty Signature", body, "", "d2", http.StatusForbidden},
+		{"Wrong Secret", body, sign("other", body), "d3", http.StatusForbidden},
+		{"Ignored Action", closedBody, sign("secret", closedBody), "d4", http.StatusOK},
+		{"Ignored Action Not Remembered", closedBody, sign("secret", closedBody), "d4", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h.Handle, tt.body, map[string]string{"X-Gitea-Signature": tt.signature, "X-Gitea-Delivery": tt.delivery})
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestNewWebhookHandlers_RejectEmptySecret(t *testing.T) {
+	reviews := newHandlerTestQueue(t)
+	cfg := &config.Config{}
+	_, err := NewGitHubWebhookHandler(reviews, cfg, "")
+	assert.ErrorIs(t, err, errEmptySecret)
+	_, err = NewGiteaWebhookHandler(reviews, cfg, "")
+	assert.ErrorIs(t, err, errEmptySecret)
+	_, err = NewGitLabWebhookHandler(reviews, cfg, "")
+	assert.ErrorIs(t, err, errEmptySecret)
+	_, err = NewBitbucketWebhookHandler(reviews, cfg, "")
+	assert.ErrorIs(t, err, errEmptySecret)
+	_, err = NewAzureDevOpsWebhookHandler(reviews, cfg, "")
+	assert.ErrorIs(t, err, errEmptySecret)
+}
@@ -10,7 +10,6 @@ import (
 	"log"
 	"net/http"
 
-	"github.com/firebase/genkit/go/genkit"
 	"github.com/gin-gonic/gin"
 	"github.com/surya84/code-reviewer-bot/config"
 	"github.com/surya84/code-reviewer-bot/constants"
@@ -25,6 +24,8 @@ type GiteaPullRequestHook struct {
 	Number      int64  `json:"number"`
 	PullRequest struct {
 		State string `json:"state"`
+		Title string `json:"title"`
+		Body  string `json:"body"`
 	} `json:"pull_request"`
 	Repository struct {
 		Owner struct {
@@ -35,13 +36,20 @@ type GiteaPullRequestHook struct {
 }
 
 type GiteaWebhookHandler struct {
-	g      *genkit.Genkit
-	config *config.Config
-	secret string
+	reviews *ReviewQueue
+	config  *config.Config
+	secret  string
 }
 
-func NewGiteaWebhookHandler(g *genkit.Genkit, cfg *config.Config, secret string) (*GiteaWebhookHandler, error) {
-	return &GiteaWebhookHandler{g: g, config: cfg, secret: secret}, nil
+func NewGiteaWebhookHandler(reviews *ReviewQueue, cfg *config.Config, secret string) (*GiteaWebhookHandler, error) {
+	if secret == "" {
+		return nil, errEmptySecret
+	}
+	h := &GiteaWebhookHandler{reviews: reviews, config: cfg, secret: secret}
+	reviews.register(constants.GITEA, func(ctx context.Context, req *ReviewRequest) (vcs.VCSAdapter, error) {
+		return vcs.NewGiteaClient(ctx, cfg.VCS.Gitea.BaseURL, cfg.VCS.Gitea.Token), nil
+	})
+	return h, nil
 }
 
 func (h *GiteaWebhookHandler) Handle(c *gin.Context) {
@@ -53,11 +61,10 @@ func (h *GiteaWebhookHandler) Handle(c *gin.Context) {
 	mac.Write(body)
 	expectedSignature := hex.EncodeToString(mac.Sum(nil))
 
-	if signature != expectedSignature {
+	if signature == "" || !hmac.Equal([]byte(signature), []byte(expectedSignature)) {
 		c.String(http.StatusForbidden, "Forbidden: Invalid signature")
 		return
 	}
-
 	var payload GiteaPullRequestHook
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		c.String(http.StatusBadRequest, "Bad Request")
@@ -66,28 +73,22 @@ func (h *GiteaWebhookHandler) Handle(c *gin.Context) {
 
 	action := payload.Action
 	if action == constants.OPENED || action == constants.SYNCHRONIZE || action == constants.REOPENED {
+		if !h.reviews.claimDelivery(c, constants.GITEA, c.GetHeader("X-Gitea-Delivery"), signature) {
+			return
+		}
 		log.Printf("Received Gitea PR event: %s for PR #%d", action, payload.Number)
-		go h.processPullRequest(&payload)
-		c.String(http.StatusOK, "Event received.")
+		h.reviews.enqueue(c, &ReviewRequest{
+			Platform: constants.GITEA,
+			PR: reviewer.PRDetails{
+				Owner:    payload.Repository.Owner.Login,
+				Repo:     payload.Repository.Name,
+				PRNumber: int(payload.Number),
+				Title:    payload.PullRequest.Title,
+				Body:     payload.PullRequest.Body,
+			},
+		})
 	} else {
 		log.Printf("Ignoring Gitea PR action: %s", action)
 		c.String(http.StatusOK, "Event ignored.")
 	}
 }
-
-func (h *GiteaWebhookHandler) processPullRequest(payload *GiteaPullRequestHook) {
-	ctx := context.Background()
-	prDetails := &reviewer.PRDetails{
-		Owner:    payload.Repository.Owner.Login,
-		Repo:     payload.Repository.Name,
-		PRNumber: int(payload.Number),
-	}
-
-	vcsClient := vcs.NewGiteaClient(ctx, h.config.VCS.Gitea.BaseURL, h.config.VCS.Gitea.Token)
-
-	_, err := reviewer.RunReview(ctx, h.g, prDetails, h.config, vcsClient)
-	if err != nil {
-		log.Printf("Code review failed for Gitea PR #%d: %v", prDetails.PRNumber, err)
-		vcsClient.PostGeneralComment(ctx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber, "❌ AI Review Failed: An internal error occurred.")
-	}
-}
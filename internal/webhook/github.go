@@ -5,7 +5,6 @@ import (
 	"log"
 	"net/http"
 
-	"github.com/firebase/genkit/go/genkit"
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v62/github"
 	"github.com/surya84/code-reviewer-bot/config"
@@ -16,18 +15,31 @@ import (
 
 // GitHubWebhookHandler no longer stores a reference to a flow.
 type GitHubWebhookHandler struct {
-	g      *genkit.Genkit
-	config *config.Config
-	secret []byte
+	reviews *ReviewQueue
+	config  *config.Config
+	secret  []byte
+	app     *vcs.GitHubApp // Set when running as a GitHub App; nil for token authentication.
 }
 
 // NewGitHubWebhookHandler is simplified.
-func NewGitHubWebhookHandler(g *genkit.Genkit, cfg *config.Config, secret string) (*GitHubWebhookHandler, error) {
-	return &GitHubWebhookHandler{
-		g:      g,
-		config: cfg,
-		secret: []byte(secret),
-	}, nil
+func NewGitHubWebhookHandler(reviews *ReviewQueue, cfg *config.Config, secret string) (*GitHubWebhookHandler, error) {
+	if secret == "" {
+		return nil, errEmptySecret
+	}
+	h := &GitHubWebhookHandler{
+		reviews: reviews,
+		config:  cfg,
+		secret:  []byte(secret),
+	}
+	if cfg.VCS.GitHub.AppID != 0 {
+		app, err := vcs.NewGitHubAppFromConfig(&cfg.VCS.GitHub)
+		if err != nil {
+			return nil, err
+		}
+		h.app = app
+	}
+	reviews.register(constants.GITHUB, h.newClient)
+	return h, nil
 }
 
 // Handle is the Gin handler function for the webhook endpoint.
@@ -47,40 +59,62 @@ func (h *GitHubWebhookHandler) Handle(c *gin.Context) {
 	switch event := event.(type) {
 	case *github.PullRequestEvent:
 		action := event.GetAction()
-		if action == constants.OPENED || action == constants.SYNCHRONIZE || action == constants.REOPENED {
-			log.Printf("Received GitHub PR event: %s for PR #%d", action, event.GetNumber())
-			go h.processPullRequest(event)
-		} else {
+		if action != constants.OPENED && action != constants.SYNCHRONIZE && action != constants.REOPENED {
 			log.Printf("Ignoring GitHub PR action: %s", action)
+			c.String(http.StatusOK, "Event ignored.")
+			return
+		}
+		pr := event.GetPullRequest()
+		if pr.GetState() != constants.OPEN {
+			log.Printf("Ignoring PR #%d because its state is '%s'", pr.GetNumber(), pr.GetState())
+			c.String(http.StatusOK, "Event ignored.")
+			return
+		}
+		// Only a delivery that starts a review is claimed, so an ignored or malformed one
+		// cannot use up the delivery ID or signature of a later, valid one.
+		signature := c.GetHeader(github.SHA256SignatureHeader)
+		if signature == "" {
+			signature = c.GetHeader(github.SHA1SignatureHeader)
+		}
+		if !h.reviews.claimDelivery(c, constants.GITHUB, github.DeliveryID(c.Request), signature) {
+			return
 		}
-		c.String(http.StatusOK, "Event received.")
+		log.Printf("Received GitHub PR event: %s for PR #%d", action, event.GetNumber())
+		h.reviews.enqueue(c, &ReviewRequest{
+			Platform: constants.GITHUB,
+			PR: reviewer.PRDetails{
+				Owner:    pr.Base.Repo.GetOwner().GetLogin(),
+				Repo:     pr.Base.Repo.GetName(),
+				PRNumber: pr.GetNumber(),
+				Title:    pr.GetTitle(),
+				Body:     pr.GetBody(),
+			},
+			InstallationID: event.GetInstallation().GetID(),
+		})
 	default:
 		log.Printf("Ignoring GitHub webhook event type: %T", event)
 		c.String(http.StatusOK, "Event type ignored.")
 	}
 }
 
-// processPullRequest now explicitly creates a GitHubClient.
-func (h *GitHubWebhookHandler) processPullRequest(event *github.PullRequestEvent) {
-	ctx := context.Background()
-
-	pr := event.GetPullRequest()
-	if pr.GetState() != constants.OPEN {
-		log.Printf("Ignoring PR #%d because its state is '%s'", pr.GetNumber(), pr.GetState())
-		return
+// newClient creates a GitHub client for a queued review. As a GitHub App it authenticates as
+// the installation named in the event, falling back to the configured or looked-up installation.
+func (h *GitHubWebhookHandler) newClient(ctx context.Context, req *ReviewRequest) (vcs.VCSAdapter, error) {
+	if h.app == nil {
+		// Explicitly create a GitHub client, ignoring the static config provider.
+		return vcs.NewGitHubClientFromConfig(ctx, &h.config.VCS.GitHub)
 	}
-	prDetails := &reviewer.PRDetails{
-		Owner:    pr.Base.Repo.GetOwner().GetLogin(),
-		Repo:     pr.Base.Repo.GetName(),
-		PRNumber: pr.GetNumber(),
-	}
-
-	// CORRECTED: Explicitly create a GitHub client, ignoring the static config provider.
-	vcsClient := vcs.NewGitHubClient(ctx, h.config.VCS.GitHub.Token)
 
-	_, err := reviewer.RunReview(ctx, h.g, prDetails, h.config, vcsClient)
-	if err != nil {
-		log.Printf("Code review failed for GitHub PR #%d: %v", prDetails.PRNumber, err)
-		vcsClient.PostGeneralComment(ctx, prDetails.Owner, prDetails.Repo, prDetails.PRNumber, "❌ AI Review Failed: An internal error occurred.")
+	installationID := req.InstallationID
+	if installationID == 0 {
+		installationID = h.config.VCS.GitHub.InstallationID
+	}
+	if installationID == 0 {
+		id, err := h.app.FindInstallation(ctx, req.PR.Owner, req.PR.Repo)
+		if err != nil {
+			return nil, err
+		}
+		installationID = id
 	}
+	return h.app.Client(ctx, installationID)
 }
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/internal/reviewer"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+// GitLabMergeRequestHook represents the structure of GitLab's Merge Request Hook payload.
+type GitLabMergeRequestHook struct {
+	ObjectKind       string `json:"object_kind"`
+	ObjectAttributes struct {
+		IID         int    `json:"iid"`
+		Action      string `json:"action"`
+		State       string `json:"state"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		// OldRev is only present on update events that pushed new commits.
+		OldRev string `json:"oldrev"`
+	} `json:"object_attributes"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+type GitLabWebhookHandler struct {
+	reviews *ReviewQueue
+	config  *config.Config
+	secret  string
+}
+
+func NewGitLabWebhookHandler(reviews *ReviewQueue, cfg *config.Config, secret string) (*GitLabWebhookHandler, error) {
+	if secret == "" {
+		return nil, errEmptySecret
+	}
+	h := &GitLabWebhookHandler{reviews: reviews, config: cfg, secret: secret}
+	reviews.register(constants.GITLAB, func(ctx context.Context, req *ReviewRequest) (vcs.VCSAdapter, error) {
+		return vcs.NewGitLabClient(ctx, cfg.VCS.GitLab.BaseURL, cfg.VCS.GitLab.Token), nil
+	})
+	return h, nil
+}
+
+func (h *GitLabWebhookHandler) Handle(c *gin.Context) {
+	token := c.GetHeader("X-Gitlab-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.secret)) != 1 {
+		c.String(http.StatusForbidden, "Forbidden: Invalid token")
+		return
+	}
+
+	var payload GitLabMergeRequestHook
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.String(http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	if payload.ObjectKind != constants.MERGE_REQUEST {
+		log.Printf("Ignoring GitLab webhook event kind: %s", payload.ObjectKind)
+		c.String(http.StatusOK, "Event type ignored.")
+		return
+	}
+
+	attrs := payload.ObjectAttributes
+	switch {
+	case attrs.Action == constants.OPEN || attrs.Action == constants.REOPEN:
+	case attrs.Action == constants.UPDATE && attrs.OldRev != "":
+	default:
+		// Updates without "oldrev" only touched metadata such as labels or the title.
+		log.Printf("Ignoring GitLab MR action: %s for MR !%d", attrs.Action, attrs.IID)
+		c.String(http.StatusOK, "Event ignored.")
+		return
+	}
+
+	if attrs.State != constants.OPENED {
+		log.Printf("Ignoring MR !%d because its state is '%s'", attrs.IID, attrs.State)
+		c.String(http.StatusOK, "Event ignored.")
+		return
+	}
+
+	// The namespace may contain subgroups; the adapter rejoins owner and repo into the project path.
+	fullPath := payload.Project.PathWithNamespace
+	idx := strings.LastIndex(fullPath, "/")
+	if idx == -1 {
+		log.Printf("Invalid GitLab project path: '%s'", fullPath)
+		c.String(http.StatusBadRequest, "Bad Request")
+		return
+	}
+
+	log.Printf("Received GitLab MR event: %s for MR !%d", attrs.Action, attrs.IID)
+	h.reviews.enqueue(c, &ReviewRequest{
+		Platform: constants.GITLAB,
+		PR: reviewer.PRDetails{
+			Owner:    fullPath[:idx],
+			Repo:     fullPath[idx+1:],
+			PRNumber: attrs.IID,
+			Title:    attrs.Title,
+			Body:     attrs.Description,
+		},
+	})
+}
@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/internal/reviewer"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+)
+
+func TestGitLabWebhookHandler_Handle(t *testing.T) {
+	mrEvent := func(action, state, oldRev string) string {
+		return `{"object_kind":"merge_request","object_attributes":{"iid":7,"action":"` + action + `","state":"` + state + `","oldrev":"` + oldRev + `"},"project":{"path_with_namespace":"group/sub/project"}}`
+	}
+	tests := []struct {
+		name  string
+		token string
+		body  string
+		want  int
+	}{
+		{"Opened", "secret", mrEvent("open", "opened", ""), http.StatusAccepted},
+		{"Reopened", "secret", mrEvent("reopen", "opened", ""), http.StatusAccepted},
+		{"Pushed Commits", "secret", mrEvent("update", "opened", "abc123"), http.StatusAccepted},
+		{"Wrong Token", "wrong", mrEvent("open", "opened", ""), http.StatusForbidden},
+		{"Missing Token", "", mrEvent("open", "opened", ""), http.StatusForbidden},
+		{"Metadata Update Ignored", "secret", mrEvent("update", "opened", ""), http.StatusOK},
+		{"Close Ignored", "secret", mrEvent("close", "closed", ""), http.StatusOK},
+		{"Merged Ignored", "secret", mrEvent("open", "merged", ""), http.StatusOK},
+		{"Other Event Kind Ignored", "secret", `{"object_kind":"push"}`, http.StatusOK},
+		{"Project Without Namespace", "secret", strings.Replace(mrEvent("open", "opened", ""), "group/sub/project", "project", 1), http.StatusBadRequest},
+		{"Invalid JSON", "secret", `{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := NewGitLabWebhookHandler(newHandlerTestQueue(t), &config.Config{}, "secret")
+			require.NoError(t, err)
+
+			w := serve(h.Handle, tt.body, map[string]string{"X-Gitlab-Token": tt.token})
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestGitLabWebhookHandler_SubgroupProjectPath(t *testing.T) {
+	reviews := newHandlerTestQueue(t)
+	h, err := NewGitLabWebhookHandler(reviews, &config.Config{}, "secret")
+	require.NoError(t, err)
+
+	// Replace the GitLab client factory to capture the queued request instead of reviewing it.
+	queued := make(chan reviewer.PRDetails, 1)
+	reviews.register(constants.GITLAB, func(ctx context.Context, req *ReviewRequest) (vcs.VCSAdapter, error) {
+		select {
+		case queued <- req.PR:
+		default:
+		}
+		return nil, errors.New("not reviewed in this test")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reviews.Start(ctx)
+
+	body := `{"object_kind":"merge_request","object_attributes":{"iid":7,"action":"open","state":"opened","title":"Add b"},"project":{"path_with_namespace":"group/sub/project"}}`
+	w := serve(h.Handle, body, map[string]string{"X-Gitlab-Token": "secret"})
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	select {
+	case pr := <-queued:
+		assert.Equal(t, reviewer.PRDetails{Owner: "group/sub", Repo: "project", PRNumber: 7, Title: "Add b"}, pr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the review was not queued")
+	}
+}
@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/gin-gonic/gin"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/internal/queue"
+	"github.com/surya84/code-reviewer-bot/internal/reviewer"
+	"github.com/surya84/code-reviewer-bot/pkg/tracing"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errEmptySecret is returned by the handler constructors: with an empty secret anyone could
+// compute valid signatures.
+var errEmptySecret = errors.New("webhook secret must not be empty")
+
+// ReviewRequest is what a queued review job stores: enough to rebuild the VCS client and run
+// the review after a restart.
+type ReviewRequest struct {
+	Platform string             `json:"platform"`
+	PR       reviewer.PRDetails `json:"pr"`
+	// InstallationID is the GitHub App installation named in the event, if any.
+	InstallationID int64 `json:"installation_id,omitempty"`
+	// Trace carries the trace context of the webhook, so the review continues its trace.
+	Trace map[string]string `json:"trace,omitempty"`
+}
+
+// clientFactory creates the VCS client for a queued review.
+type clientFactory func(ctx context.Context, req *ReviewRequest) (vcs.VCSAdapter, error)
+
+// ReviewQueue runs webhook-triggered reviews from a durable job queue shared by every handler.
+// A new event for a PR replaces the review that is pending or running for it, so reviews never
+// race each other against stale commits, and the debounce window lets a burst of pushes settle
+// into a single review.
+type ReviewQueue struct {
+	g          *genkit.Genkit
+	config     *config.Config
+	queue      *queue.Queue
+	deliveries *deliveryCache
+
+	mu      sync.RWMutex
+	clients map[string]clientFactory // By platform.
+}
+
+// NewReviewQueue opens the review queue configured under server.
+func NewReviewQueue(g *genkit.Genkit, cfg *config.Config) (*ReviewQueue, error) {
+	path := cfg.Server.QueuePath
+	if path == "" {
+		path = config.DefaultQueuePath
+	}
+	return newReviewQueue(g, cfg, path, queue.Options{
+		Workers:     cfg.Server.Workers,
+		MaxAttempts: cfg.Server.MaxAttempts,
+		Debounce:    time.Duration(cfg.Server.DebounceSeconds) * time.Second,
+	})
+}
+
+func newReviewQueue(g *genkit.Genkit, cfg *config.Config, path string, opts queue.Options) (*ReviewQueue, error) {
+	ttl := time.Duration(cfg.Server.DeliveryTTLMinutes) * time.Minute
+	if ttl == 0 {
+		ttl = defaultDeliveryTTL
+	}
+	deliveries, err := newDeliveryCache(cfg.Server.DeliveryCachePath, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("could not open webhook delivery cache: %w", err)
+	}
+	r := &ReviewQueue{g: g, config: cfg, deliveries: deliveries, clients: make(map[string]clientFactory)}
+	opts.OnDead = r.reportFailure
+	q, err := queue.Open(path, opts)
+	if err != nil {
+		deliveries.Close()
+		return nil, err
+	}
+	r.queue = q
+	return r, nil
+}
+
+// register sets how clients are created for a platform's reviews.
+func (r *ReviewQueue) register(platform string, newClient clientFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[platform] = newClient
+}
+
+// Start runs queued reviews, including those left over from before a restart. Handlers
+// must be registered first so that resumed jobs find their client factory.
+func (r *ReviewQueue) Start(ctx context.Context) {
+	r.queue.Start(ctx, r.run)
+}
+
+// Close stops running reviews, which resume on the next start, and closes the queue file.
+func (r *ReviewQueue) Close() error {
+	err := r.queue.Close()
+	if cerr := r.deliveries.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// enqueue queues a review and answers the webhook with 202 and the job ID.
+func (r *ReviewQueue) enqueue(c *gin.Context, req *ReviewRequest) {
+	ctx := c.Request.Context()
+	key := prKey(req.Platform, req.PR.Owner+"/"+req.PR.Repo, req.PR.PRNumber)
+	req.Trace = tracing.Inject(ctx)
+	job, err := r.queue.Enqueue(key, req)
+	if err != nil {
+		tracing.Logf(ctx, "Error queueing review of %s: %v", key, err)
+		r.releaseDelivery(c)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("queue.job_id", int64(job.ID)))
+	tracing.Logf(ctx, "Queued review of %s as job %d.", key, job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID})
+}
+
+// prKey identifies a pull request across platforms; repoPath is the full repository path.
+func prKey(platform, repoPath string, number int) string {
+	return fmt.Sprintf("%s:%s#%d", platform, repoPath, number)
+}
+
+// run reviews the pull request of a queued job.
+func (r *ReviewQueue) run(ctx context.Context, job *queue.Job) error {
+	req, vcsClient, err := r.decode(ctx, job)
+	if err != nil {
+		return err
+	}
+	ctx = tracing.Extract(ctx, req.Trace)
+	_, err = reviewer.RunReview(ctx, r.g, &req.PR, r.config, vcsClient)
+	return err
+}
+
+// reportFailure tells the PR author that a review was given up after its last retry.
+func (r *ReviewQueue) reportFailure(job *queue.Job) {
+	ctx := context.Background()
+	req, vcsClient, err := r.decode(ctx, job)
+	if err != nil {
+		log.Printf("Could not report failure of job %d: %v", job.ID, err)
+		return
+	}
+	vcsClient.PostGeneralComment(ctx, req.PR.Owner, req.PR.Repo, req.PR.PRNumber, "❌ AI Review Failed: An internal error occurred.")
+}
+
+// decode reads a job's review request and creates the client for its platform.
+func (r *ReviewQueue) decode(ctx context.Context, job *queue.Job) (*ReviewRequest, vcs.VCSAdapter, error) {
+	var req ReviewRequest
+	if err := json.Unmarshal(job.Payload, &req); err != nil {
+		return nil, nil, fmt.Errorf("invalid review request in job %d: %w", job.ID, err)
+	}
+	r.mu.RLock()
+	newClient, ok := r.clients[req.Platform]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("no webhook handler is configured for %s", req.Platform)
+	}
+	vcsClient, err := newClient(ctx, &req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create %s client: %w", req.Platform, err)
+	}
+	return &req, vcsClient, nil
+}
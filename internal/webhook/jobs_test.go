@@ -0,0 +1,268 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/internal/queue"
+	"github.com/surya84/code-reviewer-bot/internal/reviewer"
+	"github.com/surya84/code-reviewer-bot/pkg/tracing"
+	"github.com/surya84/code-reviewer-bot/pkg/vcs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const testDiff = `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,2 +1,3 @@
+ package a
++var x = 1
+ func f() {}
+`
+
+// fakeAdapter is an in-memory VCSAdapter that records what a review posts.
+type fakeAdapter struct {
+	mu       sync.Mutex
+	diffErr  error
+	reviews  [][]*vcs.Comment
+	comments []string
+}
+
+func (f *fakeAdapter) GetPRDiff(ctx context.Context, owner, repo string, prNumber int) (string, error) {
+	if f.diffErr != nil {
+		return "", f.diffErr
+	}
+	return testDiff, nil
+}
+
+func (f *fakeAdapter) posted() ([][]*vcs.Comment, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reviews, f.comments
+}
+
+func (f *fakeAdapter) PostReview(ctx context.Context, owner, repo string, prNumber int, comments []*vcs.Comment, commitID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reviews = append(f.reviews, comments)
+	return nil
+}
+
+func (f *fakeAdapter) PostGeneralComment(ctx context.Context, owner, repo string, prNumber int, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.comments = append(f.comments, body)
+	return nil
+}
+
+func (f *fakeAdapter) GetPRCommitID(ctx context.Context, owner, repo string, prNumber int) (string, error) {
+	return "abc123", nil
+}
+
+func (f *fakeAdapter) GetCompareDiff(ctx context.Context, owner, repo, base, head string) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (f *fakeAdapter) FindGeneralComment(ctx context.Context, owner, repo string, prNumber int, marker, author string) (*vcs.GeneralComment, error) {
+	return nil, nil
+}
+
+func (f *fakeAdapter) EditGeneralComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) error {
+	return nil
+}
+
+func (f *fakeAdapter) ListReviewComments(ctx context.Context, owner, repo string, prNumber int) ([]*vcs.LineComment, error) {
+	return nil, nil
+}
+
+func (f *fakeAdapter) CurrentUser(ctx context.Context) (string, error) {
+	return "review-bot", nil
+}
+
+func (f *fakeAdapter) EditReviewComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) error {
+	return nil
+}
+
+// setupBlockingModel registers a model whose first review call blocks until its context is
+// cancelled, closing started when it begins. Later review calls return one comment, and
+// summary calls fail so that reviews post nothing but their line comments.
+func setupBlockingModel(t *testing.T) (*genkit.Genkit, *config.Config, <-chan struct{}) {
+	g, err := genkit.Init(context.Background())
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	var once sync.Once
+	info := &ai.ModelInfo{Supports: &ai.ModelSupports{Constrained: ai.ConstrainedSupportAll}}
+	genkit.DefineModel(g, "fake", "reviewer", info, func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+		prompt := req.Messages[len(req.Messages)-1].Text()
+		if !strings.HasPrefix(prompt, "Review ") {
+			return nil, errors.New("no summary")
+		}
+		first := false
+		once.Do(func() { first = true })
+		if first {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		text := `{"comments":[{"line":2,"line_content":"","message":"Unused.","severity":"minor","category":"bug","confidence":0.9}]}`
+		return &ai.ModelResponse{Request: req, Message: ai.NewModelTextMessage(text)}, nil
+	})
+
+	cfg := &config.Config{ReviewPrompt: "Review {{.FilePath}}:\n{{.CodeSnippet}}"}
+	cfg.LLM.ModelName = "fake/reviewer"
+	return g, cfg, started
+}
+
+// newTestQueue opens a review queue in a temporary directory whose "fake" platform hands out
+// the adapter registered under the PR title.
+func newTestQueue(t *testing.T, g *genkit.Genkit, cfg *config.Config, opts queue.Options, adapters map[string]*fakeAdapter) *ReviewQueue {
+	t.Helper()
+	reviews, err := newReviewQueue(g, cfg, filepath.Join(t.TempDir(), "queue.db"), opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { reviews.Close() })
+	reviews.register("fake", func(ctx context.Context, req *ReviewRequest) (vcs.VCSAdapter, error) {
+		return adapters[req.PR.Title], nil
+	})
+	return reviews
+}
+
+func enqueueReview(t *testing.T, reviews *ReviewQueue, title string) {
+	t.Helper()
+	_, err := reviews.queue.Enqueue(prKey("fake", "owner/repo", 1), &ReviewRequest{
+		Platform: "fake",
+		PR:       reviewer.PRDetails{Owner: "owner", Repo: "repo", PRNumber: 1, Title: title},
+	})
+	require.NoError(t, err)
+}
+
+func TestReviewQueue_CancelsSupersededReview(t *testing.T) {
+	g, cfg, started := setupBlockingModel(t)
+	stale, current := &fakeAdapter{}, &fakeAdapter{}
+	reviews := newTestQueue(t, g, cfg, queue.Options{}, map[string]*fakeAdapter{"stale": stale, "current": current})
+	reviews.Start(context.Background())
+
+	enqueueReview(t, reviews, "stale")
+	<-started
+	enqueueReview(t, reviews, "current")
+
+	assert.Eventually(t, func() bool {
+		posted, _ := current.posted()
+		return len(posted) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	posted, _ := current.posted()
+	assert.Len(t, posted[0], 1)
+	staleReviews, staleComments := stale.posted()
+	assert.Empty(t, staleReviews, "the superseded review posts nothing")
+	assert.Empty(t, staleComments, "a cancelled review is not reported as a failure")
+}
+
+func TestReviewQueue_ReportsDeadReviews(t *testing.T) {
+	g, cfg, _ := setupBlockingModel(t)
+	broken := &fakeAdapter{diffErr: errors.New("401 Unauthorized")}
+	reviews := newTestQueue(t, g, cfg, queue.Options{MaxAttempts: 2, Backoff: time.Millisecond}, map[string]*fakeAdapter{"broken": broken})
+	reviews.Start(context.Background())
+
+	enqueueReview(t, reviews, "broken")
+
+	assert.Eventually(t, func() bool {
+		_, comments := broken.posted()
+		return len(comments) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	_, comments := broken.posted()
+	assert.Equal(t, []string{"❌ AI Review Failed: An internal error occurred."}, comments)
+	dead, err := reviews.queue.Dead()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, 2, dead[0].Attempts)
+}
+
+func TestGitLabWebhookHandler_QueuesReview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	reviews, err := newReviewQueue(nil, cfg, filepath.Join(t.TempDir(), "queue.db"), queue.Options{})
+	require.NoError(t, err)
+	defer reviews.Close()
+	h, err := NewGitLabWebhookHandler(reviews, cfg, "secret")
+	require.NoError(t, err)
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/gitlab/webhook", strings.NewReader(body))
+		c.Request.Header.Set("X-Gitlab-Token", token)
+		h.Handle(c)
+		return w
+	}
+	event := `{"object_kind":"merge_request","object_attributes":{"iid":7,"action":"open","state":"opened"},"project":{"path_with_namespace":"group/sub/project"}}`
+
+	w := post("secret", event)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.JSONEq(t, `{"job_id":1}`, w.Body.String())
+
+	w = post("wrong", event)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = post("secret", strings.Replace(event, `"opened"`, `"merged"`, 1))
+	assert.Equal(t, http.StatusOK, w.Code, "closed merge requests are not queued")
+}
+
+func TestReviewQueue_ContinuesWebhookTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider(sdktrace.WithSyncer(exporter), config.TracingConfig{})
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	reviews, err := newReviewQueue(nil, cfg, filepath.Join(t.TempDir(), "queue.db"), queue.Options{MaxAttempts: 1})
+	require.NoError(t, err)
+	defer reviews.Close()
+	h, err := NewGitLabWebhookHandler(reviews, cfg, "secret")
+	require.NoError(t, err)
+	adapter := &fakeAdapter{diffErr: errors.New("404 Not Found")}
+	reviews.register(constants.GITLAB, func(ctx context.Context, req *ReviewRequest) (vcs.VCSAdapter, error) {
+		return adapter, nil
+	})
+	router := gin.New()
+	router.POST("/api/gitlab/webhook", tracing.Webhook(constants.GITLAB), h.Handle)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/gitlab/webhook", strings.NewReader(
+		`{"object_kind":"merge_request","object_attributes":{"iid":7,"action":"open","state":"opened"},"project":{"path_with_namespace":"group/project"}}`))
+	req.Header.Set("X-Gitlab-Token", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	reviews.Start(context.Background())
+
+	assert.Eventually(t, func() bool {
+		_, comments := adapter.posted()
+		return len(comments) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	require.Contains(t, spans, "webhook.receive")
+	require.Contains(t, spans, "review")
+	assert.Equal(t, spans["webhook.receive"].SpanContext.TraceID(), spans["review"].SpanContext.TraceID())
+	assert.Contains(t, spans["webhook.receive"].Attributes, attribute.Int64("queue.job_id", 1))
+}
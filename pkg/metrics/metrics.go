@@ -0,0 +1,165 @@
+// Package metrics holds the Prometheus metrics shared by the review server, the reviewer and
+// the VCS adapters, and serves them for scraping.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "code_reviewer"
+
+// Registry holds every metric of the bot, plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+var (
+	// Webhooks counts webhook requests by provider and outcome: received, ignored, rejected or error.
+	Webhooks = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhooks_total",
+		Help:      "Webhook requests by provider and outcome.",
+	}, []string{"provider", "outcome"})
+
+	// Reviews counts reviews by outcome: started, succeeded, failed or cancelled.
+	Reviews = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reviews_total",
+		Help:      "Pull request reviews by outcome.",
+	}, []string{"outcome"})
+
+	// ChunksAnalyzed counts diff chunks sent to the LLM by result: ok or failed.
+	ChunksAnalyzed = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "chunks_analyzed_total",
+		Help:      "Diff chunks analyzed by the LLM, by result.",
+	}, []string{"result"})
+
+	// LLMLatency observes the duration of each model call.
+	LLMLatency = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Duration of LLM requests by model.",
+		Buckets:   prometheus.ExponentialBuckets(0.25, 2, 10),
+	}, []string{"model"})
+
+	// LLMTokens counts the tokens reported by the model, by direction: input or output.
+	LLMTokens = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "LLM tokens used by model and direction.",
+	}, []string{"model", "direction"})
+
+	// LLMParseFailures counts LLM responses that did not match the requested schema.
+	LLMParseFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_parse_failures_total",
+		Help:      "LLM responses that did not match the requested schema, by model.",
+	}, []string{"model"})
+
+	// LLMFailovers counts the times a model was given up on for the next one in the fallback chain.
+	LLMFailovers = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_failovers_total",
+		Help:      "Failovers from a model to the next one in the fallback chain, by the model given up on.",
+	}, []string{"model"})
+
+	// CommentsPosted counts review comments posted, by severity.
+	CommentsPosted = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comments_posted_total",
+		Help:      "Review comments posted by severity.",
+	}, []string{"severity"})
+
+	// VCSErrors counts failed VCS API requests by provider and status code, or "error" when
+	// no response was received.
+	VCSErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vcs_api_errors_total",
+		Help:      "Failed VCS API requests by provider and status code.",
+	}, []string{"provider", "status"})
+)
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Webhook is Gin middleware that counts the webhook requests of a provider by the status the
+// handler answered with: 202 when a review was queued, other 2xx when the event was ignored.
+func Webhook(provider string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		status := c.Writer.Status()
+		outcome := "ignored"
+		switch {
+		case status == http.StatusAccepted:
+			outcome = "received"
+		case status >= 500:
+			outcome = "error"
+		case status >= 400:
+			outcome = "rejected"
+		}
+		Webhooks.WithLabelValues(provider, outcome).Inc()
+	}
+}
+
+// ReviewFinished counts the outcome of a review that returned err from ctx.
+func ReviewFinished(ctx context.Context, err error) {
+	switch {
+	case err == nil:
+		Reviews.WithLabelValues("succeeded").Inc()
+	case ctx.Err() != nil || errors.Is(err, context.Canceled):
+		Reviews.WithLabelValues("cancelled").Inc()
+	default:
+		Reviews.WithLabelValues("failed").Inc()
+	}
+}
+
+// ObserveLLMCall records the latency and token usage of a model call.
+func ObserveLLMCall(model string, elapsed time.Duration, inputTokens, outputTokens int) {
+	LLMLatency.WithLabelValues(model).Observe(elapsed.Seconds())
+	if inputTokens > 0 {
+		LLMTokens.WithLabelValues(model, "input").Add(float64(inputTokens))
+	}
+	if outputTokens > 0 {
+		LLMTokens.WithLabelValues(model, "output").Add(float64(outputTokens))
+	}
+}
+
+// Transport wraps next to count the provider's failed API requests.
+func Transport(provider string, next http.RoundTripper) http.RoundTripper {
+	return &transport{provider: provider, next: next}
+}
+
+type transport struct {
+	provider string
+	next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		if req.Context().Err() == nil {
+			VCSErrors.WithLabelValues(t.provider, "error").Inc()
+		}
+	case resp.StatusCode >= 400:
+		VCSErrors.WithLabelValues(t.provider, strconv.Itoa(resp.StatusCode)).Inc()
+	}
+	return resp, err
+}
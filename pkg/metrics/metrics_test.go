@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/hook", Webhook("test"), func(c *gin.Context) {
+		status := http.StatusOK
+		switch c.Query("event") {
+		case "pr":
+			status = http.StatusAccepted
+		case "forged":
+			status = http.StatusForbidden
+		case "broken":
+			status = http.StatusInternalServerError
+		}
+		c.Status(status)
+	})
+
+	for _, event := range []string{"pr", "pr", "push", "forged", "broken"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/hook?event="+event, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(Webhooks.WithLabelValues("test", "received")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(Webhooks.WithLabelValues("test", "ignored")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(Webhooks.WithLabelValues("test", "rejected")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(Webhooks.WithLabelValues("test", "error")))
+}
+
+func TestReviewFinished(t *testing.T) {
+	count := func(outcome string) float64 { return testutil.ToFloat64(Reviews.WithLabelValues(outcome)) }
+	succeeded, failed, cancelled := count("succeeded"), count("failed"), count("cancelled")
+
+	ReviewFinished(context.Background(), nil)
+	ReviewFinished(context.Background(), errors.New("failed to get PR diff"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ReviewFinished(ctx, errors.New("review was cancelled"))
+
+	assert.Equal(t, succeeded+1, count("succeeded"))
+	assert.Equal(t, failed+1, count("failed"))
+	assert.Equal(t, cancelled+1, count("cancelled"))
+}
+
+func TestObserveLLMCall(t *testing.T) {
+	ObserveLLMCall("test/model", 2*time.Second, 120, 30)
+	ObserveLLMCall("test/model", time.Second, 0, 0)
+
+	assert.Equal(t, 120.0, testutil.ToFloat64(LLMTokens.WithLabelValues("test/model", "input")))
+	assert.Equal(t, 30.0, testutil.ToFloat64(LLMTokens.WithLabelValues("test/model", "output")))
+	assert.Equal(t, 1, testutil.CollectAndCount(LLMLatency))
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: Transport("forge", http.DefaultTransport)}
+
+	for _, path := range []string{"/ok", "/missing", "/missing", "/limited"} {
+		resp, err := client.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	_, err := client.Get("http://127.0.0.1:1/unreachable")
+	require.Error(t, err)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(VCSErrors.WithLabelValues("forge", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(VCSErrors.WithLabelValues("forge", "429")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(VCSErrors.WithLabelValues("forge", "error")))
+}
+
+func TestHandler(t *testing.T) {
+	Reviews.WithLabelValues("started").Inc()
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `code_reviewer_reviews_total{outcome="started"}`)
+	assert.Contains(t, body, "go_goroutines")
+}
@@ -0,0 +1,120 @@
+// Package tracing sets up OpenTelemetry tracing for the review server and the reviewer, and
+// ties log lines to the trace they were written in.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/surya84/code-reviewer-bot/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/surya84/code-reviewer-bot"
+	defaultServiceName  = "code-reviewer-bot"
+)
+
+// propagator carries trace context across the review queue.
+var propagator = propagation.TraceContext{}
+
+// Tracer returns the bot's tracer. Spans are dropped until Setup installs a provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs the global tracer provider exporting to the configured OTLP/HTTP endpoint.
+// Without an endpoint it does nothing. The returned function flushes and stops the exporter.
+func Setup(ctx context.Context, cfg config.TracingConfig) (shutdown func(context.Context) error, err error) {
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	tp := NewProvider(sdktrace.WithBatcher(exporter), cfg)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// NewProvider creates a tracer provider that samples as configured and sends spans to the
+// given processor; tests pass an in-memory exporter with sdktrace.WithSyncer.
+func NewProvider(processor sdktrace.TracerProviderOption, cfg config.TracingConfig) *sdktrace.TracerProvider {
+	ratio := cfg.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	return sdktrace.NewTracerProvider(
+		processor,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+}
+
+// Start starts a span of the bot's tracer.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Logf logs like log.Printf, prefixed with the ID of the trace in ctx when there is one.
+func Logf(ctx context.Context, format string, args ...any) {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		format = "[trace_id=" + sc.TraceID().String() + "] " + format
+	}
+	log.Printf(format, args...)
+}
+
+// Inject encodes the trace context of ctx, so that work queued now can continue the trace.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns ctx continuing the trace context that Inject encoded.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	return propagator.Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// Webhook is Gin middleware that traces the receipt of a provider's webhook. The handler sees
+// the span in its request context.
+func Webhook(provider string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, span := Start(c.Request.Context(), "webhook.receive", attribute.String("vcs.provider", provider))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("webhook handler returned %d", status))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupInMemory(t *testing.T, cfg config.TracingConfig) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := NewProvider(sdktrace.WithSyncer(exporter), cfg)
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		tp.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func TestSetup_WithoutEndpoint(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	shutdown, err := Setup(context.Background(), config.TracingConfig{})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+	assert.Equal(t, previous, otel.GetTracerProvider(), "no provider is installed without an endpoint")
+}
+
+func TestNewProvider_Sampling(t *testing.T) {
+	exporter := setupInMemory(t, config.TracingConfig{SampleRatio: 0.0000001})
+	for i := 0; i < 10; i++ {
+		_, span := Start(context.Background(), "review")
+		span.End()
+	}
+	assert.Empty(t, exporter.GetSpans(), "a tiny ratio samples nothing")
+}
+
+func TestLogf(t *testing.T) {
+	setupInMemory(t, config.TracingConfig{})
+	var buf bytes.Buffer
+	output, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(output)
+		log.SetFlags(flags)
+	}()
+
+	Logf(context.Background(), "no trace %d", 1)
+	ctx, span := Start(context.Background(), "review")
+	defer span.End()
+	Logf(ctx, "in trace %d", 2)
+
+	assert.Equal(t, "no trace 1\n[trace_id="+span.SpanContext().TraceID().String()+"] in trace 2\n", buf.String())
+}
+
+func TestInjectExtract(t *testing.T) {
+	setupInMemory(t, config.TracingConfig{})
+	assert.Nil(t, Inject(context.Background()))
+
+	ctx, span := Start(context.Background(), "webhook.receive")
+	defer span.End()
+	carrier := Inject(ctx)
+	require.Contains(t, carrier, "traceparent")
+
+	remote := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+	assert.Equal(t, span.SpanContext().TraceID(), remote.TraceID())
+	assert.True(t, remote.IsRemote())
+}
+
+func TestWebhook(t *testing.T) {
+	exporter := setupInMemory(t, config.TracingConfig{})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var handlerTrace trace.TraceID
+	router.POST("/hook", Webhook("github"), func(c *gin.Context) {
+		handlerTrace = trace.SpanContextFromContext(c.Request.Context()).TraceID()
+		c.Status(http.StatusInternalServerError)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/hook", nil))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "webhook.receive", spans[0].Name)
+	assert.Equal(t, spans[0].SpanContext.TraceID(), handlerTrace, "the handler runs inside the span")
+	assert.Contains(t, spans[0].Attributes, attribute.String("vcs.provider", "github"))
+	assert.Contains(t, spans[0].Attributes, attribute.Int("http.response.status_code", http.StatusInternalServerError))
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+}
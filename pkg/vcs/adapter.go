@@ -1,13 +1,43 @@
 package vcs
 
-import "context"
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotAncestor is returned by GetCompareDiff when the base commit is not an ancestor of
+// the head commit, typically because a force-push rewrote the branch's history.
+var ErrNotAncestor = errors.New("base commit is not an ancestor of head")
 
 // Comment represents a single review comment to be posted.
 type Comment struct {
 	Body     string
 	Path     string
+	OldPath  string // The file's path before a rename; empty when the path did not change.
 	Position int
 	Line     int
+	OldLine  int // For GitLab, set only when anchoring on an unchanged line.
+	// Severity, Category and Confidence are the LLM's assessment; Body already shows them as badges.
+	Severity   string
+	Category   string
+	Confidence float64
+	// Fingerprint identifies the finding across runs; Body embeds it in a hidden marker.
+	Fingerprint string
+}
+
+// GeneralComment is an existing PR-level comment.
+type GeneralComment struct {
+	ID   int64
+	Body string
+}
+
+// LineComment is an existing review comment attached to a line of the PR diff.
+type LineComment struct {
+	ID     int64
+	Path   string
+	Line   int
+	Body   string
+	Author string // As returned by CurrentUser for the bot's own comments.
 }
 
 // VCSAdapter defines the contract for a Version Control System client.
@@ -16,4 +46,19 @@ type VCSAdapter interface {
 	PostReview(ctx context.Context, owner, repo string, prNumber int, comments []*Comment, commitID string) error
 	PostGeneralComment(ctx context.Context, owner, repo string, prNumber int, body string) error
 	GetPRCommitID(ctx context.Context, owner, repo string, prNumber int) (string, error)
+	// GetCompareDiff returns the unified diff between two commits of the repository, or
+	// ErrNotAncestor if base is not an ancestor of head.
+	GetCompareDiff(ctx context.Context, owner, repo, base, head string) (string, error)
+	// FindGeneralComment returns the most recent PR-level comment by author, as returned by
+	// CurrentUser, whose body contains marker, or nil if there is none.
+	FindGeneralComment(ctx context.Context, owner, repo string, prNumber int, marker, author string) (*GeneralComment, error)
+	// EditGeneralComment replaces the body of a comment returned by FindGeneralComment.
+	EditGeneralComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) error
+	// ListReviewComments returns the line comments already posted on the PR, by any author.
+	ListReviewComments(ctx context.Context, owner, repo string, prNumber int) ([]*LineComment, error)
+	// EditReviewComment replaces the body of a comment returned by ListReviewComments.
+	EditReviewComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) error
+	// CurrentUser returns the login or ID of the account the adapter posts as, so that the
+	// bot's own comments can be told apart from others carrying the same hidden markers.
+	CurrentUser(ctx context.Context) (string, error)
 }
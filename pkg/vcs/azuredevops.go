@@ -0,0 +1,286 @@
+package vcs
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/surya84/code-reviewer-bot/constants"
+)
+
+const azureDevOpsAPIVersion = "7.1"
+
+// AzureDevOpsClient implements the VCSAdapter for Azure DevOps Repos.
+// The base URL is the organization URL, the owner is the project and the repo is the repository name.
+type AzureDevOpsClient struct {
+	rest *restClient
+}
+
+// azureDevOpsPullRequest is the subset of the pull request payload used by the client.
+type azureDevOpsPullRequest struct {
+	LastMergeSourceCommit struct {
+		CommitID string `json:"commitId"`
+	} `json:"lastMergeSourceCommit"`
+}
+
+// azureDevOpsChange is a single entry returned by the iteration changes endpoint.
+type azureDevOpsChange struct {
+	ChangeType   string `json:"changeType"`
+	OriginalPath string `json:"originalPath"`
+	Item         struct {
+		Path             string `json:"path"`
+		ObjectID         string `json:"objectId"`
+		OriginalObjectID string `json:"originalObjectId"`
+		GitObjectType    string `json:"gitObjectType"`
+	} `json:"item"`
+}
+
+// azureDevOpsFilePosition is a 1-based line/offset position inside a file.
+type azureDevOpsFilePosition struct {
+	Line   int `json:"line"`
+	Offset int `json:"offset"`
+}
+
+// azureDevOpsThreadContext anchors a thread to a range of lines in a file.
+type azureDevOpsThreadContext struct {
+	FilePath       string                   `json:"filePath"`
+	RightFileStart *azureDevOpsFilePosition `json:"rightFileStart"`
+	RightFileEnd   *azureDevOpsFilePosition `json:"rightFileEnd"`
+}
+
+// azureDevOpsComment is a single comment inside a thread.
+type azureDevOpsComment struct {
+	ParentCommentID int                  `json:"parentCommentId"`
+	Content         string               `json:"content"`
+	CommentType     int                  `json:"commentType"`
+	Author          *azureDevOpsIdentity `json:"author,omitempty"` // Only set in responses.
+}
+
+// azureDevOpsIdentity is the author of a comment.
+type azureDevOpsIdentity struct {
+	ID string `json:"id"`
+}
+
+// azureDevOpsThread is the request body for creating a pull request comment thread.
+type azureDevOpsThread struct {
+	Comments      []azureDevOpsComment      `json:"comments"`
+	Status        int                       `json:"status"`
+	ThreadContext *azureDevOpsThreadContext `json:"threadContext,omitempty"`
+}
+
+// NewAzureDevOpsClient creates a new client for interacting with the Azure DevOps API using a PAT.
+func NewAzureDevOpsClient(ctx context.Context, orgURL, token string) *AzureDevOpsClient {
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(":"+token))
+	rest := newRESTClient(constants.AZUREDEVOPS, "Azure DevOps", orgURL, func(req *http.Request) {
+		req.Header.Set("Authorization", auth)
+	})
+	return &AzureDevOpsClient{rest: rest}
+}
+
+// GetPRDiff builds a unified diff for the pull request from the latest iteration's changes,
+// since Azure DevOps does not expose a patch endpoint.
+func (a *AzureDevOpsClient) GetPRDiff(ctx context.Context, project, repo string, prID int) (string, error) {
+	var iterations struct {
+		Value []struct {
+			ID int `json:"id"`
+		} `json:"value"`
+	}
+	if _, err := a.rest.do(ctx, http.MethodGet, a.pullRequestPath(project, repo, prID, "/iterations"), nil, &iterations); err != nil {
+		return "", fmt.Errorf("failed to get PR iterations from Azure DevOps: %w", err)
+	}
+	if len(iterations.Value) == 0 {
+		return "", fmt.Errorf("Azure DevOps PR #%d has no iterations", prID)
+	}
+	latest := iterations.Value[len(iterations.Value)-1].ID
+
+	var sb strings.Builder
+	skip := 0
+	for {
+		var page struct {
+			ChangeEntries []azureDevOpsChange `json:"changeEntries"`
+			NextSkip      int                 `json:"nextSkip"`
+		}
+		// $compareTo=0 compares the iteration against the merge base rather than the previous iteration.
+		path := a.pullRequestPath(project, repo, prID, fmt.Sprintf("/iterations/%d/changes", latest)) + fmt.Sprintf("&$compareTo=0&$top=100&$skip=%d", skip)
+		if _, err := a.rest.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return "", fmt.Errorf("failed to get PR changes from Azure DevOps: %w", err)
+		}
+		for _, change := range page.ChangeEntries {
+			fileDiff, err := a.fileDiff(ctx, project, repo, change)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(fileDiff)
+		}
+		if page.NextSkip == 0 {
+			break
+		}
+		skip = page.NextSkip
+	}
+	return sb.String(), nil
+}
+
+// fileDiff downloads both versions of a changed file and diffs them.
+func (a *AzureDevOpsClient) fileDiff(ctx context.Context, project, repo string, change azureDevOpsChange) (string, error) {
+	if change.Item.GitObjectType != "" && change.Item.GitObjectType != "blob" {
+		return "", nil
+	}
+	newPath := strings.TrimPrefix(change.Item.Path, "/")
+	oldPath := newPath
+	if change.OriginalPath != "" {
+		oldPath = strings.TrimPrefix(change.OriginalPath, "/")
+	}
+
+	var oldText, newText string
+	switch {
+	case strings.Contains(change.ChangeType, "add"):
+		oldPath = ""
+	case strings.Contains(change.ChangeType, "delete"):
+		newPath = ""
+	}
+	if oldPath != "" && change.Item.OriginalObjectID != "" {
+		if err := a.getBlob(ctx, project, repo, change.Item.OriginalObjectID, &oldText); err != nil {
+			return "", err
+		}
+	}
+	if newPath != "" && change.Item.ObjectID != "" {
+		if err := a.getBlob(ctx, project, repo, change.Item.ObjectID, &newText); err != nil {
+			return "", err
+		}
+	}
+	return unifiedDiff(oldPath, newPath, oldText, newText), nil
+}
+
+func (a *AzureDevOpsClient) getBlob(ctx context.Context, project, repo, objectID string, out *string) error {
+	path := fmt.Sprintf("/%s/_apis/git/repositories/%s/blobs/%s?$format=octetstream&api-version=%s",
+		url.PathEscape(project), url.PathEscape(repo), objectID, azureDevOpsAPIVersion)
+	if _, err := a.rest.do(ctx, http.MethodGet, path, nil, out); err != nil {
+		return fmt.Errorf("failed to get blob %s from Azure DevOps: %w", objectID, err)
+	}
+	return nil
+}
+
+// GetPRCommitID fetches the SHA of the latest commit on the pull request's source branch.
+func (a *AzureDevOpsClient) GetPRCommitID(ctx context.Context, project, repo string, prID int) (string, error) {
+	var pr azureDevOpsPullRequest
+	if _, err := a.rest.do(ctx, http.MethodGet, a.pullRequestPath(project, repo, prID, ""), nil, &pr); err != nil {
+		return "", fmt.Errorf("failed to get pull request details: %w", err)
+	}
+	if pr.LastMergeSourceCommit.CommitID == "" {
+		return "", fmt.Errorf("could not retrieve HEAD commit SHA for PR #%d", prID)
+	}
+	return pr.LastMergeSourceCommit.CommitID, nil
+}
+
+// GetCompareDiff is not implemented for Azure DevOps, so every review covers the whole pull request.
+func (a *AzureDevOpsClient) GetCompareDiff(ctx context.Context, project, repo, base, head string) (string, error) {
+	return "", fmt.Errorf("compare diffs are not supported for Azure DevOps")
+}
+
+// PostReview creates one thread per comment, positioned on the right (new) side of the file.
+// Azure DevOps has no batch review endpoint, so the first failure aborts the remaining comments.
+func (a *AzureDevOpsClient) PostReview(ctx context.Context, project, repo string, prID int, comments []*Comment, commitID string) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	for _, c := range comments {
+		thread := newAzureDevOpsThread(c.Body)
+		thread.ThreadContext = &azureDevOpsThreadContext{
+			FilePath:       "/" + strings.TrimPrefix(c.Path, "/"),
+			RightFileStart: &azureDevOpsFilePosition{Line: c.Line, Offset: 1},
+			RightFileEnd:   &azureDevOpsFilePosition{Line: c.Line, Offset: 1},
+		}
+		if _, err := a.rest.do(ctx, http.MethodPost, a.pullRequestPath(project, repo, prID, "/threads"), thread, nil); err != nil {
+			return fmt.Errorf("failed to create thread on %s:%d: %w", c.Path, c.Line, err)
+		}
+	}
+
+	log.Printf("Successfully submitted review to Azure DevOps PR #%d", prID)
+	return nil
+}
+
+// PostGeneralComment creates a thread that is not tied to a file.
+func (a *AzureDevOpsClient) PostGeneralComment(ctx context.Context, project, repo string, prID int, body string) error {
+	if _, err := a.rest.do(ctx, http.MethodPost, a.pullRequestPath(project, repo, prID, "/threads"), newAzureDevOpsThread(body), nil); err != nil {
+		return fmt.Errorf("failed to post general comment: %w", err)
+	}
+	return nil
+}
+
+// FindGeneralComment returns the latest thread not tied to a file whose first comment was
+// written by author and contains the marker. The returned ID is the thread ID.
+func (a *AzureDevOpsClient) FindGeneralComment(ctx context.Context, project, repo string, prID int, marker, author string) (*GeneralComment, error) {
+	var threads struct {
+		Value []struct {
+			ID            int64                     `json:"id"`
+			Comments      []azureDevOpsComment      `json:"comments"`
+			ThreadContext *azureDevOpsThreadContext `json:"threadContext"`
+		} `json:"value"`
+	}
+	if _, err := a.rest.do(ctx, http.MethodGet, a.pullRequestPath(project, repo, prID, "/threads"), nil, &threads); err != nil {
+		return nil, fmt.Errorf("failed to list PR threads: %w", err)
+	}
+	var found *GeneralComment
+	for _, t := range threads.Value {
+		if t.ThreadContext == nil && len(t.Comments) > 0 && t.Comments[0].Author != nil &&
+			t.Comments[0].Author.ID == author && strings.Contains(t.Comments[0].Content, marker) {
+			found = &GeneralComment{ID: t.ID, Body: t.Comments[0].Content}
+		}
+	}
+	return found, nil
+}
+
+// EditGeneralComment replaces the first comment of a thread returned by FindGeneralComment.
+func (a *AzureDevOpsClient) EditGeneralComment(ctx context.Context, project, repo string, prID int, threadID int64, body string) error {
+	// The comment that opens a thread always has ID 1.
+	path := a.pullRequestPath(project, repo, prID, fmt.Sprintf("/threads/%d/comments/1", threadID))
+	payload := struct {
+		Content string `json:"content"`
+	}{Content: body}
+	if _, err := a.rest.do(ctx, http.MethodPatch, path, payload, nil); err != nil {
+		return fmt.Errorf("failed to edit general comment: %w", err)
+	}
+	return nil
+}
+
+// ListReviewComments is not implemented for Azure DevOps, so every run posts all of its findings.
+func (a *AzureDevOpsClient) ListReviewComments(ctx context.Context, project, repo string, prID int) ([]*LineComment, error) {
+	return nil, nil
+}
+
+// CurrentUser returns the ID of the identity the PAT belongs to, which comment authors are reported by.
+func (a *AzureDevOpsClient) CurrentUser(ctx context.Context) (string, error) {
+	var data struct {
+		AuthenticatedUser struct {
+			ID string `json:"id"`
+		} `json:"authenticatedUser"`
+	}
+	if _, err := a.rest.do(ctx, http.MethodGet, "/_apis/connectionData?api-version="+azureDevOpsAPIVersion+"-preview", nil, &data); err != nil {
+		return "", fmt.Errorf("failed to get current Azure DevOps user: %w", err)
+	}
+	return data.AuthenticatedUser.ID, nil
+}
+
+// EditReviewComment is not implemented for Azure DevOps.
+func (a *AzureDevOpsClient) EditReviewComment(ctx context.Context, project, repo string, prID int, commentID int64, body string) error {
+	return fmt.Errorf("editing review comments is not supported for Azure DevOps")
+}
+
+// newAzureDevOpsThread creates an active thread holding a single text comment.
+func newAzureDevOpsThread(body string) *azureDevOpsThread {
+	return &azureDevOpsThread{
+		Comments: []azureDevOpsComment{{Content: body, CommentType: 1}}, // 1 = text
+		Status:   1,                                                     // 1 = active
+	}
+}
+
+// pullRequestPath builds the API path for a pull request sub-resource, including the api-version query.
+func (a *AzureDevOpsClient) pullRequestPath(project, repo string, prID int, suffix string) string {
+	return fmt.Sprintf("/%s/_apis/git/repositories/%s/pullRequests/%d%s?api-version=%s",
+		url.PathEscape(project), url.PathEscape(repo), prID, suffix, azureDevOpsAPIVersion)
+}
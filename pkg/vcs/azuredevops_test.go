@@ -0,0 +1,203 @@
+package vcs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const azureDevOpsRepoPath = "/proj/_apis/git/repositories/repo"
+
+// setupAzureDevOpsTestServer creates a mock HTTP server and an AzureDevOpsClient pointed to it.
+func setupAzureDevOpsTestServer(t *testing.T) (*AzureDevOpsClient, *http.ServeMux, *httptest.Server) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	client := NewAzureDevOpsClient(context.Background(), server.URL, "test-pat")
+	client.rest.httpClient = server.Client()
+	return client, mux, server
+}
+
+func TestAzureDevOpsClient_GetPRDiff(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, mux, server := setupAzureDevOpsTestServer(t)
+		defer server.Close()
+
+		expectedAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte(":test-pat"))
+		mux.HandleFunc(azureDevOpsRepoPath+"/pullRequests/4/iterations", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, expectedAuth, r.Header.Get("Authorization"))
+			assert.Equal(t, "7.1", r.URL.Query().Get("api-version"))
+			fmt.Fprint(w, `{"value":[{"id":1},{"id":2}]}`)
+		})
+		mux.HandleFunc(azureDevOpsRepoPath+"/pullRequests/4/iterations/2/changes", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "0", r.URL.Query().Get("$compareTo"))
+			fmt.Fprint(w, `{"changeEntries":[
+				{"changeType":"edit","item":{"path":"/main.go","objectId":"new-main","originalObjectId":"old-main","gitObjectType":"blob"}},
+				{"changeType":"add","item":{"path":"/new.go","objectId":"new-file","gitObjectType":"blob"}}
+			]}`)
+		})
+		blobs := map[string]string{
+			"old-main": "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+			"new-main": "package main\n\nfunc main() {\n\tprintln(\"world\")\n}\n",
+			"new-file": "package main\n",
+		}
+		mux.HandleFunc(azureDevOpsRepoPath+"/blobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, blobs[r.PathValue("id")])
+		})
+
+		diff, err := client.GetPRDiff(context.Background(), "proj", "repo", 4)
+		assert.NoError(t, err)
+		expected := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n" +
+			"@@ -1,5 +1,5 @@\n package main\n \n func main() {\n-\tprintln(\"hello\")\n+\tprintln(\"world\")\n }\n" +
+			"diff --git a/new.go b/new.go\nnew file mode 100644\n--- /dev/null\n+++ b/new.go\n@@ -0,0 +1,1 @@\n+package main\n"
+		assert.Equal(t, expected, diff)
+	})
+
+	t.Run("Failure - Not Found", func(t *testing.T) {
+		client, mux, server := setupAzureDevOpsTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(azureDevOpsRepoPath+"/pullRequests/4/iterations", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+		_, err := client.GetPRDiff(context.Background(), "proj", "repo", 4)
+		assert.Error(t, err)
+	})
+}
+
+func TestAzureDevOpsClient_GetPRCommitID(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, mux, server := setupAzureDevOpsTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(azureDevOpsRepoPath+"/pullRequests/4", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"lastMergeSourceCommit":{"commitId":"abcdef1234567890"}}`)
+		})
+
+		sha, err := client.GetPRCommitID(context.Background(), "proj", "repo", 4)
+		assert.NoError(t, err)
+		assert.Equal(t, "abcdef1234567890", sha)
+	})
+}
+
+func TestAzureDevOpsClient_PostReview(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, mux, server := setupAzureDevOpsTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(azureDevOpsRepoPath+"/pullRequests/4/threads", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+
+			body, _ := io.ReadAll(r.Body)
+			var thread azureDevOpsThread
+			json.Unmarshal(body, &thread)
+
+			assert.Len(t, thread.Comments, 1)
+			assert.Equal(t, "Azure test comment", thread.Comments[0].Content)
+			if assert.NotNil(t, thread.ThreadContext) {
+				assert.Equal(t, "/main.go", thread.ThreadContext.FilePath)
+				assert.Equal(t, 4, thread.ThreadContext.RightFileStart.Line)
+				assert.Equal(t, 4, thread.ThreadContext.RightFileEnd.Line)
+			}
+
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{}`)
+		})
+
+		comments := []*Comment{{Body: "Azure test comment", Path: "main.go", Position: 5, Line: 4}}
+		err := client.PostReview(context.Background(), "proj", "repo", 4, comments, "test-commit-id")
+		assert.NoError(t, err)
+	})
+}
+
+func TestAzureDevOpsClient_PostGeneralComment(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, mux, server := setupAzureDevOpsTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(azureDevOpsRepoPath+"/pullRequests/4/threads", func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			var thread azureDevOpsThread
+			json.Unmarshal(body, &thread)
+			assert.Nil(t, thread.ThreadContext)
+			assert.Equal(t, "Azure summary", thread.Comments[0].Content)
+			fmt.Fprint(w, `{}`)
+		})
+
+		err := client.PostGeneralComment(context.Background(), "proj", "repo", 4, "Azure summary")
+		assert.NoError(t, err)
+	})
+}
+
+func TestAzureDevOpsClient_FindGeneralComment(t *testing.T) {
+	client, mux, server := setupAzureDevOpsTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc(azureDevOpsRepoPath+"/pullRequests/4/threads", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		fmt.Fprint(w, `{"value":[
+			{"id":10,"comments":[{"content":"<!-- marker --> summary","author":{"id":"bot-guid"}}]},
+			{"id":11,"comments":[{"content":"<!-- marker --> inline","author":{"id":"bot-guid"}}],"threadContext":{"filePath":"/a.go"}},
+			{"id":12,"comments":[{"content":"hello","author":{"id":"bot-guid"}}]},
+			{"id":13,"comments":[{"content":"<!-- marker --> forged","author":{"id":"other-guid"}}]}]}`)
+	})
+
+	comment, err := client.FindGeneralComment(context.Background(), "proj", "repo", 4, "<!-- marker -->", "bot-guid")
+	assert.NoError(t, err)
+	assert.Equal(t, &GeneralComment{ID: 10, Body: "<!-- marker --> summary"}, comment)
+}
+
+func TestAzureDevOpsClient_EditGeneralComment(t *testing.T) {
+	client, mux, server := setupAzureDevOpsTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc(azureDevOpsRepoPath+"/pullRequests/4/threads/10/comments/1", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"content":"updated"}`, string(body))
+		fmt.Fprint(w, `{}`)
+	})
+
+	err := client.EditGeneralComment(context.Background(), "proj", "repo", 4, 10, "updated")
+	assert.NoError(t, err)
+}
+
+func TestAzureDevOpsClient_CurrentUser(t *testing.T) {
+	client, mux, server := setupAzureDevOpsTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc("/_apis/connectionData", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "7.1-preview", r.URL.Query().Get("api-version"))
+		fmt.Fprint(w, `{"authenticatedUser":{"id":"bot-guid","providerDisplayName":"Review Bot"}}`)
+	})
+
+	id, err := client.CurrentUser(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "bot-guid", id)
+}
+
+func TestDiffLines_LargeRewriteFallsBackToReplace(t *testing.T) {
+	a := make([]string, 5000)
+	b := make([]string, 5000)
+	for i := range a {
+		a[i] = fmt.Sprintf("old %d", i)
+		b[i] = fmt.Sprintf("new %d", i)
+	}
+	a[0], b[0] = "shared", "shared"
+
+	ops := diffLines(a, b)
+	counts := map[byte]int{}
+	for _, op := range ops {
+		counts[op.kind]++
+	}
+	assert.Equal(t, map[byte]int{' ': 1, '-': 4999, '+': 4999}, counts)
+	assert.Equal(t, lineOp{'-', "old 1"}, ops[1])
+	assert.Equal(t, lineOp{'+', "new 1"}, ops[5000])
+}
@@ -0,0 +1,250 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/surya84/code-reviewer-bot/constants"
+)
+
+// BitbucketClient implements the VCSAdapter for Bitbucket Server / Data Center using the REST API 1.0.
+// The owner is the project key and the repo is the repository slug.
+type BitbucketClient struct {
+	rest *restClient
+}
+
+// bitbucketPullRequest is the subset of the pull request payload used by the client.
+type bitbucketPullRequest struct {
+	FromRef struct {
+		LatestCommit string `json:"latestCommit"`
+	} `json:"fromRef"`
+	ToRef struct {
+		LatestCommit string `json:"latestCommit"`
+	} `json:"toRef"`
+}
+
+// bitbucketAnchor pins a comment to a line of a file in the pull request diff.
+type bitbucketAnchor struct {
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	LineType string `json:"lineType"` // ADDED, REMOVED or CONTEXT.
+	FileType string `json:"fileType"` // FROM or TO.
+	DiffType string `json:"diffType"`
+	FromHash string `json:"fromHash,omitempty"`
+	ToHash   string `json:"toHash,omitempty"`
+}
+
+// bitbucketComment is the request body for the pull request comments endpoint.
+type bitbucketComment struct {
+	Text   string           `json:"text"`
+	Anchor *bitbucketAnchor `json:"anchor,omitempty"`
+}
+
+// bitbucketActivity is an entry in a pull request's activity stream.
+type bitbucketActivity struct {
+	Action        string `json:"action"`
+	CommentAction string `json:"commentAction"`
+	Comment       *struct {
+		ID     int64  `json:"id"`
+		Text   string `json:"text"`
+		Author struct {
+			Name string `json:"name"`
+		} `json:"author"`
+	} `json:"comment"`
+	CommentAnchor *bitbucketAnchor `json:"commentAnchor"`
+}
+
+// NewBitbucketClient creates a new client for interacting with the Bitbucket Server API.
+func NewBitbucketClient(ctx context.Context, baseURL, token string) *BitbucketClient {
+	rest := newRESTClient(constants.BITBUCKET, "Bitbucket", baseURL, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	})
+	return &BitbucketClient{rest: rest}
+}
+
+// GetPRDiff fetches the raw pull request diff from Bitbucket Server.
+func (b *BitbucketClient) GetPRDiff(ctx context.Context, project, repo string, prID int) (string, error) {
+	var diff string
+	if _, err := b.rest.do(ctx, http.MethodGet, b.pullRequestPath(project, repo, prID)+".diff", nil, &diff); err != nil {
+		return "", fmt.Errorf("failed to get PR diff from Bitbucket: %w", err)
+	}
+	return normalizeBitbucketDiff(diff), nil
+}
+
+// GetPRCommitID fetches the SHA of the latest commit on the pull request's source branch.
+func (b *BitbucketClient) GetPRCommitID(ctx context.Context, project, repo string, prID int) (string, error) {
+	pr, err := b.getPullRequest(ctx, project, repo, prID)
+	if err != nil {
+		return "", err
+	}
+	if pr.FromRef.LatestCommit == "" {
+		return "", fmt.Errorf("could not retrieve HEAD commit SHA for PR #%d", prID)
+	}
+	return pr.FromRef.LatestCommit, nil
+}
+
+// GetCompareDiff is not implemented for Bitbucket, so every review covers the whole pull request.
+func (b *BitbucketClient) GetCompareDiff(ctx context.Context, project, repo, base, head string) (string, error) {
+	return "", fmt.Errorf("compare diffs are not supported for Bitbucket")
+}
+
+// PostReview posts each comment as an inline comment anchored to the new version of the file.
+// Bitbucket Server has no batch review endpoint, so the first failure aborts the remaining comments.
+func (b *BitbucketClient) PostReview(ctx context.Context, project, repo string, prID int, comments []*Comment, commitID string) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	pr, err := b.getPullRequest(ctx, project, repo, prID)
+	if err != nil {
+		return err
+	}
+	toHash := pr.FromRef.LatestCommit
+	if commitID != "" {
+		toHash = commitID
+	}
+
+	for _, c := range comments {
+		lineType := "ADDED"
+		if c.OldLine > 0 {
+			lineType = "CONTEXT"
+		}
+		payload := bitbucketComment{
+			Text: c.Body,
+			Anchor: &bitbucketAnchor{
+				Path:     c.Path,
+				Line:     c.Line,
+				LineType: lineType,
+				FileType: "TO",
+				DiffType: "EFFECTIVE",
+				FromHash: pr.ToRef.LatestCommit,
+				ToHash:   toHash,
+			},
+		}
+		if _, err := b.rest.do(ctx, http.MethodPost, b.pullRequestPath(project, repo, prID)+"/comments", payload, nil); err != nil {
+			return fmt.Errorf("failed to create comment on %s:%d: %w", c.Path, c.Line, err)
+		}
+	}
+
+	log.Printf("Successfully submitted review to Bitbucket PR #%d", prID)
+	return nil
+}
+
+// PostGeneralComment posts a comment on the pull request's activity stream.
+func (b *BitbucketClient) PostGeneralComment(ctx context.Context, project, repo string, prID int, body string) error {
+	if _, err := b.rest.do(ctx, http.MethodPost, b.pullRequestPath(project, repo, prID)+"/comments", bitbucketComment{Text: body}, nil); err != nil {
+		return fmt.Errorf("failed to post general comment: %w", err)
+	}
+	return nil
+}
+
+// FindGeneralComment returns the latest comment by author on the pull request, not anchored to
+// a file, that contains the marker.
+func (b *BitbucketClient) FindGeneralComment(ctx context.Context, project, repo string, prID int, marker, author string) (*GeneralComment, error) {
+	// The activity stream is newest first, so the first match is the latest comment.
+	start := 0
+	for {
+		var page struct {
+			Values        []bitbucketActivity `json:"values"`
+			IsLastPage    bool                `json:"isLastPage"`
+			NextPageStart int                 `json:"nextPageStart"`
+		}
+		path := fmt.Sprintf("%s/activities?limit=100&start=%d", b.pullRequestPath(project, repo, prID), start)
+		if _, err := b.rest.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list PR activities: %w", err)
+		}
+		for _, a := range page.Values {
+			if a.Action == "COMMENTED" && a.CommentAction == "ADDED" && a.CommentAnchor == nil &&
+				a.Comment != nil && a.Comment.Author.Name == author && strings.Contains(a.Comment.Text, marker) {
+				return &GeneralComment{ID: a.Comment.ID, Body: a.Comment.Text}, nil
+			}
+		}
+		if page.IsLastPage {
+			return nil, nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+// EditGeneralComment replaces the text of a pull request comment. Bitbucket requires the
+// comment's current version, so the comment is fetched first.
+func (b *BitbucketClient) EditGeneralComment(ctx context.Context, project, repo string, prID int, commentID int64, body string) error {
+	path := fmt.Sprintf("%s/comments/%d", b.pullRequestPath(project, repo, prID), commentID)
+	var current struct {
+		Version int `json:"version"`
+	}
+	if _, err := b.rest.do(ctx, http.MethodGet, path, nil, &current); err != nil {
+		return fmt.Errorf("failed to get comment %d: %w", commentID, err)
+	}
+	payload := struct {
+		Text    string `json:"text"`
+		Version int    `json:"version"`
+	}{Text: body, Version: current.Version}
+	if _, err := b.rest.do(ctx, http.MethodPut, path, payload, nil); err != nil {
+		return fmt.Errorf("failed to edit general comment: %w", err)
+	}
+	return nil
+}
+
+// ListReviewComments is not implemented for Bitbucket, so every run posts all of its findings.
+func (b *BitbucketClient) ListReviewComments(ctx context.Context, project, repo string, prID int) ([]*LineComment, error) {
+	return nil, nil
+}
+
+// CurrentUser returns the name of the user the token belongs to. Bitbucket Server has no
+// endpoint for it, but reports the name in the X-AUSERNAME header of authenticated responses.
+func (b *BitbucketClient) CurrentUser(ctx context.Context) (string, error) {
+	header, err := b.rest.do(ctx, http.MethodGet, "/rest/api/1.0/application-properties", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current Bitbucket user: %w", err)
+	}
+	name := header.Get("X-AUSERNAME")
+	if name == "" {
+		return "", fmt.Errorf("Bitbucket did not report the authenticated user")
+	}
+	return name, nil
+}
+
+// EditReviewComment is not implemented for Bitbucket.
+func (b *BitbucketClient) EditReviewComment(ctx context.Context, project, repo string, prID int, commentID int64, body string) error {
+	return fmt.Errorf("editing review comments is not supported for Bitbucket")
+}
+
+func (b *BitbucketClient) getPullRequest(ctx context.Context, project, repo string, prID int) (*bitbucketPullRequest, error) {
+	var pr bitbucketPullRequest
+	if _, err := b.rest.do(ctx, http.MethodGet, b.pullRequestPath(project, repo, prID), nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get pull request details: %w", err)
+	}
+	return &pr, nil
+}
+
+func (b *BitbucketClient) pullRequestPath(project, repo string, prID int) string {
+	return fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s/pull-requests/%d", url.PathEscape(project), url.PathEscape(repo), prID)
+}
+
+// normalizeBitbucketDiff rewrites the "src://" and "dst://" path prefixes that Bitbucket
+// Server uses in raw diffs to the "a/" and "b/" prefixes produced by git. Only file headers
+// are rewritten, so hunk lines that happen to start with "-- src://" or "++ dst://" are kept.
+func normalizeBitbucketDiff(diff string) string {
+	lines := strings.Split(diff, "\n")
+	inHeader := false
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			inHeader = true
+			line = strings.Replace(line, " src://", " a/", 1)
+			lines[i] = strings.Replace(line, " dst://", " b/", 1)
+		case strings.HasPrefix(line, "@@"):
+			inHeader = false
+		case inHeader && strings.HasPrefix(line, "--- src://"):
+			lines[i] = "--- a/" + strings.TrimPrefix(line, "--- src://")
+		case inHeader && strings.HasPrefix(line, "+++ dst://"):
+			lines[i] = "+++ b/" + strings.TrimPrefix(line, "+++ dst://")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
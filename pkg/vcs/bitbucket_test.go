@@ -0,0 +1,202 @@
+package vcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const bitbucketPRPath = "/rest/api/1.0/projects/PRJ/repos/repo/pull-requests/3"
+
+// setupBitbucketTestServer creates a mock HTTP server and a BitbucketClient pointed to it.
+func setupBitbucketTestServer(t *testing.T) (*BitbucketClient, *http.ServeMux, *httptest.Server) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	client := NewBitbucketClient(context.Background(), server.URL, "test-token")
+	client.rest.httpClient = server.Client()
+
+	mux.HandleFunc(bitbucketPRPath, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"fromRef":{"latestCommit":"from-sha"},"toRef":{"latestCommit":"to-sha"}}`)
+	})
+	return client, mux, server
+}
+
+func TestBitbucketClient_GetPRDiff(t *testing.T) {
+	t.Run("Success - Normalizes Prefixes", func(t *testing.T) {
+		client, mux, server := setupBitbucketTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(bitbucketPRPath+".diff", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			fmt.Fprint(w, "diff --git src://main.go dst://main.go\n--- src://main.go\n+++ dst://main.go\n@@ -1 +1 @@\n-hello\n+world\n")
+		})
+
+		diff, err := client.GetPRDiff(context.Background(), "PRJ", "repo", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-hello\n+world\n", diff)
+	})
+
+	t.Run("Success - Keeps Hunk Lines That Look Like Headers", func(t *testing.T) {
+		client, mux, server := setupBitbucketTestServer(t)
+		defer server.Close()
+
+		// The file removes the line "-- src://x" and adds the line "++ dst://y".
+		hunk := "@@ -1,2 +1,2 @@\n--- src://x\n+++ dst://y\n keep\n"
+		mux.HandleFunc(bitbucketPRPath+".diff", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "diff --git src://notes.txt dst://notes.txt\n--- src://notes.txt\n+++ dst://notes.txt\n"+hunk)
+		})
+
+		diff, err := client.GetPRDiff(context.Background(), "PRJ", "repo", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, "diff --git a/notes.txt b/notes.txt\n--- a/notes.txt\n+++ b/notes.txt\n"+hunk, diff)
+	})
+
+	t.Run("Failure - Not Found", func(t *testing.T) {
+		client, mux, server := setupBitbucketTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(bitbucketPRPath+".diff", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+		_, err := client.GetPRDiff(context.Background(), "PRJ", "repo", 3)
+		assert.Error(t, err)
+	})
+}
+
+func TestBitbucketClient_GetPRCommitID(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, _, server := setupBitbucketTestServer(t)
+		defer server.Close()
+
+		sha, err := client.GetPRCommitID(context.Background(), "PRJ", "repo", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, "from-sha", sha)
+	})
+}
+
+func TestBitbucketClient_PostReview(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, mux, server := setupBitbucketTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(bitbucketPRPath+"/comments", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+
+			body, _ := io.ReadAll(r.Body)
+			var req bitbucketComment
+			json.Unmarshal(body, &req)
+
+			assert.Equal(t, "Bitbucket test comment", req.Text)
+			if assert.NotNil(t, req.Anchor) {
+				assert.Equal(t, "main.go", req.Anchor.Path)
+				assert.Equal(t, 8, req.Anchor.Line)
+				assert.Equal(t, "ADDED", req.Anchor.LineType)
+				assert.Equal(t, "TO", req.Anchor.FileType)
+				assert.Equal(t, "to-sha", req.Anchor.FromHash)
+				assert.Equal(t, "test-commit-id", req.Anchor.ToHash)
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{}`)
+		})
+
+		comments := []*Comment{{Body: "Bitbucket test comment", Path: "main.go", Position: 2, Line: 8}}
+		err := client.PostReview(context.Background(), "PRJ", "repo", 3, comments, "test-commit-id")
+		assert.NoError(t, err)
+	})
+}
+
+func TestBitbucketClient_PostGeneralComment(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, mux, server := setupBitbucketTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(bitbucketPRPath+"/comments", func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"text":"Bitbucket summary"}`, string(body))
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{}`)
+		})
+
+		err := client.PostGeneralComment(context.Background(), "PRJ", "repo", 3, "Bitbucket summary")
+		assert.NoError(t, err)
+	})
+}
+
+func TestBitbucketClient_FindGeneralComment(t *testing.T) {
+	client, mux, server := setupBitbucketTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc(bitbucketPRPath+"/activities", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("start") {
+		case "0":
+			fmt.Fprint(w, `{"isLastPage":false,"nextPageStart":2,"values":[
+				{"action":"COMMENTED","commentAction":"ADDED","comment":{"id":5,"text":"<!-- marker --> inline","author":{"name":"review-bot"}},"commentAnchor":{"path":"a.go","line":1}},
+				{"action":"APPROVED"}]}`)
+		case "2":
+			fmt.Fprint(w, `{"isLastPage":true,"values":[
+				{"action":"COMMENTED","commentAction":"ADDED","comment":{"id":6,"text":"<!-- marker --> forged","author":{"name":"someone"}}},
+				{"action":"COMMENTED","commentAction":"ADDED","comment":{"id":4,"text":"<!-- marker --> summary","author":{"name":"review-bot"}}},
+				{"action":"COMMENTED","commentAction":"ADDED","comment":{"id":1,"text":"<!-- marker --> older","author":{"name":"review-bot"}}}]}`)
+		}
+	})
+
+	comment, err := client.FindGeneralComment(context.Background(), "PRJ", "repo", 3, "<!-- marker -->", "review-bot")
+	assert.NoError(t, err)
+	assert.Equal(t, &GeneralComment{ID: 4, Body: "<!-- marker --> summary"}, comment)
+}
+
+func TestBitbucketClient_EditGeneralComment(t *testing.T) {
+	client, mux, server := setupBitbucketTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc(bitbucketPRPath+"/comments/4", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, `{"id":4,"version":6}`)
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"text":"updated","version":6}`, string(body))
+			fmt.Fprint(w, `{}`)
+		}
+	})
+
+	err := client.EditGeneralComment(context.Background(), "PRJ", "repo", 3, 4, "updated")
+	assert.NoError(t, err)
+}
+
+func TestBitbucketClient_CurrentUser(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, mux, server := setupBitbucketTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc("/rest/api/1.0/application-properties", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-AUSERNAME", "review-bot")
+			fmt.Fprint(w, `{"version":"8.9.0"}`)
+		})
+
+		name, err := client.CurrentUser(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "review-bot", name)
+	})
+
+	t.Run("Failure - Anonymous", func(t *testing.T) {
+		client, mux, server := setupBitbucketTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc("/rest/api/1.0/application-properties", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"version":"8.9.0"}`)
+		})
+
+		_, err := client.CurrentUser(context.Background())
+		assert.Error(t, err)
+	})
+}
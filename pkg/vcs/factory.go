@@ -3,9 +3,11 @@ package vcs
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/surya84/code-reviewer-bot/config"
 	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/pkg/metrics"
 )
 
 // NewVCSClient is a factory function that creates a VCSAdapter based on the
@@ -13,16 +15,69 @@ import (
 func NewVCSClient(ctx context.Context, cfg *config.VCSConfig) (VCSAdapter, error) {
 	switch cfg.Provider {
 	case constants.GITHUB:
+		if cfg.GitHub.AppID != 0 {
+			if cfg.GitHub.InstallationID == 0 {
+				return nil, fmt.Errorf("github app installation_id is not configured")
+			}
+			app, err := NewGitHubAppFromConfig(&cfg.GitHub)
+			if err != nil {
+				return nil, err
+			}
+			return app.Client(ctx, cfg.GitHub.InstallationID)
+		}
 		if cfg.GitHub.Token == "" {
 			return nil, fmt.Errorf("github token is not configured")
 		}
-		return NewGitHubClient(ctx, cfg.GitHub.Token), nil
+		return NewGitHubClientFromConfig(ctx, &cfg.GitHub)
 	case constants.GITEA:
 		if cfg.Gitea.Token == "" {
 			return nil, fmt.Errorf("gitea token is not configured")
 		}
 		return NewGiteaClient(ctx, cfg.Gitea.BaseURL, cfg.Gitea.Token), nil
+	case constants.GITLAB:
+		if cfg.GitLab.Token == "" {
+			return nil, fmt.Errorf("gitlab token is not configured")
+		}
+		return NewGitLabClient(ctx, cfg.GitLab.BaseURL, cfg.GitLab.Token), nil
+	case constants.BITBUCKET:
+		if cfg.Bitbucket.Token == "" {
+			return nil, fmt.Errorf("bitbucket token is not configured")
+		}
+		if cfg.Bitbucket.BaseURL == "" {
+			return nil, fmt.Errorf("bitbucket base_url is not configured")
+		}
+		return NewBitbucketClient(ctx, cfg.Bitbucket.BaseURL, cfg.Bitbucket.Token), nil
+	case constants.AZUREDEVOPS:
+		if cfg.AzureDevOps.Token == "" {
+			return nil, fmt.Errorf("azure devops token is not configured")
+		}
+		if cfg.AzureDevOps.BaseURL == "" {
+			return nil, fmt.Errorf("azure devops base_url is not configured")
+		}
+		return NewAzureDevOpsClient(ctx, cfg.AzureDevOps.BaseURL, cfg.AzureDevOps.Token), nil
 	default:
 		return nil, fmt.Errorf("unsupported VCS provider: %s", cfg.Provider)
 	}
 }
+
+// NewGitHubAppFromConfig creates a GitHubApp from the app ID and private key file in the configuration.
+func NewGitHubAppFromConfig(cfg *config.GitHubConfig) (*GitHubApp, error) {
+	if cfg.PrivateKeyPath == "" {
+		return nil, fmt.Errorf("github app private_key_path is not configured")
+	}
+	key, err := os.ReadFile(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read github app private key '%s': %w", cfg.PrivateKeyPath, err)
+	}
+	app, err := NewGitHubApp(cfg.AppID, key)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := newHTTPTransport(cfg.CABundlePath, cfg.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	app.transport = metrics.Transport(constants.GITHUB, transport)
+	app.baseURL, app.uploadURL = cfg.BaseURL, cfg.UploadURL
+	return app, nil
+}
@@ -3,24 +3,34 @@ package vcs
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/url"
 	"strings"
 
 	"code.gitea.io/sdk/gitea"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/pkg/metrics"
 )
 
 // GiteaClient implements the VCSAdapter for Gitea using the official Go SDK.
 type GiteaClient struct {
 	client *gitea.Client
+	// The API has no endpoint for compare diffs, so they are read from the web UI with these.
+	baseURL    string
+	token      string
+	httpClient *http.Client
 }
 
 // NewGiteaClient creates a new client for interacting with the Gitea API.
 func NewGiteaClient(ctx context.Context, baseURL, token string) *GiteaClient {
-	c, err := gitea.NewClient(baseURL, gitea.SetToken(token))
+	httpClient := &http.Client{Transport: metrics.Transport(constants.GITEA, http.DefaultTransport)}
+	c, err := gitea.NewClient(baseURL, gitea.SetToken(token), gitea.SetHTTPClient(httpClient))
 	if err != nil {
 		log.Fatalf("Failed to create Gitea client: %v", err)
 	}
-	return &GiteaClient{client: c}
+	return &GiteaClient{client: c, baseURL: strings.TrimSuffix(baseURL, "/"), token: token, httpClient: httpClient}
 }
 
 // GetPRDiff fetches a Pull Request's diff from Gitea.
@@ -44,6 +54,44 @@ func (g *GiteaClient) GetPRCommitID(ctx context.Context, owner, repo string, prI
 	return pr.Head.Sha, nil
 }
 
+// GetCompareDiff fetches the diff between two commits after checking that head descends from base.
+func (g *GiteaClient) GetCompareDiff(ctx context.Context, owner, repo, base, head string) (string, error) {
+	// Compared the other way round, the result lists the commits of base that head lacks,
+	// of which there are none when base is an ancestor of head.
+	behind, resp, err := g.client.CompareCommits(owner, repo, head, base)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			// The base commit no longer exists, which happens after a force-push.
+			return "", ErrNotAncestor
+		}
+		return "", fmt.Errorf("Gitea SDK failed to compare commits: %w", err)
+	}
+	if behind.TotalCommits > 0 {
+		return "", ErrNotAncestor
+	}
+
+	diffURL := fmt.Sprintf("%s/%s/%s/compare/%s...%s.diff", g.baseURL,
+		url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(base), url.PathEscape(head))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, diffURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "token "+g.token)
+	diffResp, err := g.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get compare diff from Gitea: %w", err)
+	}
+	defer diffResp.Body.Close()
+	if diffResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get compare diff from Gitea: status %s", diffResp.Status)
+	}
+	diff, err := io.ReadAll(diffResp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read compare diff from Gitea: %w", err)
+	}
+	return string(diff), nil
+}
+
 // PostReview submits a single review to a Gitea pull request with multiple line-specific comments.
 // This is the definitive, robust method.
 func (g *GiteaClient) PostReview(ctx context.Context, owner, repo string, prIndex int, comments []*Comment, commitID string) error {
@@ -99,3 +147,82 @@ func (g *GiteaClient) PostGeneralComment(ctx context.Context, owner, repo string
 	}
 	return nil
 }
+
+// FindGeneralComment returns the latest comment by author in the PR's issue thread that contains the marker.
+func (g *GiteaClient) FindGeneralComment(ctx context.Context, owner, repo string, prIndex int, marker, author string) (*GeneralComment, error) {
+	var found *GeneralComment
+	opts := gitea.ListIssueCommentOptions{ListOptions: gitea.ListOptions{Page: 1, PageSize: 50}}
+	for {
+		comments, _, err := g.client.ListIssueComments(owner, repo, int64(prIndex), opts)
+		if err != nil {
+			return nil, fmt.Errorf("Gitea SDK failed to list PR comments: %w", err)
+		}
+		for _, c := range comments {
+			if c.Poster != nil && c.Poster.UserName == author && strings.Contains(c.Body, marker) {
+				found = &GeneralComment{ID: c.ID, Body: c.Body}
+			}
+		}
+		if len(comments) < opts.PageSize {
+			return found, nil
+		}
+		opts.Page++
+	}
+}
+
+// EditGeneralComment replaces the body of a comment in the PR's issue thread.
+func (g *GiteaClient) EditGeneralComment(ctx context.Context, owner, repo string, prIndex int, commentID int64, body string) error {
+	if _, _, err := g.client.EditIssueComment(owner, repo, commentID, gitea.EditIssueCommentOption{Body: body}); err != nil {
+		return fmt.Errorf("Gitea SDK failed to edit general comment: %w", err)
+	}
+	return nil
+}
+
+// ListReviewComments returns the line comments of every review on the pull request.
+func (g *GiteaClient) ListReviewComments(ctx context.Context, owner, repo string, prIndex int) ([]*LineComment, error) {
+	var result []*LineComment
+	opts := gitea.ListPullReviewsOptions{ListOptions: gitea.ListOptions{Page: 1, PageSize: 50}}
+	for {
+		reviews, _, err := g.client.ListPullReviews(owner, repo, int64(prIndex), opts)
+		if err != nil {
+			return nil, fmt.Errorf("Gitea SDK failed to list PR reviews: %w", err)
+		}
+		for _, review := range reviews {
+			if review.CodeCommentsCount == 0 {
+				continue
+			}
+			comments, _, err := g.client.ListPullReviewComments(owner, repo, int64(prIndex), review.ID)
+			if err != nil {
+				return nil, fmt.Errorf("Gitea SDK failed to list comments of review %d: %w", review.ID, err)
+			}
+			for _, c := range comments {
+				comment := &LineComment{ID: c.ID, Path: c.Path, Line: int(c.LineNum), Body: c.Body}
+				if c.Reviewer != nil {
+					comment.Author = c.Reviewer.UserName
+				}
+				result = append(result, comment)
+			}
+		}
+		if len(reviews) < opts.PageSize {
+			return result, nil
+		}
+		opts.Page++
+	}
+}
+
+// EditReviewComment replaces the body of a review comment. Gitea stores review comments
+// alongside issue comments, so the issue comment endpoint edits them too.
+func (g *GiteaClient) EditReviewComment(ctx context.Context, owner, repo string, prIndex int, commentID int64, body string) error {
+	if _, _, err := g.client.EditIssueComment(owner, repo, commentID, gitea.EditIssueCommentOption{Body: body}); err != nil {
+		return fmt.Errorf("Gitea SDK failed to edit review comment: %w", err)
+	}
+	return nil
+}
+
+// CurrentUser returns the login of the user the token belongs to.
+func (g *GiteaClient) CurrentUser(ctx context.Context) (string, error) {
+	user, _, err := g.client.GetMyUserInfo()
+	if err != nil {
+		return "", fmt.Errorf("Gitea SDK failed to get current user: %w", err)
+	}
+	return user.UserName, nil
+}
@@ -21,13 +21,13 @@ func setupGiteaTestServer(t *testing.T) (*GiteaClient, *http.ServeMux, *httptest
 
 	// Default handler for the version check the SDK always performs.
 	mux.HandleFunc("/api/v1/version", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, `{"version":"1.21.0"}`)
+		fmt.Fprint(w, `{"version":"1.22.0"}`)
 	})
 
 	client, err := gitea.NewClient(server.URL, gitea.SetToken("test-token"))
 	assert.NoError(t, err)
 
-	return &GiteaClient{client: client}, mux, server
+	return &GiteaClient{client: client, baseURL: server.URL, token: "test-token", httpClient: server.Client()}, mux, server
 }
 
 func TestGiteaClient_GetPRDiff(t *testing.T) {
@@ -61,6 +61,49 @@ func TestGiteaClient_GetPRDiff(t *testing.T) {
 	})
 }
 
+func TestGiteaClient_GetCompareDiff(t *testing.T) {
+	setup := func(t *testing.T, behind int) (*GiteaClient, *httptest.Server) {
+		client, mux, server := setupGiteaTestServer(t)
+		mux.HandleFunc("/api/v1/repos/owner/repo/compare/head...base", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"total_commits":%d,"commits":[]}`, behind)
+		})
+		mux.HandleFunc("/owner/repo/compare/base...head.diff", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "token test-token", r.Header.Get("Authorization"))
+			fmt.Fprint(w, "diff --git a/a.go b/a.go\n")
+		})
+		return client, server
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		client, server := setup(t, 0)
+		defer server.Close()
+
+		diff, err := client.GetCompareDiff(context.Background(), "owner", "repo", "base", "head")
+		assert.NoError(t, err)
+		assert.Equal(t, "diff --git a/a.go b/a.go\n", diff)
+	})
+
+	t.Run("Failure - Diverged", func(t *testing.T) {
+		client, server := setup(t, 2)
+		defer server.Close()
+
+		_, err := client.GetCompareDiff(context.Background(), "owner", "repo", "base", "head")
+		assert.ErrorIs(t, err, ErrNotAncestor)
+	})
+
+	t.Run("Failure - Base Commit Gone", func(t *testing.T) {
+		client, mux, server := setupGiteaTestServer(t)
+		defer server.Close()
+		mux.HandleFunc("/api/v1/repos/owner/repo/compare/head...base", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"Not Found"}`)
+		})
+
+		_, err := client.GetCompareDiff(context.Background(), "owner", "repo", "base", "head")
+		assert.ErrorIs(t, err, ErrNotAncestor)
+	})
+}
+
 func TestGiteaClient_GetPRCommitID(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		client, mux, server := setupGiteaTestServer(t)
@@ -123,3 +166,79 @@ func TestGiteaClient_PostGeneralComment(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestGiteaClient_FindGeneralComment(t *testing.T) {
+	client, mux, server := setupGiteaTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc("/api/v1/repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		fmt.Fprint(w, `[{"id":1,"body":"<!-- marker --> old","user":{"login":"review-bot"}},{"id":2,"body":"<!-- marker --> new","user":{"login":"review-bot"}},{"id":3,"body":"hello","user":{"login":"review-bot"}},{"id":4,"body":"<!-- marker --> forged","user":{"login":"someone"}}]`)
+	})
+
+	comment, err := client.FindGeneralComment(context.Background(), "owner", "repo", 1, "<!-- marker -->", "review-bot")
+	assert.NoError(t, err)
+	assert.Equal(t, &GeneralComment{ID: 2, Body: "<!-- marker --> new"}, comment)
+}
+
+func TestGiteaClient_EditGeneralComment(t *testing.T) {
+	client, mux, server := setupGiteaTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc("/api/v1/repos/owner/repo/issues/comments/2", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"body":"updated"}`, string(body))
+		fmt.Fprint(w, `{"id":2}`)
+	})
+
+	err := client.EditGeneralComment(context.Background(), "owner", "repo", 1, 2, "updated")
+	assert.NoError(t, err)
+}
+
+func TestGiteaClient_ListReviewComments(t *testing.T) {
+	client, mux, server := setupGiteaTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc("/api/v1/repos/owner/repo/pulls/1/reviews", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id":7,"comments_count":1},{"id":8,"comments_count":0}]`)
+	})
+	mux.HandleFunc("/api/v1/repos/owner/repo/pulls/1/reviews/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id":9,"path":"a.go","position":12,"body":"finding","user":{"login":"review-bot"}}]`)
+	})
+	mux.HandleFunc("/api/v1/repos/owner/repo/pulls/1/reviews/8/comments", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("reviews without code comments should not be fetched")
+	})
+
+	comments, err := client.ListReviewComments(context.Background(), "owner", "repo", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []*LineComment{{ID: 9, Path: "a.go", Line: 12, Body: "finding", Author: "review-bot"}}, comments)
+}
+
+func TestGiteaClient_EditReviewComment(t *testing.T) {
+	client, mux, server := setupGiteaTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc("/api/v1/repos/owner/repo/issues/comments/9", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"body":"resolved"}`, string(body))
+		fmt.Fprint(w, `{"id":9}`)
+	})
+
+	err := client.EditReviewComment(context.Background(), "owner", "repo", 1, 9, "resolved")
+	assert.NoError(t, err)
+}
+
+func TestGiteaClient_CurrentUser(t *testing.T) {
+	client, mux, server := setupGiteaTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":3,"login":"review-bot"}`)
+	})
+
+	login, err := client.CurrentUser(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "review-bot", login)
+}
@@ -3,23 +3,63 @@ package vcs
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/google/go-github/v62/github"
+	"github.com/surya84/code-reviewer-bot/config"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/pkg/metrics"
 	"golang.org/x/oauth2"
 )
 
 // GitHubClient is an implementation of the VCSAdapter for GitHub.
 type GitHubClient struct {
 	client *github.Client
+	// login resolves the account the client posts as. When nil, the authenticated user is looked up.
+	login func(ctx context.Context) (string, error)
 }
 
 // NewGitHubClient creates a new client for interacting with the GitHub API.
 func NewGitHubClient(ctx context.Context, token string) *GitHubClient {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: metrics.Transport(constants.GITHUB, http.DefaultTransport)})
 	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
 	tc := oauth2.NewClient(ctx, ts)
 	return &GitHubClient{client: github.NewClient(tc)}
 }
 
+// NewGitHubClientFromConfig creates a token-authenticated client that honours the
+// GitHub Enterprise Server URLs, CA bundle and proxy settings in the configuration.
+func NewGitHubClientFromConfig(ctx context.Context, cfg *config.GitHubConfig) (*GitHubClient, error) {
+	transport, err := newHTTPTransport(cfg.CABundlePath, cfg.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: metrics.Transport(constants.GITHUB, transport)})
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.Token})
+	client, err := withGitHubURLs(github.NewClient(oauth2.NewClient(ctx, ts)), cfg.BaseURL, cfg.UploadURL)
+	if err != nil {
+		return nil, err
+	}
+	return &GitHubClient{client: client}, nil
+}
+
+// withGitHubURLs points the client at a GitHub Enterprise Server instance when baseURL is set.
+// The upload URL defaults to the base URL, which go-github rewrites to the uploads endpoint.
+func withGitHubURLs(client *github.Client, baseURL, uploadURL string) (*github.Client, error) {
+	if baseURL == "" {
+		return client, nil
+	}
+	if uploadURL == "" {
+		uploadURL = baseURL
+	}
+	client, err := client.WithEnterpriseURLs(baseURL, uploadURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub Enterprise URLs: %w", err)
+	}
+	return client, nil
+}
+
 // GetPRDiff fetches the pull request diff from GitHub.
 func (g *GitHubClient) GetPRDiff(ctx context.Context, owner, repo string, prNumber int) (string, error) {
 
@@ -45,6 +85,26 @@ func (g *GitHubClient) GetPRCommitID(ctx context.Context, owner, repo string, pr
 	return *pr.Head.SHA, nil
 }
 
+// GetCompareDiff fetches the diff between two commits after checking that head descends from base.
+func (g *GitHubClient) GetCompareDiff(ctx context.Context, owner, repo, base, head string) (string, error) {
+	comparison, resp, err := g.client.Repositories.CompareCommits(ctx, owner, repo, base, head, &github.ListOptions{PerPage: 1})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			// The base commit no longer exists, which happens after a force-push.
+			return "", ErrNotAncestor
+		}
+		return "", fmt.Errorf("failed to compare commits on GitHub: %w", err)
+	}
+	if status := comparison.GetStatus(); status != "ahead" && status != "identical" {
+		return "", ErrNotAncestor
+	}
+	diff, _, err := g.client.Repositories.CompareCommitsRaw(ctx, owner, repo, base, head, github.RawOptions{Type: github.Diff})
+	if err != nil {
+		return "", fmt.Errorf("failed to get compare diff from GitHub: %w", err)
+	}
+	return diff, nil
+}
+
 // PostReview submits a single review to a pull request with multiple line-specific comments.
 func (g *GitHubClient) PostReview(ctx context.Context, owner, repo string, prNumber int, comments []*Comment, commitID string) error {
 	if len(comments) == 0 {
@@ -85,3 +145,76 @@ func (g *GitHubClient) PostGeneralComment(ctx context.Context, owner, repo strin
 	}
 	return nil
 }
+
+// FindGeneralComment returns the latest issue comment on the PR by author that contains the marker.
+func (g *GitHubClient) FindGeneralComment(ctx context.Context, owner, repo string, prNumber int, marker, author string) (*GeneralComment, error) {
+	var found *GeneralComment
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := g.client.Issues.ListComments(ctx, owner, repo, prNumber, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list PR comments: %w", err)
+		}
+		for _, c := range comments {
+			if c.GetUser().GetLogin() == author && strings.Contains(c.GetBody(), marker) {
+				found = &GeneralComment{ID: c.GetID(), Body: c.GetBody()}
+			}
+		}
+		if resp.NextPage == 0 {
+			return found, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// EditGeneralComment replaces the body of an issue comment on the PR.
+func (g *GitHubClient) EditGeneralComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) error {
+	if _, _, err := g.client.Issues.EditComment(ctx, owner, repo, commentID, &github.IssueComment{Body: &body}); err != nil {
+		return fmt.Errorf("failed to edit general comment: %w", err)
+	}
+	return nil
+}
+
+// ListReviewComments returns every line comment on the pull request.
+func (g *GitHubClient) ListReviewComments(ctx context.Context, owner, repo string, prNumber int) ([]*LineComment, error) {
+	var result []*LineComment
+	opts := &github.PullRequestListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := g.client.PullRequests.ListComments(ctx, owner, repo, prNumber, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list review comments: %w", err)
+		}
+		for _, c := range comments {
+			line := c.GetLine()
+			if line == 0 {
+				// Outdated comments no longer have a line in the current diff.
+				line = c.GetOriginalLine()
+			}
+			result = append(result, &LineComment{ID: c.GetID(), Path: c.GetPath(), Line: line, Body: c.GetBody(), Author: c.GetUser().GetLogin()})
+		}
+		if resp.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// EditReviewComment replaces the body of a line comment on the pull request.
+func (g *GitHubClient) EditReviewComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) error {
+	if _, _, err := g.client.PullRequests.EditComment(ctx, owner, repo, commentID, &github.PullRequestComment{Body: &body}); err != nil {
+		return fmt.Errorf("failed to edit review comment: %w", err)
+	}
+	return nil
+}
+
+// CurrentUser returns the login of the authenticated user, or the bot login of a GitHub App.
+func (g *GitHubClient) CurrentUser(ctx context.Context) (string, error) {
+	if g.login != nil {
+		return g.login(ctx)
+	}
+	user, _, err := g.client.Users.Get(ctx, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to get authenticated GitHub user: %w", err)
+	}
+	return user.GetLogin(), nil
+}
@@ -3,14 +3,20 @@ package vcs
 import (
 	"context"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-github/v62/github"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/surya84/code-reviewer-bot/config"
 )
 
 // setupGitHubTestServer creates a mock HTTP server and a GitHubClient pointed to it.
@@ -126,3 +132,211 @@ func TestGitHubClient_PostGeneralComment(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestNewGitHubClientFromConfig(t *testing.T) {
+	prHandler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/repos/owner/repo/pulls/1", r.URL.Path)
+		assert.Equal(t, "Bearer ghes-token", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"head":{"sha":"abcdef"}}`)
+	}
+
+	t.Run("Success - Enterprise URL With CA Bundle", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(prHandler))
+		defer server.Close()
+
+		caPath := filepath.Join(t.TempDir(), "ca.pem")
+		caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		require.NoError(t, os.WriteFile(caPath, caPEM, 0o600))
+
+		client, err := NewGitHubClientFromConfig(context.Background(), &config.GitHubConfig{
+			Token:        "ghes-token",
+			BaseURL:      server.URL,
+			CABundlePath: caPath,
+		})
+		require.NoError(t, err)
+
+		sha, err := client.GetPRCommitID(context.Background(), "owner", "repo", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "abcdef", sha)
+	})
+
+	t.Run("Failure - Untrusted Certificate", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(prHandler))
+		defer server.Close()
+
+		client, err := NewGitHubClientFromConfig(context.Background(), &config.GitHubConfig{
+			Token:   "ghes-token",
+			BaseURL: server.URL,
+		})
+		require.NoError(t, err)
+
+		_, err = client.GetPRCommitID(context.Background(), "owner", "repo", 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("Success - Through Proxy", func(t *testing.T) {
+		var proxied bool
+		// A plain HTTP proxy receives the absolute target URL in the request line.
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = true
+			assert.Equal(t, "ghes.example.com", r.URL.Host)
+			prHandler(w, r)
+		}))
+		defer proxy.Close()
+
+		client, err := NewGitHubClientFromConfig(context.Background(), &config.GitHubConfig{
+			Token:    "ghes-token",
+			BaseURL:  "http://ghes.example.com/",
+			ProxyURL: proxy.URL,
+		})
+		require.NoError(t, err)
+
+		sha, err := client.GetPRCommitID(context.Background(), "owner", "repo", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "abcdef", sha)
+		assert.True(t, proxied)
+	})
+
+	t.Run("Failure - Invalid CA Bundle", func(t *testing.T) {
+		caPath := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(caPath, []byte("not a certificate"), 0o600))
+
+		_, err := NewGitHubClientFromConfig(context.Background(), &config.GitHubConfig{CABundlePath: caPath})
+		assert.Error(t, err)
+	})
+}
+
+func TestGitHubClient_FindGeneralComment(t *testing.T) {
+	t.Run("Success - Latest Match Across Pages", func(t *testing.T) {
+		var serverURL string
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v3/repos/owner/repo/issues/1/comments", r.URL.Path)
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprint(w, `[{"id":3,"body":"<!-- marker --> new","user":{"login":"review-bot"}},{"id":4,"body":"unrelated","user":{"login":"review-bot"}},{"id":5,"body":"<!-- marker --> forged","user":{"login":"someone"}}]`)
+				return
+			}
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/repos/owner/repo/issues/1/comments?page=2>; rel="next"`, serverURL))
+			fmt.Fprint(w, `[{"id":1,"body":"<!-- marker --> old","user":{"login":"review-bot"}},{"id":2,"body":"hello","user":{"login":"someone"}}]`)
+		}
+		client, server := setupGitHubTestServer(t, handler)
+		defer server.Close()
+		serverURL = server.URL
+
+		comment, err := client.FindGeneralComment(context.Background(), "owner", "repo", 1, "<!-- marker -->", "review-bot")
+		assert.NoError(t, err)
+		assert.Equal(t, &GeneralComment{ID: 3, Body: "<!-- marker --> new"}, comment)
+	})
+
+	t.Run("Success - No Match By The Bot", func(t *testing.T) {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `[{"id":1,"body":"hello","user":{"login":"review-bot"}},{"id":2,"body":"<!-- marker --> forged","user":{"login":"someone"}}]`)
+		}
+		client, server := setupGitHubTestServer(t, handler)
+		defer server.Close()
+
+		comment, err := client.FindGeneralComment(context.Background(), "owner", "repo", 1, "<!-- marker -->", "review-bot")
+		assert.NoError(t, err)
+		assert.Nil(t, comment)
+	})
+}
+
+func TestGitHubClient_EditGeneralComment(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "/api/v3/repos/owner/repo/issues/comments/3", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"body":"updated"}`, string(body))
+		fmt.Fprint(w, `{"id":3}`)
+	}
+	client, server := setupGitHubTestServer(t, handler)
+	defer server.Close()
+
+	err := client.EditGeneralComment(context.Background(), "owner", "repo", 1, 3, "updated")
+	assert.NoError(t, err)
+}
+
+func TestGitHubClient_ListReviewComments(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/repos/owner/repo/pulls/1/comments", r.URL.Path)
+		fmt.Fprint(w, `[{"id":5,"path":"a.go","line":12,"body":"current","user":{"login":"review-bot"}},{"id":6,"path":"b.go","original_line":3,"body":"outdated","user":{"login":"someone"}}]`)
+	}
+	client, server := setupGitHubTestServer(t, handler)
+	defer server.Close()
+
+	comments, err := client.ListReviewComments(context.Background(), "owner", "repo", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []*LineComment{
+		{ID: 5, Path: "a.go", Line: 12, Body: "current", Author: "review-bot"},
+		{ID: 6, Path: "b.go", Line: 3, Body: "outdated", Author: "someone"},
+	}, comments)
+}
+
+func TestGitHubClient_CurrentUser(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/user", r.URL.Path)
+		fmt.Fprint(w, `{"login":"review-bot"}`)
+	}
+	client, server := setupGitHubTestServer(t, handler)
+	defer server.Close()
+
+	login, err := client.CurrentUser(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "review-bot", login)
+}
+
+func TestGitHubClient_EditReviewComment(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "/api/v3/repos/owner/repo/pulls/comments/5", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"body":"resolved"}`, string(body))
+		fmt.Fprint(w, `{"id":5}`)
+	}
+	client, server := setupGitHubTestServer(t, handler)
+	defer server.Close()
+
+	err := client.EditReviewComment(context.Background(), "owner", "repo", 1, 5, "resolved")
+	assert.NoError(t, err)
+}
+
+func TestGitHubClient_GetCompareDiff(t *testing.T) {
+	compareHandler := func(status string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v3/repos/owner/repo/compare/base...head", r.URL.Path)
+			if strings.Contains(r.Header.Get("Accept"), "diff") {
+				fmt.Fprint(w, "diff --git a/a.go b/a.go\n")
+				return
+			}
+			fmt.Fprintf(w, `{"status":%q}`, status)
+		}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		client, server := setupGitHubTestServer(t, compareHandler("ahead"))
+		defer server.Close()
+
+		diff, err := client.GetCompareDiff(context.Background(), "owner", "repo", "base", "head")
+		assert.NoError(t, err)
+		assert.Equal(t, "diff --git a/a.go b/a.go\n", diff)
+	})
+
+	t.Run("Failure - Diverged", func(t *testing.T) {
+		client, server := setupGitHubTestServer(t, compareHandler("diverged"))
+		defer server.Close()
+
+		_, err := client.GetCompareDiff(context.Background(), "owner", "repo", "base", "head")
+		assert.ErrorIs(t, err, ErrNotAncestor)
+	})
+
+	t.Run("Failure - Base Commit Gone", func(t *testing.T) {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"Not Found"}`)
+		}
+		client, server := setupGitHubTestServer(t, handler)
+		defer server.Close()
+
+		_, err := client.GetCompareDiff(context.Background(), "owner", "repo", "base", "head")
+		assert.ErrorIs(t, err, ErrNotAncestor)
+	})
+}
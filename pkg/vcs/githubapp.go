@@ -0,0 +1,216 @@
+package vcs
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/surya84/code-reviewer-bot/constants"
+	"github.com/surya84/code-reviewer-bot/pkg/metrics"
+	"golang.org/x/oauth2"
+)
+
+// installationTokenLeeway is how long before expiry a cached installation token is refreshed.
+const installationTokenLeeway = time.Minute
+
+// GitHubApp authenticates as a GitHub App and mints installation access tokens.
+// Tokens are cached per installation and shared by every client created from the app,
+// so one instance should be reused for the lifetime of the process.
+type GitHubApp struct {
+	appID      int64
+	privateKey *rsa.PrivateKey
+	baseURL    string
+	uploadURL  string
+	transport  http.RoundTripper
+
+	mu     sync.Mutex
+	tokens map[int64]*oauth2.Token
+	slug   string // The app's URL name, looked up on first use.
+}
+
+// NewGitHubApp creates a GitHub App authenticator from the app ID and its PEM-encoded private key.
+func NewGitHubApp(appID int64, privateKeyPEM []byte) (*GitHubApp, error) {
+	key, err := parseRSAPrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+	return &GitHubApp{
+		appID:      appID,
+		privateKey: key,
+		transport:  metrics.Transport(constants.GITHUB, http.DefaultTransport),
+		tokens:     make(map[int64]*oauth2.Token),
+	}, nil
+}
+
+// Client returns a GitHubClient that authenticates as the given installation.
+func (a *GitHubApp) Client(ctx context.Context, installationID int64) (*GitHubClient, error) {
+	client, err := a.newClient(&http.Client{Transport: &installationTransport{app: a, installationID: installationID}})
+	if err != nil {
+		return nil, err
+	}
+	return &GitHubClient{client: client, login: a.botLogin}, nil
+}
+
+// botLogin returns the login that the app's comments are posted under, "<slug>[bot]".
+func (a *GitHubApp) botLogin(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	slug := a.slug
+	a.mu.Unlock()
+	if slug == "" {
+		apps, err := a.appsService()
+		if err != nil {
+			return "", err
+		}
+		app, _, err := apps.Get(ctx, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to get GitHub App: %w", err)
+		}
+		slug = app.GetSlug()
+		a.mu.Lock()
+		a.slug = slug
+		a.mu.Unlock()
+	}
+	return slug + "[bot]", nil
+}
+
+// FindInstallation looks up the ID of the app installation that covers a repository.
+func (a *GitHubApp) FindInstallation(ctx context.Context, owner, repo string) (int64, error) {
+	apps, err := a.appsService()
+	if err != nil {
+		return 0, err
+	}
+	inst, _, err := apps.FindRepositoryInstallation(ctx, owner, repo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find GitHub App installation for %s/%s: %w", owner, repo, err)
+	}
+	return inst.GetID(), nil
+}
+
+// installationToken returns a cached installation token, exchanging a fresh JWT when it is missing or about to expire.
+// The lock is not held during the exchange, so a slow or cancelled exchange does not hold up
+// requests for other installations.
+func (a *GitHubApp) installationToken(ctx context.Context, installationID int64) (*oauth2.Token, error) {
+	a.mu.Lock()
+	tok, ok := a.tokens[installationID]
+	a.mu.Unlock()
+	if ok && time.Until(tok.Expiry) > installationTokenLeeway {
+		return tok, nil
+	}
+
+	apps, err := a.appsService()
+	if err != nil {
+		return nil, err
+	}
+	it, _, err := apps.CreateInstallationToken(ctx, installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create installation token for installation %d: %w", installationID, err)
+	}
+	tok = &oauth2.Token{AccessToken: it.GetToken(), Expiry: it.GetExpiresAt().Time}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// A concurrent exchange may have stored a token that lives longer.
+	if cached, ok := a.tokens[installationID]; !ok || cached.Expiry.Before(tok.Expiry) {
+		a.tokens[installationID] = tok
+	}
+	return tok, nil
+}
+
+// signJWT creates the short-lived RS256 JWT that authenticates as the app itself.
+func (a *GitHubApp) signJWT(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		// Backdate issuance to allow for clock drift; GitHub rejects expiries beyond 10 minutes.
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// appsService returns the Apps API authenticated with the app's JWT.
+func (a *GitHubApp) appsService() (*github.AppsService, error) {
+	client, err := a.newClient(&http.Client{Transport: &appJWTTransport{app: a}})
+	if err != nil {
+		return nil, err
+	}
+	return client.Apps, nil
+}
+
+func (a *GitHubApp) newClient(httpClient *http.Client) (*github.Client, error) {
+	return withGitHubURLs(github.NewClient(httpClient), a.baseURL, a.uploadURL)
+}
+
+// appJWTTransport authenticates app-level API requests with a freshly signed JWT.
+type appJWTTransport struct {
+	app *GitHubApp
+}
+
+func (t *appJWTTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := t.app.signJWT(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	return t.app.transport.RoundTrip(req)
+}
+
+// installationTransport authenticates requests as an installation, fetching the token with the
+// request's context so that cancelling a review also cancels its token exchange.
+type installationTransport struct {
+	app            *GitHubApp
+	installationID int64
+}
+
+func (t *installationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tok, err := t.app.installationToken(req.Context(), t.installationID)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	return t.app.transport.RoundTrip(req)
+}
+
+// parseRSAPrivateKey decodes a PKCS#1 or PKCS#8 PEM-encoded RSA private key.
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+	return key, nil
+}
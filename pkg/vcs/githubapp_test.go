@@ -0,0 +1,191 @@
+package vcs
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// setupGitHubAppTestServer creates a GitHubApp with a fresh key pointed at a mock server.
+func setupGitHubAppTestServer(t *testing.T) (*GitHubApp, *rsa.PrivateKey, *http.ServeMux, *httptest.Server) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	app, err := NewGitHubApp(42, keyPEM)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	app.baseURL = server.URL
+	return app, key, mux, server
+}
+
+// verifyAppJWT checks the bearer JWT's RS256 signature and issuer.
+func verifyAppJWT(t *testing.T, key *rsa.PrivateKey, authHeader string) {
+	jwt := strings.TrimPrefix(authHeader, "Bearer ")
+	parts := strings.Split(jwt, ".")
+	require.Len(t, parts, 3)
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig))
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims struct {
+		Iss string `json:"iss"`
+		Exp int64  `json:"exp"`
+	}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	assert.Equal(t, "42", claims.Iss)
+	assert.LessOrEqual(t, claims.Exp, time.Now().Add(10*time.Minute).Unix())
+}
+
+func TestGitHubApp_Client(t *testing.T) {
+	t.Run("Success - Token Cached Across Clients", func(t *testing.T) {
+		app, key, mux, server := setupGitHubAppTestServer(t)
+		defer server.Close()
+
+		var exchanges int
+		mux.HandleFunc("/api/v3/app/installations/7/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			verifyAppJWT(t, key, r.Header.Get("Authorization"))
+			exchanges++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token":"inst-token","expires_at":"%s"}`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		})
+		mux.HandleFunc("/api/v3/repos/owner/repo/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer inst-token", r.Header.Get("Authorization"))
+			fmt.Fprint(w, `{"head":{"sha":"abcdef"}}`)
+		})
+
+		for i := 0; i < 2; i++ {
+			client, err := app.Client(context.Background(), 7)
+			require.NoError(t, err)
+			sha, err := client.GetPRCommitID(context.Background(), "owner", "repo", 1)
+			assert.NoError(t, err)
+			assert.Equal(t, "abcdef", sha)
+		}
+		assert.Equal(t, 1, exchanges)
+	})
+
+	t.Run("Success - Expired Token Refreshed", func(t *testing.T) {
+		app, _, mux, server := setupGitHubAppTestServer(t)
+		defer server.Close()
+
+		var exchanges int
+		mux.HandleFunc("/api/v3/app/installations/7/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+			exchanges++
+			w.WriteHeader(http.StatusCreated)
+			// Expires inside the refresh leeway, so every request needs a new token.
+			fmt.Fprintf(w, `{"token":"inst-token","expires_at":"%s"}`, time.Now().Add(30*time.Second).UTC().Format(time.RFC3339))
+		})
+
+		for i := 0; i < 2; i++ {
+			_, err := app.installationToken(context.Background(), 7)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, exchanges)
+	})
+
+	t.Run("Failure - Cancelled Request Cancels Exchange", func(t *testing.T) {
+		app, _, mux, server := setupGitHubAppTestServer(t)
+		defer server.Close()
+		release := make(chan struct{})
+		defer close(release)
+
+		// The exchange hangs until the test ends, long after the client gave up on it.
+		mux.HandleFunc("/api/v3/app/installations/7/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		})
+
+		client, err := app.Client(context.Background(), 7)
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err = client.GetPRCommitID(ctx, "owner", "repo", 1)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Success - Cached Token Served During Another Exchange", func(t *testing.T) {
+		app, _, mux, server := setupGitHubAppTestServer(t)
+		defer server.Close()
+
+		exchanging := make(chan struct{})
+		release := make(chan struct{})
+		mux.HandleFunc("/api/v3/app/installations/7/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+			close(exchanging)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token":"inst-7","expires_at":"%s"}`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		})
+		app.tokens[8] = &oauth2.Token{AccessToken: "inst-8", Expiry: time.Now().Add(time.Hour)}
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := app.installationToken(context.Background(), 7)
+			done <- err
+		}()
+		<-exchanging
+
+		tok, err := app.installationToken(context.Background(), 8)
+		require.NoError(t, err)
+		assert.Equal(t, "inst-8", tok.AccessToken)
+
+		close(release)
+		assert.NoError(t, <-done)
+	})
+}
+
+func TestGitHubApp_FindInstallation(t *testing.T) {
+	app, key, mux, server := setupGitHubAppTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc("/api/v3/repos/owner/repo/installation", func(w http.ResponseWriter, r *http.Request) {
+		verifyAppJWT(t, key, r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"id":99}`)
+	})
+
+	id, err := app.FindInstallation(context.Background(), "owner", "repo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(99), id)
+}
+
+func TestGitHubApp_ClientCurrentUser(t *testing.T) {
+	app, key, mux, server := setupGitHubAppTestServer(t)
+	defer server.Close()
+
+	var lookups int
+	mux.HandleFunc("/api/v3/app", func(w http.ResponseWriter, r *http.Request) {
+		verifyAppJWT(t, key, r.Header.Get("Authorization"))
+		lookups++
+		fmt.Fprint(w, `{"slug":"code-reviewer"}`)
+	})
+
+	for i := 0; i < 2; i++ {
+		client, err := app.Client(context.Background(), 7)
+		require.NoError(t, err)
+		login, err := client.CurrentUser(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "code-reviewer[bot]", login)
+	}
+	assert.Equal(t, 1, lookups, "the app's slug is cached")
+}
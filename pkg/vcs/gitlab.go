@@ -0,0 +1,317 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/surya84/code-reviewer-bot/constants"
+)
+
+// GitLabClient implements the VCSAdapter for GitLab merge requests using the REST API v4.
+type GitLabClient struct {
+	rest *restClient
+}
+
+// gitLabDiffRefs holds the SHAs GitLab needs to anchor a discussion on a diff.
+type gitLabDiffRefs struct {
+	BaseSHA  string `json:"base_sha"`
+	HeadSHA  string `json:"head_sha"`
+	StartSHA string `json:"start_sha"`
+}
+
+// gitLabMergeRequest is the subset of the merge request payload used by the client.
+type gitLabMergeRequest struct {
+	SHA      string          `json:"sha"`
+	DiffRefs *gitLabDiffRefs `json:"diff_refs"`
+}
+
+// gitLabDiff is a single file entry returned by the merge request diffs endpoint.
+type gitLabDiff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	Diff        string `json:"diff"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+	AMode       string `json:"a_mode"` // The file's mode before the change, e.g. "100644"; "0" for new files.
+	BMode       string `json:"b_mode"` // The file's mode after the change; "0" for deleted files.
+	// TooLarge and Collapsed report that GitLab left Diff empty because the file's diff was too big.
+	TooLarge  bool `json:"too_large"`
+	Collapsed bool `json:"collapsed"`
+}
+
+// gitLabPosition is the position object used to anchor an inline discussion.
+type gitLabPosition struct {
+	PositionType string `json:"position_type"`
+	BaseSHA      string `json:"base_sha"`
+	StartSHA     string `json:"start_sha"`
+	HeadSHA      string `json:"head_sha"`
+	OldPath      string `json:"old_path"`
+	NewPath      string `json:"new_path"`
+	NewLine      int    `json:"new_line,omitempty"`
+	OldLine      int    `json:"old_line,omitempty"`
+}
+
+// gitLabNote is a comment on a merge request.
+type gitLabNote struct {
+	ID     int64  `json:"id"`
+	Body   string `json:"body"`
+	System bool   `json:"system"`
+	Author struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+// NewGitLabClient creates a new client for interacting with the GitLab API.
+func NewGitLabClient(ctx context.Context, baseURL, token string) *GitLabClient {
+	if baseURL == "" {
+		baseURL = "https://gitlab.com"
+	}
+	rest := newRESTClient(constants.GITLAB, "GitLab", baseURL, func(req *http.Request) {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	})
+	return &GitLabClient{rest: rest}
+}
+
+// GetPRDiff fetches a merge request's changes and assembles them into a unified diff.
+func (g *GitLabClient) GetPRDiff(ctx context.Context, owner, repo string, mrIID int) (string, error) {
+	var sb strings.Builder
+	page := "1"
+	for page != "" {
+		var diffs []gitLabDiff
+		path := fmt.Sprintf("%s/diffs?per_page=100&page=%s", g.mergeRequestPath(owner, repo, mrIID), page)
+		header, err := g.rest.do(ctx, http.MethodGet, path, nil, &diffs)
+		if err != nil {
+			return "", fmt.Errorf("failed to get MR diff from GitLab: %w", err)
+		}
+		for _, d := range diffs {
+			writeGitLabFileDiff(&sb, d)
+		}
+		page = header.Get("X-Next-Page")
+	}
+	return sb.String(), nil
+}
+
+// GetPRCommitID fetches the SHA of the HEAD commit of a merge request.
+func (g *GitLabClient) GetPRCommitID(ctx context.Context, owner, repo string, mrIID int) (string, error) {
+	mr, err := g.getMergeRequest(ctx, owner, repo, mrIID)
+	if err != nil {
+		return "", err
+	}
+	if mr.DiffRefs != nil && mr.DiffRefs.HeadSHA != "" {
+		return mr.DiffRefs.HeadSHA, nil
+	}
+	if mr.SHA == "" {
+		return "", fmt.Errorf("could not retrieve HEAD commit SHA for MR !%d", mrIID)
+	}
+	return mr.SHA, nil
+}
+
+// GetCompareDiff fetches the diff between two commits after checking, through their merge
+// base, that head descends from base.
+func (g *GitLabClient) GetCompareDiff(ctx context.Context, owner, repo, base, head string) (string, error) {
+	var mergeBase struct {
+		ID string `json:"id"`
+	}
+	query := url.Values{"refs[]": {base, head}}
+	if _, err := g.rest.do(ctx, http.MethodGet, g.projectPath(owner, repo)+"/repository/merge_base?"+query.Encode(), nil, &mergeBase); err != nil {
+		return "", fmt.Errorf("failed to get merge base from GitLab: %w", err)
+	}
+	if mergeBase.ID != base {
+		return "", ErrNotAncestor
+	}
+
+	var compare struct {
+		Diffs []gitLabDiff `json:"diffs"`
+	}
+	query = url.Values{"from": {base}, "to": {head}, "straight": {"true"}}
+	if _, err := g.rest.do(ctx, http.MethodGet, g.projectPath(owner, repo)+"/repository/compare?"+query.Encode(), nil, &compare); err != nil {
+		return "", fmt.Errorf("failed to get compare diff from GitLab: %w", err)
+	}
+	var sb strings.Builder
+	for _, d := range compare.Diffs {
+		writeGitLabFileDiff(&sb, d)
+	}
+	return sb.String(), nil
+}
+
+// PostReview creates one inline discussion per comment, anchored with GitLab's position object.
+// GitLab has no batch review endpoint, so the first failure aborts the remaining comments.
+func (g *GitLabClient) PostReview(ctx context.Context, owner, repo string, mrIID int, comments []*Comment, commitID string) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	mr, err := g.getMergeRequest(ctx, owner, repo, mrIID)
+	if err != nil {
+		return err
+	}
+	if mr.DiffRefs == nil {
+		return fmt.Errorf("GitLab MR !%d has no diff refs", mrIID)
+	}
+	refs := *mr.DiffRefs
+	if commitID != "" {
+		refs.HeadSHA = commitID
+	}
+
+	for _, c := range comments {
+		oldPath := c.OldPath
+		if oldPath == "" {
+			oldPath = c.Path
+		}
+		payload := struct {
+			Body     string         `json:"body"`
+			Position gitLabPosition `json:"position"`
+		}{
+			Body: c.Body,
+			Position: gitLabPosition{
+				PositionType: "text",
+				BaseSHA:      refs.BaseSHA,
+				StartSHA:     refs.StartSHA,
+				HeadSHA:      refs.HeadSHA,
+				OldPath:      oldPath,
+				NewPath:      c.Path,
+				NewLine:      c.Line,
+				OldLine:      c.OldLine,
+			},
+		}
+		if _, err := g.rest.do(ctx, http.MethodPost, g.mergeRequestPath(owner, repo, mrIID)+"/discussions", payload, nil); err != nil {
+			return fmt.Errorf("failed to create discussion on %s:%d: %w", c.Path, c.Line, err)
+		}
+	}
+	return nil
+}
+
+// PostGeneralComment posts a note on the merge request (not tied to a specific line).
+func (g *GitLabClient) PostGeneralComment(ctx context.Context, owner, repo string, mrIID int, body string) error {
+	payload := struct {
+		Body string `json:"body"`
+	}{Body: body}
+	if _, err := g.rest.do(ctx, http.MethodPost, g.mergeRequestPath(owner, repo, mrIID)+"/notes", payload, nil); err != nil {
+		return fmt.Errorf("failed to post general comment: %w", err)
+	}
+	return nil
+}
+
+// FindGeneralComment returns the latest user note by author on the merge request that contains the marker.
+func (g *GitLabClient) FindGeneralComment(ctx context.Context, owner, repo string, mrIID int, marker, author string) (*GeneralComment, error) {
+	var found *GeneralComment
+	page := "1"
+	for page != "" {
+		var notes []gitLabNote
+		path := fmt.Sprintf("%s/notes?sort=asc&order_by=created_at&per_page=100&page=%s", g.mergeRequestPath(owner, repo, mrIID), page)
+		header, err := g.rest.do(ctx, http.MethodGet, path, nil, &notes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list MR notes: %w", err)
+		}
+		for _, n := range notes {
+			if !n.System && n.Author.Username == author && strings.Contains(n.Body, marker) {
+				found = &GeneralComment{ID: n.ID, Body: n.Body}
+			}
+		}
+		page = header.Get("X-Next-Page")
+	}
+	return found, nil
+}
+
+// EditGeneralComment replaces the body of a note on the merge request.
+func (g *GitLabClient) EditGeneralComment(ctx context.Context, owner, repo string, mrIID int, noteID int64, body string) error {
+	payload := struct {
+		Body string `json:"body"`
+	}{Body: body}
+	path := fmt.Sprintf("%s/notes/%d", g.mergeRequestPath(owner, repo, mrIID), noteID)
+	if _, err := g.rest.do(ctx, http.MethodPut, path, payload, nil); err != nil {
+		return fmt.Errorf("failed to edit general comment: %w", err)
+	}
+	return nil
+}
+
+// ListReviewComments is not implemented for GitLab, so every run posts all of its findings.
+func (g *GitLabClient) ListReviewComments(ctx context.Context, owner, repo string, mrIID int) ([]*LineComment, error) {
+	return nil, nil
+}
+
+// CurrentUser returns the username of the user the token belongs to.
+func (g *GitLabClient) CurrentUser(ctx context.Context) (string, error) {
+	var user struct {
+		Username string `json:"username"`
+	}
+	if _, err := g.rest.do(ctx, http.MethodGet, "/api/v4/user", nil, &user); err != nil {
+		return "", fmt.Errorf("failed to get current GitLab user: %w", err)
+	}
+	return user.Username, nil
+}
+
+// EditReviewComment is not implemented for GitLab.
+func (g *GitLabClient) EditReviewComment(ctx context.Context, owner, repo string, mrIID int, commentID int64, body string) error {
+	return fmt.Errorf("editing review comments is not supported for GitLab")
+}
+
+func (g *GitLabClient) getMergeRequest(ctx context.Context, owner, repo string, mrIID int) (*gitLabMergeRequest, error) {
+	var mr gitLabMergeRequest
+	if _, err := g.rest.do(ctx, http.MethodGet, g.mergeRequestPath(owner, repo, mrIID), nil, &mr); err != nil {
+		return nil, fmt.Errorf("failed to get merge request details: %w", err)
+	}
+	return &mr, nil
+}
+
+// mergeRequestPath builds the API path for a merge request. The owner may contain
+// subgroups, so the full project path is URL-encoded as a single ID.
+func (g *GitLabClient) mergeRequestPath(owner, repo string, mrIID int) string {
+	return fmt.Sprintf("%s/merge_requests/%d", g.projectPath(owner, repo), mrIID)
+}
+
+func (g *GitLabClient) projectPath(owner, repo string) string {
+	return "/api/v4/projects/" + url.PathEscape(owner+"/"+repo)
+}
+
+// writeGitLabFileDiff writes a git-style file header followed by GitLab's hunk text,
+// which the diffs endpoint returns without the "diff --git" preamble. Files whose diff GitLab
+// withheld for its size are left out, since an empty diff would read as a mode-only change.
+func writeGitLabFileDiff(sb *strings.Builder, d gitLabDiff) {
+	if d.TooLarge || d.Collapsed {
+		log.Printf("Skipping %s: GitLab did not return its diff because it is too large.", d.NewPath)
+		return
+	}
+	oldPath, newPath := "a/"+d.OldPath, "b/"+d.NewPath
+	sb.WriteString(fmt.Sprintf("diff --git %s %s\n", oldPath, newPath))
+	switch {
+	case d.NewFile:
+		sb.WriteString("new file mode " + gitLabMode(d.BMode) + "\n")
+		oldPath = "/dev/null"
+	case d.DeletedFile:
+		sb.WriteString("deleted file mode " + gitLabMode(d.AMode) + "\n")
+		newPath = "/dev/null"
+	default:
+		if d.AMode != "" && d.BMode != "" && d.AMode != d.BMode {
+			sb.WriteString("old mode " + d.AMode + "\n")
+			sb.WriteString("new mode " + d.BMode + "\n")
+		}
+		if d.RenamedFile {
+			sb.WriteString("rename from " + d.OldPath + "\n")
+			sb.WriteString("rename to " + d.NewPath + "\n")
+		}
+	}
+	if d.Diff == "" {
+		return
+	}
+	sb.WriteString("--- " + oldPath + "\n")
+	sb.WriteString("+++ " + newPath + "\n")
+	sb.WriteString(d.Diff)
+	if !strings.HasSuffix(d.Diff, "\n") {
+		sb.WriteString("\n")
+	}
+}
+
+// gitLabMode returns a mode reported by GitLab, or the regular file mode when older GitLab
+// versions leave it out.
+func gitLabMode(mode string) string {
+	if mode == "" || mode == "0" {
+		return "100644"
+	}
+	return mode
+}
@@ -0,0 +1,286 @@
+package vcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const gitLabMRPath = "/api/v4/projects/group%2Fsub%2Frepo/merge_requests/7"
+
+// setupGitLabTestServer creates a mock HTTP server and a GitLabClient pointed to it.
+func setupGitLabTestServer(t *testing.T) (*GitLabClient, *http.ServeMux, *httptest.Server) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	client := NewGitLabClient(context.Background(), server.URL+"/", "test-token")
+	client.rest.httpClient = server.Client()
+	return client, mux, server
+}
+
+// handleGitLabMR registers a handler for the merge request details endpoint.
+func handleGitLabMR(t *testing.T, mux *http.ServeMux) {
+	mux.HandleFunc(gitLabMRPath, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-token", r.Header.Get("PRIVATE-TOKEN"))
+		fmt.Fprint(w, `{"sha":"head-sha","diff_refs":{"base_sha":"base-sha","head_sha":"head-sha","start_sha":"start-sha"}}`)
+	})
+}
+
+func TestGitLabClient_GetPRDiff(t *testing.T) {
+	t.Run("Success - Paginated", func(t *testing.T) {
+		client, mux, server := setupGitLabTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(gitLabMRPath+"/diffs", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			// The project path must arrive URL-encoded as a single segment.
+			assert.Equal(t, gitLabMRPath+"/diffs", r.URL.EscapedPath())
+			switch r.URL.Query().Get("page") {
+			case "1":
+				w.Header().Set("X-Next-Page", "2")
+				fmt.Fprint(w, `[{"old_path":"main.go","new_path":"main.go","diff":"@@ -1,1 +1,1 @@\n-hello\n+world\n"}]`)
+			case "2":
+				fmt.Fprint(w, `[{"old_path":"new.go","new_path":"new.go","new_file":true,"diff":"@@ -0,0 +1 @@\n+package new"}]`)
+			default:
+				t.Errorf("unexpected page %q", r.URL.Query().Get("page"))
+			}
+		})
+
+		diff, err := client.GetPRDiff(context.Background(), "group/sub", "repo", 7)
+		assert.NoError(t, err)
+		expected := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1,1 +1,1 @@\n-hello\n+world\n" +
+			"diff --git a/new.go b/new.go\nnew file mode 100644\n--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+package new\n"
+		assert.Equal(t, expected, diff)
+	})
+
+	t.Run("Success - File Modes And Withheld Diffs", func(t *testing.T) {
+		client, mux, server := setupGitLabTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(gitLabMRPath+"/diffs", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `[
+				{"old_path":"run.sh","new_path":"run.sh","new_file":true,"a_mode":"0","b_mode":"100755","diff":"@@ -0,0 +1 @@\n+echo hi\n"},
+				{"old_path":"build.sh","new_path":"build.sh","a_mode":"100644","b_mode":"100755","diff":""},
+				{"old_path":"link","new_path":"link","deleted_file":true,"a_mode":"120000","b_mode":"0","diff":"@@ -1 +0,0 @@\n-target\n"},
+				{"old_path":"big.json","new_path":"big.json","a_mode":"100644","b_mode":"100644","too_large":true,"diff":""},
+				{"old_path":"gen.go","new_path":"gen.go","a_mode":"100644","b_mode":"100644","collapsed":true,"diff":""}
+			]`)
+		})
+
+		diff, err := client.GetPRDiff(context.Background(), "group/sub", "repo", 7)
+		assert.NoError(t, err)
+		expected := "diff --git a/run.sh b/run.sh\nnew file mode 100755\n--- /dev/null\n+++ b/run.sh\n@@ -0,0 +1 @@\n+echo hi\n" +
+			"diff --git a/build.sh b/build.sh\nold mode 100644\nnew mode 100755\n" +
+			"diff --git a/link b/link\ndeleted file mode 120000\n--- a/link\n+++ /dev/null\n@@ -1 +0,0 @@\n-target\n"
+		assert.Equal(t, expected, diff)
+	})
+
+	t.Run("Failure - Not Found", func(t *testing.T) {
+		client, mux, server := setupGitLabTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(gitLabMRPath+"/diffs", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+		_, err := client.GetPRDiff(context.Background(), "group/sub", "repo", 7)
+		assert.Error(t, err)
+	})
+}
+
+func TestGitLabClient_GetPRCommitID(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, mux, server := setupGitLabTestServer(t)
+		defer server.Close()
+		handleGitLabMR(t, mux)
+
+		sha, err := client.GetPRCommitID(context.Background(), "group/sub", "repo", 7)
+		assert.NoError(t, err)
+		assert.Equal(t, "head-sha", sha)
+	})
+}
+
+func TestGitLabClient_PostReview(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, mux, server := setupGitLabTestServer(t)
+		defer server.Close()
+		handleGitLabMR(t, mux)
+
+		var calls int
+		mux.HandleFunc(gitLabMRPath+"/discussions", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			calls++
+
+			body, _ := io.ReadAll(r.Body)
+			var req struct {
+				Body     string         `json:"body"`
+				Position gitLabPosition `json:"position"`
+			}
+			json.Unmarshal(body, &req)
+
+			assert.Equal(t, "GitLab test comment", req.Body)
+			assert.Equal(t, "text", req.Position.PositionType)
+			assert.Equal(t, "base-sha", req.Position.BaseSHA)
+			assert.Equal(t, "start-sha", req.Position.StartSHA)
+			assert.Equal(t, "test-commit-id", req.Position.HeadSHA)
+			assert.Equal(t, "main.go", req.Position.OldPath)
+			assert.Equal(t, "main.go", req.Position.NewPath)
+			assert.Equal(t, 12, req.Position.NewLine)
+			assert.Zero(t, req.Position.OldLine)
+
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{}`)
+		})
+
+		comments := []*Comment{{Body: "GitLab test comment", Path: "main.go", Position: 3, Line: 12}}
+		err := client.PostReview(context.Background(), "group/sub", "repo", 7, comments, "test-commit-id")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Success - Renamed File", func(t *testing.T) {
+		client, mux, server := setupGitLabTestServer(t)
+		defer server.Close()
+		handleGitLabMR(t, mux)
+
+		mux.HandleFunc(gitLabMRPath+"/discussions", func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			var req struct {
+				Position gitLabPosition `json:"position"`
+			}
+			json.Unmarshal(body, &req)
+
+			assert.Equal(t, "old/name.go", req.Position.OldPath)
+			assert.Equal(t, "new/name.go", req.Position.NewPath)
+			assert.Equal(t, 4, req.Position.NewLine)
+
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{}`)
+		})
+
+		comments := []*Comment{{Body: "Renamed file comment", Path: "new/name.go", OldPath: "old/name.go", Line: 4}}
+		err := client.PostReview(context.Background(), "group/sub", "repo", 7, comments, "test-commit-id")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Failure - API Error", func(t *testing.T) {
+		client, mux, server := setupGitLabTestServer(t)
+		defer server.Close()
+		handleGitLabMR(t, mux)
+
+		mux.HandleFunc(gitLabMRPath+"/discussions", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message":"line_code can't be blank"}`)
+		})
+
+		comments := []*Comment{{Body: "This comment will fail", Path: "main.go", Line: 12}}
+		err := client.PostReview(context.Background(), "group/sub", "repo", 7, comments, "test-commit-id")
+		assert.Error(t, err)
+	})
+}
+
+func TestGitLabClient_PostGeneralComment(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, mux, server := setupGitLabTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc(gitLabMRPath+"/notes", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"body":"GitLab summary"}`, string(body))
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{}`)
+		})
+
+		err := client.PostGeneralComment(context.Background(), "group/sub", "repo", 7, "GitLab summary")
+		assert.NoError(t, err)
+	})
+}
+
+func TestGitLabClient_FindGeneralComment(t *testing.T) {
+	client, mux, server := setupGitLabTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc(gitLabMRPath+"/notes", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("X-Next-Page", "2")
+			fmt.Fprint(w, `[{"id":1,"body":"<!-- marker --> old","author":{"username":"review-bot"}}]`)
+		case "2":
+			fmt.Fprint(w, `[{"id":2,"body":"<!-- marker --> new","author":{"username":"review-bot"}},{"id":3,"body":"<!-- marker --> mentioned","system":true,"author":{"username":"review-bot"}},{"id":4,"body":"<!-- marker --> forged","author":{"username":"someone"}}]`)
+		}
+	})
+
+	comment, err := client.FindGeneralComment(context.Background(), "group/sub", "repo", 7, "<!-- marker -->", "review-bot")
+	assert.NoError(t, err)
+	assert.Equal(t, &GeneralComment{ID: 2, Body: "<!-- marker --> new"}, comment)
+}
+
+func TestGitLabClient_EditGeneralComment(t *testing.T) {
+	client, mux, server := setupGitLabTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc(gitLabMRPath+"/notes/2", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"body":"updated"}`, string(body))
+		fmt.Fprint(w, `{}`)
+	})
+
+	err := client.EditGeneralComment(context.Background(), "group/sub", "repo", 7, 2, "updated")
+	assert.NoError(t, err)
+}
+
+func TestGitLabClient_GetCompareDiff(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		client, mux, server := setupGitLabTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc("/api/v4/projects/group%2Fproject/repository/merge_base", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, []string{"base", "head"}, r.URL.Query()["refs[]"])
+			fmt.Fprint(w, `{"id":"base"}`)
+		})
+		mux.HandleFunc("/api/v4/projects/group%2Fproject/repository/compare", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "base", r.URL.Query().Get("from"))
+			assert.Equal(t, "head", r.URL.Query().Get("to"))
+			fmt.Fprint(w, `{"diffs":[{"old_path":"a.go","new_path":"a.go","diff":"@@ -1 +1 @@\n-old\n+new\n"}]}`)
+		})
+
+		diff, err := client.GetCompareDiff(context.Background(), "group", "project", "base", "head")
+		assert.NoError(t, err)
+		assert.Contains(t, diff, "diff --git a/a.go b/a.go\n")
+		assert.Contains(t, diff, "+new\n")
+	})
+
+	t.Run("Failure - Not An Ancestor", func(t *testing.T) {
+		client, mux, server := setupGitLabTestServer(t)
+		defer server.Close()
+
+		mux.HandleFunc("/api/v4/projects/group%2Fproject/repository/merge_base", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"id":"other"}`)
+		})
+
+		_, err := client.GetCompareDiff(context.Background(), "group", "project", "base", "head")
+		assert.ErrorIs(t, err, ErrNotAncestor)
+	})
+}
+
+func TestGitLabClient_CurrentUser(t *testing.T) {
+	client, mux, server := setupGitLabTestServer(t)
+	defer server.Close()
+
+	mux.HandleFunc("/api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-token", r.Header.Get("PRIVATE-TOKEN"))
+		fmt.Fprint(w, `{"id":3,"username":"review-bot"}`)
+	})
+
+	username, err := client.CurrentUser(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "review-bot", username)
+}
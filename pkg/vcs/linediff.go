@@ -0,0 +1,224 @@
+package vcs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContextLines is the number of unchanged lines kept around each change, matching git's default.
+const diffContextLines = 3
+
+// maxTraceCells bounds the memory the Myers search may use for its backtracking trace
+// (one int per cell, so roughly 32MB). Past it, the changed region is emitted as a
+// plain delete-all/add-all edit instead of a minimal one.
+const maxTraceCells = 1 << 22
+
+// lineOp is a single line of an edit script: ' ' (unchanged), '-' (removed) or '+' (added).
+type lineOp struct {
+	kind byte
+	text string
+}
+
+// unifiedDiff renders a git-style unified diff between two versions of a file,
+// for forges that only expose file contents rather than a patch.
+func unifiedDiff(oldPath, newPath, oldText, newText string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n", diffPathOrDefault(oldPath, newPath), diffPathOrDefault(newPath, oldPath)))
+
+	fromLabel, toLabel := "a/"+oldPath, "b/"+newPath
+	if oldPath == "" {
+		sb.WriteString("new file mode 100644\n")
+		fromLabel = "/dev/null"
+	}
+	if newPath == "" {
+		sb.WriteString("deleted file mode 100644\n")
+		toLabel = "/dev/null"
+	}
+
+	if strings.ContainsRune(oldText, 0) || strings.ContainsRune(newText, 0) {
+		sb.WriteString(fmt.Sprintf("Binary files %s and %s differ\n", fromLabel, toLabel))
+		return sb.String()
+	}
+
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+	hunks := formatHunks(ops)
+	if hunks == "" {
+		return sb.String()
+	}
+	sb.WriteString("--- " + fromLabel + "\n")
+	sb.WriteString("+++ " + toLabel + "\n")
+	sb.WriteString(hunks)
+	return sb.String()
+}
+
+func diffPathOrDefault(path, fallback string) string {
+	if path == "" {
+		return fallback
+	}
+	return path
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes a shortest edit script from a to b using Myers' algorithm.
+func diffLines(a, b []string) []lineOp {
+	// Strip the common prefix and suffix so the search only covers the changed region.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []lineOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, lineOp{' ', line})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, lineOp{' ', line})
+	}
+	return ops
+}
+
+func myers(a, b []string) []lineOp {
+	n, m := len(a), len(b)
+	total := n + m
+	if total == 0 {
+		return nil
+	}
+	offset := total + 1
+	width := 2*total + 3
+	v := make([]int, width)
+	var trace [][]int
+
+search:
+	for d := 0; d <= total; d++ {
+		if (d+1)*width > maxTraceCells {
+			return replaceAll(a, b)
+		}
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the edit script, then reverse it.
+	var ops []lineOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, lineOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, lineOp{'+', b[y-1]})
+			} else {
+				ops = append(ops, lineOp{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// replaceAll is the fallback edit script for regions too large to diff precisely:
+// every old line is removed and every new line added.
+func replaceAll(a, b []string) []lineOp {
+	ops := make([]lineOp, 0, len(a)+len(b))
+	for _, line := range a {
+		ops = append(ops, lineOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, lineOp{'+', line})
+	}
+	return ops
+}
+
+// formatHunks groups an edit script into "@@" hunks with surrounding context.
+func formatHunks(ops []lineOp) string {
+	var sb strings.Builder
+	// oldAt[i] and newAt[i] hold the number of old/new lines consumed before ops[i].
+	oldAt := make([]int, len(ops)+1)
+	newAt := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if op.kind != '+' {
+			oldAt[i+1]++
+		}
+		if op.kind != '-' {
+			newAt[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := max(i-diffContextLines, 0)
+		end := i
+		// Extend the hunk while the next change is close enough to share context.
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*diffContextLines {
+				break
+			}
+		}
+		end = min(end+diffContextLines, len(ops)-1)
+
+		oldStart, oldCount := oldAt[start]+1, oldAt[end+1]-oldAt[start]
+		newStart, newCount := newAt[start]+1, newAt[end+1]-newAt[start]
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount))
+		for _, op := range ops[start : end+1] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+		i = end + 1
+	}
+	return sb.String()
+}
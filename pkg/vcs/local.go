@@ -0,0 +1,125 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// LocalGitAdapter implements the VCSAdapter against a local git working directory.
+// It reviews the changes between a base ref and HEAD and prints the review to a
+// writer instead of posting it to a forge, so no token or network access is needed.
+type LocalGitAdapter struct {
+	dir  string
+	base string
+	out  io.Writer
+}
+
+// NewLocalGitAdapter creates an adapter for the repository in dir that diffs base...HEAD
+// and writes the review to out.
+func NewLocalGitAdapter(dir, base string, out io.Writer) *LocalGitAdapter {
+	return &LocalGitAdapter{dir: dir, base: base, out: out}
+}
+
+// GetPRDiff returns the diff of HEAD against its merge base with the configured base ref.
+// The owner, repo and PR number are ignored.
+func (l *LocalGitAdapter) GetPRDiff(ctx context.Context, owner, repo string, prNumber int) (string, error) {
+	// A base starting with "-" would be read by git as an option.
+	if strings.HasPrefix(l.base, "-") {
+		return "", fmt.Errorf("invalid base ref '%s'", l.base)
+	}
+	if _, err := l.git(ctx, "rev-parse", "--verify", "--quiet", l.base+"^{commit}"); err != nil {
+		return "", fmt.Errorf("base ref '%s' does not name a commit: %w", l.base, err)
+	}
+	diff, err := l.git(ctx, "diff", "--no-color", "--no-ext-diff", l.base+"...HEAD", "--")
+	if err != nil {
+		return "", fmt.Errorf("failed to get local diff against %s: %w", l.base, err)
+	}
+	return diff, nil
+}
+
+// GetPRCommitID returns the SHA of the local HEAD commit.
+func (l *LocalGitAdapter) GetPRCommitID(ctx context.Context, owner, repo string, prNumber int) (string, error) {
+	sha, err := l.git(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve local HEAD: %w", err)
+	}
+	return strings.TrimSpace(sha), nil
+}
+
+// GetCompareDiff diffs two local commits after checking that head descends from base.
+func (l *LocalGitAdapter) GetCompareDiff(ctx context.Context, owner, repo, base, head string) (string, error) {
+	if _, err := l.git(ctx, "merge-base", "--is-ancestor", base, head); err != nil {
+		return "", ErrNotAncestor
+	}
+	diff, err := l.git(ctx, "diff", "--no-color", "--no-ext-diff", base, head)
+	if err != nil {
+		return "", fmt.Errorf("failed to diff %s..%s: %w", base, head, err)
+	}
+	return diff, nil
+}
+
+// PostReview prints each comment with its file and line.
+func (l *LocalGitAdapter) PostReview(ctx context.Context, owner, repo string, prNumber int, comments []*Comment, commitID string) error {
+	for _, c := range comments {
+		if _, err := fmt.Fprintf(l.out, "%s:%d\n%s\n\n", c.Path, c.Line, indent(c.Body, "    ")); err != nil {
+			return fmt.Errorf("failed to write review comment: %w", err)
+		}
+	}
+	return nil
+}
+
+// PostGeneralComment prints a comment that is not tied to a line.
+func (l *LocalGitAdapter) PostGeneralComment(ctx context.Context, owner, repo string, prNumber int, body string) error {
+	if _, err := fmt.Fprintf(l.out, "%s\n\n", body); err != nil {
+		return fmt.Errorf("failed to write general comment: %w", err)
+	}
+	return nil
+}
+
+// FindGeneralComment always reports no comment, since local output is not persisted.
+func (l *LocalGitAdapter) FindGeneralComment(ctx context.Context, owner, repo string, prNumber int, marker, author string) (*GeneralComment, error) {
+	return nil, nil
+}
+
+// EditGeneralComment prints the new body, like PostGeneralComment.
+func (l *LocalGitAdapter) EditGeneralComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) error {
+	return l.PostGeneralComment(ctx, owner, repo, prNumber, body)
+}
+
+// ListReviewComments always reports no comments, since local output is not persisted.
+func (l *LocalGitAdapter) ListReviewComments(ctx context.Context, owner, repo string, prNumber int) ([]*LineComment, error) {
+	return nil, nil
+}
+
+// EditReviewComment prints the new body under the comment ID.
+func (l *LocalGitAdapter) EditReviewComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) error {
+	if _, err := fmt.Fprintf(l.out, "comment %d\n%s\n\n", commentID, indent(body, "    ")); err != nil {
+		return fmt.Errorf("failed to write review comment: %w", err)
+	}
+	return nil
+}
+
+// CurrentUser reports no user, since local output has no authors.
+func (l *LocalGitAdapter) CurrentUser(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+func (l *LocalGitAdapter) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", l.dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// indent prefixes every line of s with prefix.
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
@@ -0,0 +1,105 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupLocalGitRepo creates a repository with a "main" branch and a feature branch
+// that modifies main.go, and returns its directory.
+func setupLocalGitRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	run("init", "-q", "-b", "main")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644))
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+	run("checkout", "-q", "-b", "feature")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644))
+	run("commit", "-q", "-am", "add main")
+	return dir
+}
+
+func TestLocalGitAdapter_GetPRDiff(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		dir := setupLocalGitRepo(t)
+		adapter := NewLocalGitAdapter(dir, "main", &bytes.Buffer{})
+
+		diff, err := adapter.GetPRDiff(context.Background(), "", "", 0)
+		assert.NoError(t, err)
+		assert.Contains(t, diff, "diff --git a/main.go b/main.go")
+		assert.Contains(t, diff, "+func main() {}")
+	})
+
+	t.Run("Failure - Unknown Base", func(t *testing.T) {
+		dir := setupLocalGitRepo(t)
+		adapter := NewLocalGitAdapter(dir, "does-not-exist", &bytes.Buffer{})
+
+		_, err := adapter.GetPRDiff(context.Background(), "", "", 0)
+		assert.ErrorContains(t, err, "does not name a commit")
+	})
+
+	t.Run("Failure - Base Looks Like An Option", func(t *testing.T) {
+		dir := setupLocalGitRepo(t)
+		out := filepath.Join(t.TempDir(), "out")
+		adapter := NewLocalGitAdapter(dir, "--output="+out, &bytes.Buffer{})
+
+		_, err := adapter.GetPRDiff(context.Background(), "", "", 0)
+		assert.ErrorContains(t, err, "invalid base ref")
+		assert.NoFileExists(t, out)
+	})
+}
+
+func TestLocalGitAdapter_GetPRCommitID(t *testing.T) {
+	dir := setupLocalGitRepo(t)
+	adapter := NewLocalGitAdapter(dir, "main", &bytes.Buffer{})
+
+	sha, err := adapter.GetPRCommitID(context.Background(), "", "", 0)
+	assert.NoError(t, err)
+	assert.Len(t, strings.TrimSpace(sha), 40)
+}
+
+func TestLocalGitAdapter_PostReview(t *testing.T) {
+	var out bytes.Buffer
+	adapter := NewLocalGitAdapter(".", "main", &out)
+
+	comments := []*Comment{{Body: "First line\nSecond line", Path: "main.go", Line: 3}}
+	assert.NoError(t, adapter.PostReview(context.Background(), "", "", 0, comments, ""))
+	assert.NoError(t, adapter.PostGeneralComment(context.Background(), "", "", 0, "Summary"))
+	assert.Equal(t, "main.go:3\n    First line\n    Second line\n\nSummary\n\n", out.String())
+}
+
+func TestLocalGitAdapter_GetCompareDiff(t *testing.T) {
+	dir := setupLocalGitRepo(t)
+	adapter := NewLocalGitAdapter(dir, "main", &bytes.Buffer{})
+
+	t.Run("Success", func(t *testing.T) {
+		diff, err := adapter.GetCompareDiff(context.Background(), "", "", "main", "feature")
+		assert.NoError(t, err)
+		assert.Contains(t, diff, "+func main() {}")
+	})
+
+	t.Run("Failure - Not An Ancestor", func(t *testing.T) {
+		_, err := adapter.GetCompareDiff(context.Background(), "", "", "feature", "main")
+		assert.ErrorIs(t, err, ErrNotAncestor)
+	})
+}
@@ -0,0 +1,80 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/surya84/code-reviewer-bot/pkg/metrics"
+)
+
+// restClient is a minimal JSON-over-HTTP client shared by the adapters that
+// talk to a forge's REST API directly instead of through an SDK.
+type restClient struct {
+	name       string // Provider name used in error messages, e.g. "GitLab".
+	baseURL    string
+	httpClient *http.Client
+	authorize  func(*http.Request)
+}
+
+// newRESTClient creates a client whose failed requests are counted under provider, e.g. "gitlab".
+func newRESTClient(provider, name, baseURL string, authorize func(*http.Request)) *restClient {
+	return &restClient{
+		name:       name,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Transport: metrics.Transport(provider, http.DefaultTransport)},
+		authorize:  authorize,
+	}
+}
+
+// do performs an authenticated request, encoding in as JSON when it is non-nil.
+// The response is decoded as JSON into out, or copied verbatim when out is a *string.
+func (r *restClient) do(ctx context.Context, method, path string, in, out any) (http.Header, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	r.authorize(req)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s API %s %s returned %d: %s", r.name, method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	switch out := out.(type) {
+	case nil:
+	case *string:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s response: %w", r.name, err)
+		}
+		*out = string(data)
+	default:
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("failed to decode %s response: %w", r.name, err)
+		}
+	}
+	return resp.Header, nil
+}